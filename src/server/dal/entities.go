@@ -4,20 +4,63 @@ import (
 	"time"
 )
 
+type PollStatus int
+
+const (
+	PollActive    PollStatus = 0 // Feed is checked on schedule
+	PollGone      PollStatus = 1 // Feed responded with 410 Gone; no longer checked
+	PollSuspended PollStatus = 2 // Checking feed failed too many times in a row; no longer checked
+	PollBlocked   PollStatus = 3 // Feed matches an entry in the blocked feeds list; no longer checked
+	PollOptedOut  PollStatus = 4 // Publisher opted out through robots.txt, the site or the feed; no longer checked
+)
+
+func (ps PollStatus) String() string {
+	switch ps {
+	case PollActive:
+		return "active"
+	case PollGone:
+		return "gone"
+	case PollSuspended:
+		return "suspended"
+	case PollBlocked:
+		return "blocked"
+	case PollOptedOut:
+		return "opted-out"
+	default:
+		return "unknown"
+	}
+}
+
 type Account struct {
-	Id              int
-	CreatedAt       time.Time
-	UserUrl         string // https://rss-parrot.net/u/taiwantrailsandtales.com
-	Handle          string // taiwantrailsandtales.com
-	FeedName        string // taiwan trails and tales | a guide to get you out of the city and into the hills
-	FeedSummary     string // Taiwan Trails and Tales is a one-stop shop for everything Taiwan hiking related. Here you can find information about hundreds of hiking trails in Taiwan, as well as all the details you need to know about how and when to visit.
-	SiteUrl         string // https://taiwantrailsandtales.com
-	FeedUrl         string // https://taiwantrailsandtales.com/feed
-	FeedLastUpdated time.Time
-	NextCheckDue    time.Time
-	PubKey          string
-	ProfileImageUrl string
-	HeaderImageUrl  string
+	Id                int
+	CreatedAt         time.Time
+	UserUrl           string // https://rss-parrot.net/u/taiwantrailsandtales.com
+	Handle            string // taiwantrailsandtales.com
+	FeedName          string // taiwan trails and tales | a guide to get you out of the city and into the hills
+	FeedSummary       string // Taiwan Trails and Tales is a one-stop shop for everything Taiwan hiking related. Here you can find information about hundreds of hiking trails in Taiwan, as well as all the details you need to know about how and when to visit.
+	SiteUrl           string // https://taiwantrailsandtales.com
+	FeedUrl           string // https://taiwantrailsandtales.com/feed
+	FeedLastUpdated   time.Time
+	NextCheckDue      time.Time
+	PubKey            string
+	ProfileImageUrl   string    // Square-ish image discovered on the site or in the feed; empty if none found
+	HeaderImageUrl    string    // Wide image discovered on the site or in the feed; empty if none found
+	ImagesCheckedAt   time.Time // When we last looked for the profile and header images
+	FeedEtag          string    // ETag header of last successful feed response, for conditional GET
+	FeedLastModified  string    // Last-Modified header of last successful feed response, for conditional GET
+	PollStatus        PollStatus
+	CheckFailures     int       // Number of consecutive failed feed checks
+	LastCheckError    string    // Error from most recent failed feed check
+	WebSubHub         string    // WebSub hub we subscribed to for feed, if any
+	WebSubTopic       string    // Topic URL of WebSub subscription
+	WebSubRequestedAt time.Time // When we last sent a subscription request to hub
+	WebSubExpires     time.Time // When hub's verified lease on subscription ends
+	OptOutCheckedAt   time.Time // When we last checked robots.txt and the site for the publisher's opt-out
+	ClaimedName       string    // Display name set by the verified publisher; overrides feed's title
+	ClaimedBio        string    // Bio set by the verified publisher, as plain text; overrides feed's description
+	PublisherAccount  string    // Profile URL of the publisher's own Fediverse account
+	NoHashtags        bool      // Toots don't get hashtags from the posts' categories
+	FloodLimit        int       // Overrides the configured flood limit if positive; negative for no limit; 0 to use config
 }
 
 type Mention struct {
@@ -31,6 +74,9 @@ type FeedPost struct {
 	Link         string
 	Title        string
 	Description  string
+	ContentHash  int64    // Hash of link, title and description, to notice when author edits post
+	Guid         string   // GUID from feed; empty for posts stored before we kept it
+	Hashtags     []string // Normalized from the item's categories, without the leading #
 }
 
 type Toot struct {
@@ -38,15 +84,63 @@ type Toot struct {
 	TootedAt     time.Time
 	StatusId     string
 	Content      string
+	Attachments  []*TootAttachment
+	Hashtags     []string // Without the leading #
+}
+
+// Image or enclosure from a feed item that we attach to the toot
+type TootAttachment struct {
+	Type      string `json:"type"` // "Image", "Audio" or "Document"
+	MediaType string `json:"mediaType,omitempty"`
+	Url       string `json:"url"`
+	Name      string `json:"name,omitempty"` // Alt text
 }
 
 type TootQueueItem struct {
-	Id          int
-	SendingUser string
-	ToInbox     string
-	TootedAt    time.Time
-	StatusId    string
-	Content     string
+	Id           int
+	SendingUser  string
+	ToInbox      string
+	ToHost       string // Host of the inbox; deliveries are scheduled per host
+	TootedAt     time.Time
+	StatusId     string
+	Content      string
+	ActivityType string    // "Create" for new toots, "Update" for edits, "Delete" for removed toots
+	UpdatedAt    time.Time // When toot was edited; only for updates
+	Attempts     int       // Failed delivery attempts so far
+	Attachments  []*TootAttachment
+	Hashtags     []string
+}
+
+// Remote domain we don't federate with. Blocks apply to subdomains too.
+type BlockedDomain struct {
+	Domain    string
+	CreatedAt time.Time
+	Comment   string // Why the domain is blocked; for admins only
+}
+
+// Publisher's claim of an account. Token is published on the site to prove ownership;
+// the secret, which only the publisher knows, authorizes changes once the claim is verified.
+type AccountClaim struct {
+	Token               string
+	AccountId           int
+	Handle              string
+	SecretHash          string
+	CreatedAt           time.Time
+	VerifiedAt          time.Time
+	VerifiedBy          string // How the token was found: meta, file or dns; empty until the claim is verified
+	DeletionRequestedAt time.Time
+}
+
+// Delivery health of a remote inbox. Most followers are reached through their server's shared inbox,
+// so this is usually the health of a whole remote host.
+type InboxHealth struct {
+	Inbox        string
+	Failures     int       // Consecutive failed deliveries
+	FailingSince time.Time // Time of the first failure in the current streak
+	LastFailure  time.Time
+	LastSuccess  time.Time
+	LastError    string
+	Suspended    bool // No deliveries, except for an occasional probe
 }
 
 type FollowerInfo struct {
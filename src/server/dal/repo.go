@@ -3,17 +3,19 @@ package dal
 import (
 	"database/sql"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mattn/go-sqlite3"
 	"rss_parrot/shared"
+	"strings"
 	"sync"
 	"time"
 )
 
 //go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_repo.go -package mocks rss_parrot/dal IRepo
 
-const schemaVer = 6
+const schemaVer = 23
 
 //go:embed scripts/*
 var scripts embed.FS
@@ -32,12 +34,30 @@ type IRepo interface {
 	GetToot(statusId string) (*Toot, error)
 	GetPostCount(user string) (uint, error)
 	GetTotalPostCount() (uint, error)
-	GetPostsPage(accountId int, offset, limit int) ([]*FeedPost, error)
+	GetPostsPage(accountId int, hashtag string, offset, limit int) ([]*FeedPost, error)
 	GetTootExtracts(accountId int) ([]*Toot, error)
+	GetTootAttachmentUrls(user string) ([]string, error)
+	GetTootCount(accountId int) (uint, error)
+	GetTootsPage(accountId int, offset, limit int) ([]*Toot, int, error)
 	GetFeedLastUpdated(accountId int) (time.Time, error)
 	UpdateAccountFeedTimes(accountId int, lastUpdated, nextCheckDue time.Time) error
+	UpdateAccountFeedValidators(accountId int, etag, lastModified string) error
+	UpdateAccountFeedUrl(accountId int, feedUrl string) error
+	SetAccountPollStatus(accountId int, status PollStatus) error
+	SetAccountNoHashtags(accountId int, noHashtags bool) error
+	SetAccountFloodLimit(accountId int, floodLimit int) error
+	SetAccountCheckFailures(accountId int, failures int, lastError string) error
+	GetFailingAccounts(minFailures int) ([]*Account, error)
+	ResumeAccountPolling(accountId int) error
+	SetAccountWebSub(accountId int, hub, topic, secret string, requestedAt time.Time) error
+	SetAccountWebSubExpires(accountId int, expires time.Time) error
+	SetAccountOptOutChecked(accountId int, checkedAt time.Time) error
+	SetAccountImages(accountId int, profileImageUrl, headerImageUrl string, checkedAt time.Time) error
+	GetWebSubSecret(accountId int) (string, error)
 	AddFeedPostIfNew(accountId int, post *FeedPost) (isNew bool, err error)
-	GetAccountToCheck(checkDue time.Time) (*Account, int, error)
+	UpdateFeedPostIfChanged(accountId int, post *FeedPost) (changed bool, err error)
+	UpdateTootContent(accountId int, postGuidHash int64, content string, hashtags []string) (*Toot, error)
+	ClaimAccountsToCheck(checkDue time.Time, maxCount int, claimedUntil time.Time, excludeHosts map[string]bool) ([]*Account, int, error)
 	GetFollowerCount(user string, onlyApproved bool) (uint, error)
 
 	// Returns number of all followers of feeds. Includes unapproved and banned ones, but excludes followers of birb.
@@ -48,12 +68,38 @@ type IRepo interface {
 	SetFollowerApproveStatus(user, followerUserUrl string, status int) error
 	AddFollower(user string, follower *FollowerInfo) error
 	RemoveFollower(user, followerUserUrl string) error
+	DoesFollowerExist(followerUserUrl string) (bool, error)
+	RemoveFollowerEverywhere(followerUserUrl string) (removed int, err error)
+	MoveFollower(oldUserUrl string, newFollower *FollowerInfo) (moved int, err error)
 	AddTootQueueItem(tqi *TootQueueItem) error
-	GetTootQueueItems(aboveId, maxCount int) ([]*TootQueueItem, int, error)
+	GetTootQueueItems(perHost, maxCount int, due time.Time) ([]*TootQueueItem, map[string]int, error)
 	DeleteTootQueueItem(id int) error
-	PurgePostsAndToots(accountId int, fromBefore time.Time) error
+	RetryTootQueueItem(id int, attempts int, nextAttemptAt time.Time) error
+	GetInboxHealth(inbox string) (*InboxHealth, error)
+	RecordInboxSuccess(inbox string, when time.Time) (recovered bool, err error)
+	RecordInboxFailure(inbox string, when time.Time, lastError string) (*InboxHealth, error)
+	SuspendInbox(inbox string) error
+	GetSuspendedInboxCount() (int, error)
+	PurgePostsAndToots(accountId int, fromBefore time.Time) (purgedStatusIds []string, err error)
+	GetFeedPostsSince(accountId int, since time.Time) ([]*FeedPost, error)
+	RekeyFeedPost(accountId int, oldHash, newHash int64, guid string) error
+	GetBlockedDomains() ([]*BlockedDomain, error)
+	AddBlockedDomain(bd *BlockedDomain) (isNew bool, err error)
+	RemoveBlockedDomain(domain string) (removed bool, err error)
+	RemoveFollowersOnDomain(domain string) (removed int, err error)
+	AddAccountClaim(claim *AccountClaim) error
+	GetAccountClaim(accountId int, secretHash string) (*AccountClaim, error)
+	GetVerifiedAccountClaims() ([]*AccountClaim, error)
+	SetAccountClaimVerified(token string, verifiedAt time.Time, verifiedBy string) error
+	SetAccountClaimDeletionRequested(token string, requestedAt time.Time) error
+	DeleteUnverifiedAccountClaims(createdBefore time.Time) error
+	DeleteOlderUnverifiedAccountClaims(accountId int, keep int) error
+	SetAccountClaimedProfile(accountId int, name, bio, publisherAccount string) error
+	DeletePostAndToot(accountId int, postGuidHash int64) (statusId string, err error)
+	GetDeletedToot(statusId string) (deletedAt time.Time, deleted bool, err error)
 	MarkActivityHandled(id string, when time.Time) (alreadyHandled bool, err error)
 	DeleteHandledActivities(before time.Time) error
+	DeleteDeletedToots(before time.Time) error
 }
 
 type Repo struct {
@@ -207,10 +253,11 @@ func (repo *Repo) AddAccountIfNotExist(acct *Account, privKey string) (isNew boo
 
 	isNew = true
 	_, err = repo.db.Exec(`INSERT INTO accounts
-    	(created_at, user_url, handle, feed_name, feed_summary, profile_image_url, site_url, feed_url, pubkey, privkey)
-		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+    	(created_at, user_url, handle, feed_name, feed_summary, profile_image_url, header_image_url, images_checked_at,
+		 site_url, feed_url, pubkey, privkey)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		acct.CreatedAt, acct.UserUrl, acct.Handle, acct.FeedName, acct.FeedSummary, acct.ProfileImageUrl,
-		acct.SiteUrl, acct.FeedUrl, acct.PubKey, privKey)
+		acct.HeaderImageUrl, acct.ImagesCheckedAt, acct.SiteUrl, acct.FeedUrl, acct.PubKey, privKey)
 	if err == nil {
 		return
 	}
@@ -250,14 +297,10 @@ func (repo *Repo) GetAccount(user string) (*Account, error) {
 
 func (repo *Repo) getAccount(user string) (*Account, error) {
 
-	row := repo.db.QueryRow(
-		`SELECT id, created_at, user_url, handle, feed_name, feed_summary, profile_image_url, site_url, feed_url,
-         		feed_last_updated, next_check_due, pubkey
-		FROM accounts WHERE handle=?`, user)
+	row := repo.db.QueryRow(`SELECT `+accountColumns+` FROM accounts WHERE handle=?`, user)
 	var err error
 	var res Account
-	err = row.Scan(&res.Id, &res.CreatedAt, &res.UserUrl, &res.Handle, &res.FeedName, &res.FeedSummary,
-		&res.ProfileImageUrl, &res.SiteUrl, &res.FeedUrl, &res.FeedLastUpdated, &res.NextCheckDue, &res.PubKey)
+	err = scanAccount(row, &res)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -268,12 +311,37 @@ func (repo *Repo) getAccount(user string) (*Account, error) {
 	return &res, nil
 }
 
+// Columns read by scanAccount, in the order it expects them
+const accountColumns = `id, created_at, user_url, handle, feed_name, feed_summary, profile_image_url, site_url,
+	feed_url, feed_last_updated, next_check_due, pubkey, feed_etag, feed_last_modified, poll_status,
+	check_failures, last_check_error, websub_hub, websub_topic, websub_requested_at, websub_expires,
+	optout_checked_at, claimed_name, claimed_bio, publisher_account, header_image_url, images_checked_at,
+	no_hashtags, flood_limit`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAccount(row rowScanner, a *Account) error {
+	return row.Scan(&a.Id, &a.CreatedAt, &a.UserUrl, &a.Handle, &a.FeedName, &a.FeedSummary,
+		&a.ProfileImageUrl, &a.SiteUrl, &a.FeedUrl, &a.FeedLastUpdated, &a.NextCheckDue, &a.PubKey,
+		&a.FeedEtag, &a.FeedLastModified, &a.PollStatus, &a.CheckFailures, &a.LastCheckError,
+		&a.WebSubHub, &a.WebSubTopic, &a.WebSubRequestedAt, &a.WebSubExpires, &a.OptOutCheckedAt,
+		&a.ClaimedName, &a.ClaimedBio, &a.PublisherAccount, &a.HeaderImageUrl, &a.ImagesCheckedAt,
+		&a.NoHashtags, &a.FloodLimit)
+}
+
 func (repo *Repo) BruteDeleteAccount(accountId int) error {
 
 	step1 := func() error {
 		repo.muDb.Lock()
 		defer repo.muDb.Unlock()
-		_, err := repo.db.Exec(`DELETE FROM toots WHERE account_id=?`, accountId)
+		_, err := repo.db.Exec(`INSERT OR IGNORE INTO deleted_toots (status_id, account_id, deleted_at)
+			SELECT status_id, account_id, ? FROM toots WHERE account_id=?`, time.Now().UTC(), accountId)
+		if err != nil {
+			return err
+		}
+		_, err = repo.db.Exec(`DELETE FROM toots WHERE account_id=?`, accountId)
 		if err != nil {
 			return err
 		}
@@ -297,6 +365,10 @@ func (repo *Repo) BruteDeleteAccount(accountId int) error {
 		if err != nil {
 			return err
 		}
+		_, err = repo.db.Exec(`DELETE FROM account_claims WHERE account_id=?`, accountId)
+		if err != nil {
+			return err
+		}
 		return nil
 	}
 
@@ -343,9 +415,7 @@ func (repo *Repo) GetAccountsPage(offset, limit int) ([]*Account, int, error) {
 		return nil, 0, err
 	}
 
-	query := `SELECT id, created_at, user_url, handle, feed_name, feed_summary, profile_image_url, site_url, feed_url,
-        feed_last_updated, next_check_due, pubkey
-		FROM accounts ORDER BY ID DESC LIMIT ? OFFSET ?`
+	query := `SELECT ` + accountColumns + ` FROM accounts ORDER BY ID DESC LIMIT ? OFFSET ?`
 	rows, err := repo.db.Query(query, limit, offset)
 	if err != nil {
 		return nil, 0, err
@@ -354,8 +424,7 @@ func (repo *Repo) GetAccountsPage(offset, limit int) ([]*Account, int, error) {
 
 	for rows.Next() {
 		a := Account{}
-		err = rows.Scan(&a.Id, &a.CreatedAt, &a.UserUrl, &a.Handle, &a.FeedName, &a.FeedSummary,
-			&a.ProfileImageUrl, &a.SiteUrl, &a.FeedUrl, &a.FeedLastUpdated, &a.NextCheckDue, &a.PubKey)
+		err = scanAccount(rows, &a)
 		if err = rows.Err(); err != nil {
 			return nil, 0, err
 		}
@@ -395,14 +464,71 @@ func (repo *Repo) SetPrivKey(user, privKey string) error {
 	return nil
 }
 
+// Attachments are stored as JSON; no attachments is an empty string
+func marshalAttachments(attachments []*TootAttachment) (string, error) {
+	if len(attachments) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(attachments)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalAttachments(val string) ([]*TootAttachment, error) {
+	if val == "" {
+		return nil, nil
+	}
+	var res []*TootAttachment
+	if err := json.Unmarshal([]byte(val), &res); err != nil {
+		return nil, fmt.Errorf("invalid attachments: %v", err)
+	}
+	return res, nil
+}
+
+var likeEscaper = strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_")
+
+// Hashtags are stored separated by spaces, which they never contain
+func joinHashtags(hashtags []string) string {
+	return strings.Join(hashtags, " ")
+}
+
+func splitHashtags(val string) []string {
+	if val == "" {
+		return nil
+	}
+	return strings.Fields(val)
+}
+
+func scanToot(row rowScanner) (*Toot, error) {
+	t := Toot{}
+	var attachments, hashtags string
+	if err := row.Scan(&t.PostGuidHash, &t.TootedAt, &t.StatusId, &t.Content, &attachments, &hashtags); err != nil {
+		return nil, err
+	}
+	t.Hashtags = splitHashtags(hashtags)
+	var err error
+	if t.Attachments, err = unmarshalAttachments(attachments); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
 func (repo *Repo) AddToot(accountId int, toot *Toot) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	_, err := repo.db.Exec(`INSERT INTO toots (account_id, post_guid_hash, tooted_at, status_id, content)
-		VALUES(?, ?, ?, ?, ?)`,
-		accountId, toot.PostGuidHash, toot.TootedAt, toot.StatusId, toot.Content)
+	attachments, err := marshalAttachments(toot.Attachments)
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.Exec(`INSERT INTO toots
+		(account_id, post_guid_hash, tooted_at, status_id, content, attachments, hashtags)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`,
+		accountId, toot.PostGuidHash, toot.TootedAt, toot.StatusId, toot.Content, attachments,
+		joinHashtags(toot.Hashtags))
 	if err != nil {
 		return err
 	}
@@ -414,7 +540,8 @@ func (repo *Repo) GetToot(statusId string) (*Toot, error) {
 	repo.muDb.RLock()
 	defer repo.muDb.RUnlock()
 
-	query := `SELECT post_guid_hash, tooted_at, status_id, content FROM toots WHERE status_id=?`
+	query := `SELECT post_guid_hash, tooted_at, status_id, content, attachments, hashtags FROM toots
+		WHERE status_id=?`
 	rows, err := repo.db.Query(query, statusId)
 	if err != nil {
 		return nil, err
@@ -422,12 +549,14 @@ func (repo *Repo) GetToot(statusId string) (*Toot, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		t := Toot{}
-		err = rows.Scan(&t.PostGuidHash, &t.TootedAt, &t.StatusId, &t.Content)
-		if err = rows.Err(); err != nil {
+		t, err := scanToot(rows)
+		if err != nil {
 			return nil, err
 		}
-		return &t, nil
+		return t, nil
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
 	}
 	return nil, nil
 }
@@ -461,7 +590,8 @@ func (repo *Repo) GetTotalPostCount() (uint, error) {
 	return uint(count), nil
 }
 
-func (repo *Repo) GetPostsPage(accountId int, offset, limit int) ([]*FeedPost, error) {
+// Returns one page of the account's posts, newest first. With a hashtag, only posts that have it.
+func (repo *Repo) GetPostsPage(accountId int, hashtag string, offset, limit int) ([]*FeedPost, error) {
 
 	repo.muDb.RLock()
 	defer repo.muDb.RUnlock()
@@ -469,9 +599,12 @@ func (repo *Repo) GetPostsPage(accountId int, offset, limit int) ([]*FeedPost, e
 	var res []*FeedPost
 	var err error
 
-	query := `SELECT post_guid_hash, post_time, link, title, description
-		FROM feed_posts WHERE account_id=? ORDER BY post_time DESC LIMIT ? OFFSET ?`
-	rows, err := repo.db.Query(query, accountId, limit, offset)
+	query := `SELECT post_guid_hash, post_time, link, title, description, hashtags
+		FROM feed_posts WHERE account_id=? AND (?='' OR ' ' || hashtags || ' ' LIKE ? ESCAPE '\')
+		ORDER BY post_time DESC LIMIT ? OFFSET ?`
+	// Underscores in hashtags would be wildcards for LIKE; matching is case-insensitive
+	pattern := "% " + likeEscaper.Replace(hashtag) + " %"
+	rows, err := repo.db.Query(query, accountId, hashtag, pattern, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -479,12 +612,16 @@ func (repo *Repo) GetPostsPage(accountId int, offset, limit int) ([]*FeedPost, e
 
 	for rows.Next() {
 		p := FeedPost{}
-		err = rows.Scan(&p.PostGuidHash, &p.PostTime, &p.Link, &p.Title, &p.Description)
-		if err = rows.Err(); err != nil {
+		var hashtags string
+		if err = rows.Scan(&p.PostGuidHash, &p.PostTime, &p.Link, &p.Title, &p.Description, &hashtags); err != nil {
 			return nil, err
 		}
+		p.Hashtags = splitHashtags(hashtags)
 		res = append(res, &p)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
@@ -515,6 +652,90 @@ func (repo *Repo) GetTootExtracts(accountId int) ([]*Toot, error) {
 
 }
 
+// URLs of all attachments in the account's stored toots.
+func (repo *Repo) GetTootAttachmentUrls(user string) ([]string, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	rows, err := repo.db.Query(`SELECT toots.attachments FROM toots
+		JOIN accounts ON toots.account_id=accounts.id AND accounts.handle=?
+		WHERE toots.attachments!=''`, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]string, 0)
+	for rows.Next() {
+		var val string
+		if err = rows.Scan(&val); err != nil {
+			return nil, err
+		}
+		var attachments []*TootAttachment
+		if attachments, err = unmarshalAttachments(val); err != nil {
+			return nil, err
+		}
+		for _, att := range attachments {
+			res = append(res, att.Url)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Returns one page of the account's toots, newest first, and the total number of toots.
+func (repo *Repo) GetTootCount(accountId int) (uint, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM toots WHERE account_id=?`, accountId)
+	var err error
+	var count int
+	if err = row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return uint(count), nil
+}
+
+func (repo *Repo) GetTootsPage(accountId int, offset, limit int) ([]*Toot, int, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	var res []*Toot
+	var total int
+	var err error
+
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM toots WHERE account_id=?`, accountId)
+	if err = row.Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT post_guid_hash, tooted_at, status_id, content, attachments, hashtags
+		FROM toots WHERE account_id=? ORDER BY tooted_at DESC, status_id DESC LIMIT ? OFFSET ?`
+	rows, err := repo.db.Query(query, accountId, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanToot(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
 func (repo *Repo) GetFollowerCount(user string, onlyApproved bool) (uint, error) {
 
 	repo.muDb.RLock()
@@ -572,7 +793,8 @@ func (repo *Repo) GetFollowersByUser(user string, onlyApproved bool) ([]*Followe
 	repo.muDb.RLock()
 	defer repo.muDb.RUnlock()
 
-	query := `SELECT followers.request_id, followers.user_url, followers.handle, host, user_inbox, shared_inbox
+	query := `SELECT followers.request_id, followers.approve_status, followers.user_url, followers.handle, host,
+		user_inbox, shared_inbox
 		FROM followers JOIN accounts ON followers.account_id=accounts.id AND accounts.handle=?`
 	if onlyApproved {
 		query += ` WHERE followers.approve_status=1`
@@ -590,7 +812,8 @@ func (repo *Repo) GetFollowersById(accountId int, onlyApproved bool) ([]*Followe
 	repo.muDb.RLock()
 	defer repo.muDb.RUnlock()
 
-	query := `SELECT request_id, user_url, handle, host, user_inbox, shared_inbox FROM followers WHERE account_id=?`
+	query := `SELECT request_id, approve_status, user_url, handle, host, user_inbox, shared_inbox
+		FROM followers WHERE account_id=?`
 	if onlyApproved {
 		query += ` AND followers.approve_status=1`
 	}
@@ -607,7 +830,7 @@ func readGetFollowers(rows *sql.Rows) ([]*FollowerInfo, error) {
 	res := make([]*FollowerInfo, 0)
 	for rows.Next() {
 		mui := FollowerInfo{}
-		err = rows.Scan(&mui.RequestId, &mui.UserUrl, &mui.Handle, &mui.Host, &mui.UserInbox, &mui.SharedInbox)
+		err = rows.Scan(&mui.RequestId, &mui.ApproveStatus, &mui.UserUrl, &mui.Handle, &mui.Host, &mui.UserInbox, &mui.SharedInbox)
 		if err != nil {
 			return nil, err
 		}
@@ -659,6 +882,65 @@ func (repo *Repo) RemoveFollower(user, followerUserUrl string) error {
 	return nil
 }
 
+// Checks if the remote user follows any of our accounts, whether approved or not.
+func (repo *Repo) DoesFollowerExist(followerUserUrl string) (bool, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM followers WHERE user_url=?`, followerUserUrl)
+	var err error
+	var count int
+	if err = row.Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Removes the remote user from the followers of all our accounts, e.g., because the user was deleted.
+func (repo *Repo) RemoveFollowerEverywhere(followerUserUrl string) (removed int, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var res sql.Result
+	if res, err = repo.db.Exec(`DELETE FROM followers WHERE user_url=?`, followerUserUrl); err != nil {
+		return 0, err
+	}
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// Makes the new remote user follow every account that the old user follows, and removes the old user's follows.
+// Approval status and follow request IDs are kept. If the new user already follows an account, that follow stays as is.
+func (repo *Repo) MoveFollower(oldUserUrl string, newFollower *FollowerInfo) (moved int, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var res sql.Result
+	res, err = repo.db.Exec(`UPDATE OR IGNORE followers
+		SET user_url=?, handle=?, host=?, user_inbox=?, shared_inbox=?
+		WHERE user_url=?`,
+		newFollower.UserUrl, newFollower.Handle, newFollower.Host, newFollower.UserInbox, newFollower.SharedInbox,
+		oldUserUrl)
+	if err != nil {
+		return 0, err
+	}
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return 0, err
+	}
+	// Rows left behind were for accounts the new user already follows
+	if _, err = repo.db.Exec(`DELETE FROM followers WHERE user_url=?`, oldUserUrl); err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
 func (repo *Repo) GetFeedLastUpdated(accountId int) (res time.Time, err error) {
 
 	repo.muDb.RLock()
@@ -683,169 +965,843 @@ func (repo *Repo) UpdateAccountFeedTimes(accountId int, lastUpdated, nextCheckDu
 	return err
 }
 
-func (repo *Repo) GetAccountToCheck(checkDue time.Time) (*Account, int, error) {
+func (repo *Repo) UpdateAccountFeedValidators(accountId int, etag, lastModified string) error {
 
-	repo.muDb.RLock()
-	defer repo.muDb.RUnlock()
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
 
-	var nCheckableAccounts int
-	row := repo.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE next_check_due<?`, checkDue)
-	if err := row.Scan(&nCheckableAccounts); err != nil {
-		return nil, 0, err
-	}
+	_, err := repo.db.Exec(`UPDATE accounts SET feed_etag=?, feed_last_modified=?
+        WHERE id=?`, etag, lastModified, accountId)
+	return err
+}
 
-	rows, err := repo.db.Query(`SELECT id, created_at, user_url, handle, feed_name, feed_summary,
-    	profile_image_url, site_url, feed_url, feed_last_updated, next_check_due, pubkey
-		FROM accounts WHERE next_check_due<? LIMIT 1`, checkDue)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-	var acct *Account = nil
-	for rows.Next() {
-		res := Account{}
-		err = rows.Scan(&res.Id, &res.CreatedAt, &res.UserUrl, &res.Handle, &res.FeedName, &res.FeedSummary,
-			&res.ProfileImageUrl, &res.SiteUrl, &res.FeedUrl, &res.FeedLastUpdated, &res.NextCheckDue, &res.PubKey)
-		if err = rows.Err(); err != nil {
-			return nil, 0, err
-		}
-		acct = &res
-	}
-	return acct, nCheckableAccounts, nil
+func (repo *Repo) UpdateAccountFeedUrl(accountId int, feedUrl string) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
 
+	_, err := repo.db.Exec(`UPDATE accounts SET feed_url=?, feed_etag='', feed_last_modified=''
+        WHERE id=?`, feedUrl, accountId)
+	return err
 }
 
-func (repo *Repo) AddFeedPostIfNew(accountId int, post *FeedPost) (isNew bool, err error) {
+func (repo *Repo) SetAccountNoHashtags(accountId int, noHashtags bool) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	err = nil
+	_, err := repo.db.Exec(`UPDATE accounts SET no_hashtags=? WHERE id=?`, noHashtags, accountId)
+	return err
+}
 
-	_, err = repo.db.Exec(`INSERT INTO feed_posts
-    	(account_id, post_guid_hash, post_time, link, title, description)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		accountId, post.PostGuidHash, post.PostTime, post.Link, post.Title, post.Description)
+func (repo *Repo) SetAccountFloodLimit(accountId int, floodLimit int) error {
 
-	if err == nil {
-		isNew = true
-		return
-	}
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
 
-	// Duplicate key: feed post for this account+guid_hash already exists
-	if sqliteErr, ok := err.(sqlite3.Error); ok {
-		// Duplicate key: record with this unique key already exists
-		if sqliteErr.Code == 19 && sqliteErr.ExtendedCode == 2067 {
-			isNew = false
-			err = nil
-			return
-		}
-	}
+	_, err := repo.db.Exec(`UPDATE accounts SET flood_limit=? WHERE id=?`, floodLimit, accountId)
+	return err
+}
 
-	return
+func (repo *Repo) SetAccountPollStatus(accountId int, status PollStatus) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE accounts SET poll_status=? WHERE id=?`, status, accountId)
+	return err
 }
 
-func (repo *Repo) AddTootQueueItem(tqi *TootQueueItem) error {
+func (repo *Repo) SetAccountCheckFailures(accountId int, failures int, lastError string) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	_, err := repo.db.Exec(`INSERT INTO toot_queue (sending_user, to_inbox, tooted_at, status_id, content)
-		VALUES(?, ?, ?, ?, ?)`,
-		tqi.SendingUser, tqi.ToInbox, tqi.TootedAt, tqi.StatusId, tqi.Content)
+	_, err := repo.db.Exec(`UPDATE accounts SET check_failures=?, last_check_error=? WHERE id=?`,
+		failures, lastError, accountId)
 	return err
 }
 
-func (repo *Repo) GetTootQueueItems(aboveId, maxCount int) ([]*TootQueueItem, int, error) {
+func (repo *Repo) GetFailingAccounts(minFailures int) ([]*Account, error) {
 
 	repo.muDb.RLock()
 	defer repo.muDb.RUnlock()
 
-	var itmCount int
-	row := repo.db.QueryRow(`SELECT COUNT(*) FROM toot_queue`)
-	if err := row.Scan(&itmCount); err != nil {
-		return nil, 0, err
-	}
-
-	rows, err := repo.db.Query(`SELECT id, sending_user, to_inbox, tooted_at, status_id, content
-		FROM toot_queue WHERE id>? ORDER BY id ASC LIMIT ?`, aboveId, maxCount)
+	rows, err := repo.db.Query(`SELECT `+accountColumns+` FROM accounts
+		WHERE check_failures>=? OR poll_status<>? ORDER BY check_failures DESC`, minFailures, PollActive)
 	if err != nil {
-		return nil, itmCount, err
+		return nil, err
 	}
 	defer rows.Close()
-	res := make([]*TootQueueItem, 0, maxCount)
+
+	res := make([]*Account, 0)
 	for rows.Next() {
-		tqi := TootQueueItem{}
-		err = rows.Scan(&tqi.Id, &tqi.SendingUser, &tqi.ToInbox, &tqi.TootedAt, &tqi.StatusId, &tqi.Content)
-		if err != nil {
-			return nil, itmCount, err
+		a := Account{}
+		if err = scanAccount(rows, &a); err != nil {
+			return nil, err
 		}
-		res = append(res, &tqi)
+		res = append(res, &a)
 	}
 	if err = rows.Err(); err != nil {
-		return nil, itmCount, err
+		return nil, err
 	}
-	return res, itmCount, nil
+	return res, nil
 }
 
-func (repo *Repo) DeleteTootQueueItem(id int) error {
+func (repo *Repo) ResumeAccountPolling(accountId int) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	_, err := repo.db.Exec(`DELETE FROM toot_queue WHERE id=?`, id)
+	_, err := repo.db.Exec(`UPDATE accounts SET poll_status=?, check_failures=0, last_check_error='', next_check_due=?
+		WHERE id=?`, PollActive, time.Now().UTC(), accountId)
 	return err
 }
 
-func (repo *Repo) PurgePostsAndToots(accountId int, fromBefore time.Time) error {
+// Records a subscription request sent to a WebSub hub.
+// If hub or topic changed, the old lease is dropped: new subscription is not active until hub verifies it.
+func (repo *Repo) SetAccountWebSub(accountId int, hub, topic, secret string, requestedAt time.Time) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	if _, err := repo.db.Exec(`DELETE FROM feed_posts
-       	WHERE account_id=? AND post_time<=?`, accountId, fromBefore); err != nil {
-		return err
-	}
-	if _, err := repo.db.Exec(`DELETE FROM toots
-       	WHERE account_id=? AND tooted_at<=?`, accountId, fromBefore); err != nil {
-		return err
-	}
-
-	return nil
+	_, err := repo.db.Exec(`UPDATE accounts SET
+		websub_expires=CASE WHEN websub_hub=? AND websub_topic=? THEN websub_expires ELSE ? END,
+		websub_hub=?, websub_topic=?, websub_secret=?, websub_requested_at=? WHERE id=?`,
+		hub, topic, time.Time{}, hub, topic, secret, requestedAt, accountId)
+	return err
 }
 
-func (repo *Repo) MarkActivityHandled(id string, when time.Time) (alreadyHandled bool, err error) {
+func (repo *Repo) SetAccountWebSubExpires(accountId int, expires time.Time) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	alreadyHandled = false
-	err = nil
-
-	_, err = repo.db.Exec(`INSERT INTO handled_activities VALUES (?, ?)`, id, when)
+	_, err := repo.db.Exec(`UPDATE accounts SET websub_expires=? WHERE id=?`, expires, accountId)
+	return err
+}
 
-	if err == nil {
-		return
-	}
+func (repo *Repo) SetAccountOptOutChecked(accountId int, checkedAt time.Time) error {
 
-	// Duplicate key: activity was handled before
-	if sqliteErr, ok := err.(sqlite3.Error); ok {
-		// Duplicate key: account with this handle already exists
-		if sqliteErr.Code == 19 && sqliteErr.ExtendedCode == 2067 {
-			alreadyHandled = true
-			err = nil
-			return
-		}
-	}
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
 
-	return
+	_, err := repo.db.Exec(`UPDATE accounts SET optout_checked_at=? WHERE id=?`, checkedAt, accountId)
+	return err
 }
 
-func (repo *Repo) DeleteHandledActivities(before time.Time) error {
+func (repo *Repo) SetAccountImages(accountId int, profileImageUrl, headerImageUrl string, checkedAt time.Time) error {
 
 	repo.muDb.Lock()
 	defer repo.muDb.Unlock()
 
-	_, err := repo.db.Exec(`DELETE FROM handled_activities WHERE handled_at<?`, before)
+	_, err := repo.db.Exec(`UPDATE accounts SET profile_image_url=?, header_image_url=?, images_checked_at=? WHERE id=?`,
+		profileImageUrl, headerImageUrl, checkedAt, accountId)
+	return err
+}
+
+func (repo *Repo) GetWebSubSecret(accountId int) (string, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT websub_secret FROM accounts WHERE id=?`, accountId)
+	var secret string
+	if err := row.Scan(&secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Returns up to maxCount accounts due for checking, oldest due first, plus the number of all due accounts.
+// Pushes next check time of returned accounts to claimedUntil, so they're not returned again while being checked.
+// Claims the accounts due soonest, leaving out those whose feed is on one of the excluded hosts.
+func (repo *Repo) ClaimAccountsToCheck(checkDue time.Time, maxCount int, claimedUntil time.Time, excludeHosts map[string]bool) ([]*Account, int, error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var nCheckableAccounts int
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM accounts WHERE next_check_due<? AND poll_status=?`,
+		checkDue, PollActive)
+	if err := row.Scan(&nCheckableAccounts); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := repo.db.Query(`SELECT `+accountColumns+` FROM accounts
+		WHERE next_check_due<? AND poll_status=? ORDER BY next_check_due`, checkDue, PollActive)
+	if err != nil {
+		return nil, 0, err
+	}
+	accts := make([]*Account, 0, maxCount)
+	for len(accts) < maxCount && rows.Next() {
+		acct := Account{}
+		if err = scanAccount(rows, &acct); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		if excludeHosts[shared.GetFeedHost(acct.FeedUrl)] {
+			continue
+		}
+		accts = append(accts, &acct)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, acct := range accts {
+		_, err = repo.db.Exec(`UPDATE accounts SET next_check_due=? WHERE id=?`, claimedUntil, acct.Id)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return accts, nCheckableAccounts, nil
+}
+
+func (repo *Repo) AddFeedPostIfNew(accountId int, post *FeedPost) (isNew bool, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	err = nil
+
+	_, err = repo.db.Exec(`INSERT INTO feed_posts
+    	(account_id, post_guid_hash, post_time, link, title, description, content_hash, guid, hashtags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		accountId, post.PostGuidHash, post.PostTime, post.Link, post.Title, post.Description, post.ContentHash,
+		post.Guid, joinHashtags(post.Hashtags))
+
+	if err == nil {
+		isNew = true
+		return
+	}
+
+	// Duplicate key: feed post for this account+guid_hash already exists
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		// Duplicate key: record with this unique key already exists
+		if sqliteErr.Code == 19 && sqliteErr.ExtendedCode == 2067 {
+			isNew = false
+			err = nil
+			return
+		}
+	}
+
+	return
+}
+
+// Updates a stored post's link, title, description and hashtags if its content hash differs.
+// Returns false if we don't have the post, or it hasn't changed.
+// Posts stored before we kept content hashes get their hash filled in, but don't count as changed.
+func (repo *Repo) UpdateFeedPostIfChanged(accountId int, post *FeedPost) (changed bool, err error) {
+
+	var oldHash int64
+	repo.muDb.RLock()
+	row := repo.db.QueryRow(`SELECT content_hash FROM feed_posts WHERE account_id=? AND post_guid_hash=?`,
+		accountId, post.PostGuidHash)
+	err = row.Scan(&oldHash)
+	repo.muDb.RUnlock()
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil || oldHash == post.ContentHash {
+		return false, err
+	}
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err = repo.db.Exec(`UPDATE feed_posts SET link=?, title=?, description=?, content_hash=?, hashtags=?
+		WHERE account_id=? AND post_guid_hash=?`,
+		post.Link, post.Title, post.Description, post.ContentHash, joinHashtags(post.Hashtags), accountId,
+		post.PostGuidHash)
+	if err != nil {
+		return false, err
+	}
+	return oldHash != 0, nil
+}
+
+// Replaces content and hashtags of the toot we made from a post. Returns nil if there is no such toot.
+func (repo *Repo) UpdateTootContent(accountId int, postGuidHash int64, content string, hashtags []string) (*Toot, error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE toots SET content=?, hashtags=? WHERE account_id=? AND post_guid_hash=?`,
+		content, joinHashtags(hashtags), accountId, postGuidHash)
+	if err != nil {
+		return nil, err
+	}
+
+	row := repo.db.QueryRow(`SELECT post_guid_hash, tooted_at, status_id, content, attachments, hashtags FROM toots
+		WHERE account_id=? AND post_guid_hash=?`, accountId, postGuidHash)
+	t, err := scanToot(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (repo *Repo) AddTootQueueItem(tqi *TootQueueItem) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	attachments, err := marshalAttachments(tqi.Attachments)
+	if err != nil {
+		return err
+	}
+	_, err = repo.db.Exec(`INSERT INTO toot_queue
+		(sending_user, to_inbox, to_host, tooted_at, status_id, content, activity_type, updated_at, attachments,
+		 hashtags)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tqi.SendingUser, tqi.ToInbox, tqi.ToHost, tqi.TootedAt, tqi.StatusId, tqi.Content, tqi.ActivityType,
+		tqi.UpdatedAt, attachments, joinHashtags(tqi.Hashtags))
+	return err
+}
+
+// Returns due items, at most perHost for each destination host, taking turns between hosts in the order
+// of their oldest items. Also returns the number of queued items per host, due or not.
+func (repo *Repo) GetTootQueueItems(perHost, maxCount int, due time.Time) ([]*TootQueueItem, map[string]int, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	depths := make(map[string]int)
+	rows, err := repo.db.Query(`SELECT to_host, COUNT(*) FROM toot_queue GROUP BY to_host`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var host string
+		var count int
+		if err = rows.Scan(&host, &count); err != nil {
+			return nil, nil, err
+		}
+		depths[host] = count
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = repo.db.Query(`SELECT id, sending_user, to_inbox, to_host, tooted_at, status_id, content,
+		activity_type, updated_at, attempts, attachments, hashtags FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY to_host ORDER BY id) AS host_rank
+			FROM toot_queue WHERE next_attempt_at<=?
+		) WHERE host_rank<=? ORDER BY host_rank ASC, id ASC LIMIT ?`, due, perHost, maxCount)
+	if err != nil {
+		return nil, depths, err
+	}
+	defer rows.Close()
+	res := make([]*TootQueueItem, 0, maxCount)
+	for rows.Next() {
+		tqi := TootQueueItem{}
+		var attachments, hashtags string
+		err = rows.Scan(&tqi.Id, &tqi.SendingUser, &tqi.ToInbox, &tqi.ToHost, &tqi.TootedAt, &tqi.StatusId,
+			&tqi.Content, &tqi.ActivityType, &tqi.UpdatedAt, &tqi.Attempts, &attachments, &hashtags)
+		if err != nil {
+			return nil, depths, err
+		}
+		tqi.Hashtags = splitHashtags(hashtags)
+		if tqi.Attachments, err = unmarshalAttachments(attachments); err != nil {
+			return nil, depths, err
+		}
+		res = append(res, &tqi)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, depths, err
+	}
+	return res, depths, nil
+}
+
+func (repo *Repo) DeleteTootQueueItem(id int) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`DELETE FROM toot_queue WHERE id=?`, id)
+	return err
+}
+
+// Keeps the item in the queue after a failed delivery, to be attempted again later.
+func (repo *Repo) RetryTootQueueItem(id int, attempts int, nextAttemptAt time.Time) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE toot_queue SET attempts=?, next_attempt_at=? WHERE id=?`,
+		attempts, nextAttemptAt, id)
+	return err
+}
+
+const inboxHealthColumns = `inbox, failures, failing_since, last_failure, last_success, last_error, suspended`
+
+func scanInboxHealth(row rowScanner, ih *InboxHealth) error {
+	return row.Scan(&ih.Inbox, &ih.Failures, &ih.FailingSince, &ih.LastFailure, &ih.LastSuccess,
+		&ih.LastError, &ih.Suspended)
+}
+
+// Returns nil if we have never recorded a failed delivery to the inbox.
+func (repo *Repo) GetInboxHealth(inbox string) (*InboxHealth, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT `+inboxHealthColumns+` FROM inbox_health WHERE inbox=?`, inbox)
+	var ih InboxHealth
+	if err := scanInboxHealth(row, &ih); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ih, nil
+}
+
+// Resets the failure streak and lifts suspension. Returns true if the inbox had been failing.
+func (repo *Repo) RecordInboxSuccess(inbox string, when time.Time) (recovered bool, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var failures int
+	row := repo.db.QueryRow(`SELECT failures FROM inbox_health WHERE inbox=?`, inbox)
+	if err = row.Scan(&failures); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	_, err = repo.db.Exec(`INSERT INTO inbox_health (inbox, last_success) VALUES(?, ?)
+		ON CONFLICT(inbox) DO UPDATE SET
+			failures=0, failing_since='1900-01-01 00:00:00', last_success=excluded.last_success,
+			last_error='', suspended=0`,
+		inbox, when)
+	if err != nil {
+		return false, err
+	}
+	return failures > 0, nil
+}
+
+// Adds a failure to the inbox's streak and returns the updated health.
+func (repo *Repo) RecordInboxFailure(inbox string, when time.Time, lastError string) (*InboxHealth, error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`INSERT INTO inbox_health (inbox, failures, failing_since, last_failure, last_error)
+		VALUES(?, 1, ?, ?, ?)
+		ON CONFLICT(inbox) DO UPDATE SET
+			failing_since=CASE WHEN failures=0 THEN excluded.failing_since ELSE failing_since END,
+			failures=failures+1, last_failure=excluded.last_failure, last_error=excluded.last_error`,
+		inbox, when, when, lastError)
+	if err != nil {
+		return nil, err
+	}
+
+	row := repo.db.QueryRow(`SELECT `+inboxHealthColumns+` FROM inbox_health WHERE inbox=?`, inbox)
+	var ih InboxHealth
+	if err = scanInboxHealth(row, &ih); err != nil {
+		return nil, err
+	}
+	return &ih, nil
+}
+
+func (repo *Repo) SuspendInbox(inbox string) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE inbox_health SET suspended=1 WHERE inbox=?`, inbox)
+	return err
+}
+
+func (repo *Repo) GetSuspendedInboxCount() (int, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT COUNT(*) FROM inbox_health WHERE suspended=1`)
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (repo *Repo) PurgePostsAndToots(accountId int, fromBefore time.Time) (purgedStatusIds []string, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var rows *sql.Rows
+	if rows, err = repo.db.Query(`SELECT status_id FROM toots
+       	WHERE account_id=? AND tooted_at<=?`, accountId, fromBefore); err != nil {
+		return nil, err
+	}
+	purgedStatusIds = make([]string, 0)
+	for rows.Next() {
+		var statusId string
+		if err = rows.Scan(&statusId); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		purgedStatusIds = append(purgedStatusIds, statusId)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = repo.db.Exec(`DELETE FROM feed_posts
+       	WHERE account_id=? AND post_time<=?`, accountId, fromBefore); err != nil {
+		return nil, err
+	}
+	if _, err = repo.db.Exec(`INSERT OR IGNORE INTO deleted_toots (status_id, account_id, deleted_at)
+		SELECT status_id, account_id, ? FROM toots WHERE account_id=? AND tooted_at<=?`,
+		time.Now().UTC(), accountId, fromBefore); err != nil {
+		return nil, err
+	}
+	if _, err = repo.db.Exec(`DELETE FROM toots
+       	WHERE account_id=? AND tooted_at<=?`, accountId, fromBefore); err != nil {
+		return nil, err
+	}
+
+	return purgedStatusIds, nil
+}
+
+func (repo *Repo) GetFeedPostsSince(accountId int, since time.Time) ([]*FeedPost, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	rows, err := repo.db.Query(`SELECT post_guid_hash, post_time, link, title, description, content_hash, guid
+		FROM feed_posts WHERE account_id=? AND post_time>=?`, accountId, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*FeedPost, 0)
+	for rows.Next() {
+		fp := FeedPost{}
+		err = rows.Scan(&fp.PostGuidHash, &fp.PostTime, &fp.Link, &fp.Title, &fp.Description, &fp.ContentHash,
+			&fp.Guid)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &fp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *Repo) GetBlockedDomains() ([]*BlockedDomain, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	rows, err := repo.db.Query(`SELECT domain, created_at, comment FROM blocked_domains ORDER BY domain ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*BlockedDomain, 0)
+	for rows.Next() {
+		bd := BlockedDomain{}
+		if err = rows.Scan(&bd.Domain, &bd.CreatedAt, &bd.Comment); err != nil {
+			return nil, err
+		}
+		res = append(res, &bd)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Returns false if the domain was already blocked; its comment is then left as it was.
+func (repo *Repo) AddBlockedDomain(bd *BlockedDomain) (isNew bool, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var res sql.Result
+	res, err = repo.db.Exec(`INSERT OR IGNORE INTO blocked_domains (domain, created_at, comment) VALUES (?, ?, ?)`,
+		bd.Domain, bd.CreatedAt, bd.Comment)
+	if err != nil {
+		return false, err
+	}
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+func (repo *Repo) RemoveBlockedDomain(domain string) (removed bool, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	var res sql.Result
+	if res, err = repo.db.Exec(`DELETE FROM blocked_domains WHERE domain=?`, domain); err != nil {
+		return false, err
+	}
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return false, err
+	}
+	return affected != 0, nil
+}
+
+// Removes all follows by users on the domain or its subdomains, and drops toots queued for delivery there.
+func (repo *Repo) RemoveFollowersOnDomain(domain string) (removed int, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	// Domain may hold _, which LIKE would otherwise take for any character
+	subdomains := "%." + likeEscaper.Replace(domain)
+	var res sql.Result
+	res, err = repo.db.Exec(`DELETE FROM followers WHERE lower(host)=? OR lower(host) LIKE ? ESCAPE '\'`,
+		domain, subdomains)
+	if err != nil {
+		return 0, err
+	}
+	var affected int64
+	if affected, err = res.RowsAffected(); err != nil {
+		return 0, err
+	}
+	_, err = repo.db.Exec(`DELETE FROM toot_queue WHERE to_host=? OR to_host LIKE ? ESCAPE '\'`, domain, subdomains)
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// Moves a stored post and the toot we made from it to a new GUID hash, when the feed has republished
+// the same post under a different identity. Content is left alone, so caller can tell if it changed.
+func (repo *Repo) RekeyFeedPost(accountId int, oldHash, newHash int64, guid string) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	if _, err := repo.db.Exec(`UPDATE feed_posts SET post_guid_hash=?, guid=? WHERE account_id=? AND post_guid_hash=?`,
+		newHash, guid, accountId, oldHash); err != nil {
+		return err
+	}
+	_, err := repo.db.Exec(`UPDATE toots SET post_guid_hash=? WHERE account_id=? AND post_guid_hash=?`,
+		newHash, accountId, oldHash)
+	return err
+}
+
+func (repo *Repo) AddAccountClaim(claim *AccountClaim) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`INSERT INTO account_claims (token, account_id, secret_hash, created_at) VALUES (?, ?, ?, ?)`,
+		claim.Token, claim.AccountId, claim.SecretHash, claim.CreatedAt)
+	return err
+}
+
+const accountClaimColumns = `c.token, c.account_id, a.handle, c.secret_hash, c.created_at, c.verified_at, c.verified_by,
+	c.deletion_requested_at`
+
+func scanAccountClaim(row rowScanner, c *AccountClaim) error {
+	return row.Scan(&c.Token, &c.AccountId, &c.Handle, &c.SecretHash, &c.CreatedAt, &c.VerifiedAt, &c.VerifiedBy,
+		&c.DeletionRequestedAt)
+}
+
+// Returns the account's claim with this secret, or nil if there's no such claim.
+func (repo *Repo) GetAccountClaim(accountId int, secretHash string) (*AccountClaim, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT `+accountClaimColumns+` FROM account_claims c
+		JOIN accounts a ON a.id=c.account_id WHERE c.account_id=? AND c.secret_hash=?`, accountId, secretHash)
+	claim := AccountClaim{}
+	if err := scanAccountClaim(row, &claim); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &claim, nil
+}
+
+func (repo *Repo) GetVerifiedAccountClaims() ([]*AccountClaim, error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	rows, err := repo.db.Query(`SELECT ` + accountClaimColumns + ` FROM account_claims c
+		JOIN accounts a ON a.id=c.account_id WHERE c.verified_by<>'' ORDER BY c.verified_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]*AccountClaim, 0)
+	for rows.Next() {
+		claim := AccountClaim{}
+		if err = scanAccountClaim(rows, &claim); err != nil {
+			return nil, err
+		}
+		res = append(res, &claim)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (repo *Repo) SetAccountClaimVerified(token string, verifiedAt time.Time, verifiedBy string) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE account_claims SET verified_at=?, verified_by=? WHERE token=?`,
+		verifiedAt, verifiedBy, token)
+	return err
+}
+
+func (repo *Repo) SetAccountClaimDeletionRequested(token string, requestedAt time.Time) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE account_claims SET deletion_requested_at=? WHERE token=?`, requestedAt, token)
+	return err
+}
+
+// Removes claims that were started, but never verified.
+func (repo *Repo) DeleteUnverifiedAccountClaims(createdBefore time.Time) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`DELETE FROM account_claims WHERE verified_by='' AND created_at<?`, createdBefore)
+	return err
+}
+
+// Deletes the account's unverified claims, except for the newest few
+func (repo *Repo) DeleteOlderUnverifiedAccountClaims(accountId int, keep int) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`DELETE FROM account_claims WHERE account_id=? AND verified_by='' AND token NOT IN
+		(SELECT token FROM account_claims WHERE account_id=? AND verified_by='' ORDER BY created_at DESC LIMIT ?)`,
+		accountId, accountId, keep)
+	return err
+}
+
+func (repo *Repo) SetAccountClaimedProfile(accountId int, name, bio, publisherAccount string) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`UPDATE accounts SET claimed_name=?, claimed_bio=?, publisher_account=? WHERE id=?`,
+		name, bio, publisherAccount, accountId)
+	return err
+}
+
+// Deletes a post and the toot we made from it, and remembers that the toot existed.
+// Returns the deleted toot's status ID, or empty string if there was no toot.
+func (repo *Repo) DeletePostAndToot(accountId int, postGuidHash int64) (statusId string, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	row := repo.db.QueryRow(`SELECT status_id FROM toots WHERE account_id=? AND post_guid_hash=?`,
+		accountId, postGuidHash)
+	if err = row.Scan(&statusId); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if _, err = repo.db.Exec(`DELETE FROM feed_posts WHERE account_id=? AND post_guid_hash=?`,
+		accountId, postGuidHash); err != nil {
+		return "", err
+	}
+	if statusId == "" {
+		return "", nil
+	}
+	if _, err = repo.db.Exec(`INSERT OR IGNORE INTO deleted_toots (status_id, account_id, deleted_at)
+		VALUES (?, ?, ?)`, statusId, accountId, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	if _, err = repo.db.Exec(`DELETE FROM toots WHERE account_id=? AND post_guid_hash=?`,
+		accountId, postGuidHash); err != nil {
+		return "", err
+	}
+	return statusId, nil
+}
+
+func (repo *Repo) GetDeletedToot(statusId string) (deletedAt time.Time, deleted bool, err error) {
+
+	repo.muDb.RLock()
+	defer repo.muDb.RUnlock()
+
+	row := repo.db.QueryRow(`SELECT deleted_at FROM deleted_toots WHERE status_id=?`, statusId)
+	if err = row.Scan(&deletedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	return deletedAt, true, nil
+}
+
+func (repo *Repo) MarkActivityHandled(id string, when time.Time) (alreadyHandled bool, err error) {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	alreadyHandled = false
+	err = nil
+
+	_, err = repo.db.Exec(`INSERT INTO handled_activities VALUES (?, ?)`, id, when)
+
+	if err == nil {
+		return
+	}
+
+	// Duplicate key: activity was handled before
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		// Duplicate key: account with this handle already exists
+		if sqliteErr.Code == 19 && sqliteErr.ExtendedCode == 2067 {
+			alreadyHandled = true
+			err = nil
+			return
+		}
+	}
+
+	return
+}
+
+func (repo *Repo) DeleteHandledActivities(before time.Time) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`DELETE FROM handled_activities WHERE handled_at<?`, before)
+	return err
+}
+
+func (repo *Repo) DeleteDeletedToots(before time.Time) error {
+
+	repo.muDb.Lock()
+	defer repo.muDb.Unlock()
+
+	_, err := repo.db.Exec(`DELETE FROM deleted_toots WHERE deleted_at<?`, before)
 	return err
 }
@@ -0,0 +1,2 @@
+ALTER TABLE accounts ADD COLUMN feed_etag TEXT NOT NULL DEFAULT ('');
+ALTER TABLE accounts ADD COLUMN feed_last_modified TEXT NOT NULL DEFAULT ('');
@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN poll_status INTEGER NOT NULL DEFAULT 0;
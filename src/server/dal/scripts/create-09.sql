@@ -0,0 +1,2 @@
+ALTER TABLE accounts ADD COLUMN check_failures INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accounts ADD COLUMN last_check_error TEXT NOT NULL DEFAULT ('');
@@ -0,0 +1,5 @@
+ALTER TABLE accounts ADD COLUMN websub_hub TEXT NOT NULL DEFAULT ('');
+ALTER TABLE accounts ADD COLUMN websub_topic TEXT NOT NULL DEFAULT ('');
+ALTER TABLE accounts ADD COLUMN websub_secret TEXT NOT NULL DEFAULT ('');
+ALTER TABLE accounts ADD COLUMN websub_requested_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
+ALTER TABLE accounts ADD COLUMN websub_expires DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
@@ -0,0 +1,3 @@
+ALTER TABLE feed_posts ADD COLUMN content_hash INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE toot_queue ADD COLUMN activity_type TEXT NOT NULL DEFAULT ('Create');
+ALTER TABLE toot_queue ADD COLUMN updated_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
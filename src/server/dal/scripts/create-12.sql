@@ -0,0 +1,7 @@
+CREATE TABLE deleted_toots
+(
+    status_id  TEXT     NOT NULL,
+    account_id INTEGER  NOT NULL,
+    deleted_at DATETIME NOT NULL,
+    PRIMARY KEY (status_id)
+);
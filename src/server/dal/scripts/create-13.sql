@@ -0,0 +1,13 @@
+ALTER TABLE toot_queue ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE toot_queue ADD COLUMN next_attempt_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
+CREATE TABLE inbox_health
+(
+    inbox         TEXT     NOT NULL,
+    failures      INTEGER  NOT NULL DEFAULT 0,
+    failing_since DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00',
+    last_failure  DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00',
+    last_success  DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00',
+    last_error    TEXT     NOT NULL DEFAULT '',
+    suspended     INTEGER  NOT NULL DEFAULT 0,
+    PRIMARY KEY (inbox)
+);
@@ -0,0 +1,6 @@
+ALTER TABLE toot_queue ADD COLUMN to_host TEXT NOT NULL DEFAULT '';
+UPDATE toot_queue SET to_host = lower(substr(
+    substr(to_inbox, instr(to_inbox, '://') + 3), 1,
+    instr(substr(to_inbox, instr(to_inbox, '://') + 3) || '/', '/') - 1))
+WHERE instr(to_inbox, '://') > 0;
+CREATE INDEX idx_toot_queue_to_host ON toot_queue(to_host, id);
@@ -0,0 +1 @@
+ALTER TABLE feed_posts ADD COLUMN guid TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+CREATE TABLE blocked_domains
+(
+    domain     TEXT     NOT NULL,
+    created_at DATETIME NOT NULL,
+    comment    TEXT     NOT NULL DEFAULT '',
+    PRIMARY KEY (domain)
+);
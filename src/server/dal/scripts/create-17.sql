@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN optout_checked_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
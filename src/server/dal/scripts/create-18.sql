@@ -0,0 +1,15 @@
+CREATE TABLE account_claims
+(
+    token                 TEXT     NOT NULL,
+    account_id            INTEGER  NOT NULL,
+    secret_hash           TEXT     NOT NULL,
+    created_at            DATETIME NOT NULL,
+    verified_at           DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00',
+    verified_by           TEXT     NOT NULL DEFAULT '',
+    deletion_requested_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00',
+    PRIMARY KEY (token)
+);
+CREATE INDEX idx_account_claims_account_id ON account_claims (account_id);
+ALTER TABLE accounts ADD COLUMN claimed_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN claimed_bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN publisher_account TEXT NOT NULL DEFAULT '';
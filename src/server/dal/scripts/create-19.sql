@@ -0,0 +1,2 @@
+ALTER TABLE accounts ADD COLUMN header_image_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE accounts ADD COLUMN images_checked_at DATETIME NOT NULL DEFAULT '1900-01-01 00:00:00';
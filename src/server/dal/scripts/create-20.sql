@@ -0,0 +1,2 @@
+ALTER TABLE toots ADD COLUMN attachments TEXT NOT NULL DEFAULT '';
+ALTER TABLE toot_queue ADD COLUMN attachments TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE accounts ADD COLUMN no_hashtags INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE feed_posts ADD COLUMN hashtags TEXT NOT NULL DEFAULT '';
+ALTER TABLE toots ADD COLUMN hashtags TEXT NOT NULL DEFAULT '';
+ALTER TABLE toot_queue ADD COLUMN hashtags TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+CREATE INDEX idx_deleted_toots_deleted_at ON deleted_toots (deleted_at);
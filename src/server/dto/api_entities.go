@@ -9,8 +9,64 @@ type Feed struct {
 	FeedName        string    `json:"feed_name"`
 	FeedSummary     string    `json:"feed_summary"`
 	ProfileImageUrl string    `json:"profile_image_url"`
+	HeaderImageUrl  string    `json:"header_image_url"`
 	SiteUrl         string    `json:"site_url"`
 	FeedUrl         string    `json:"feed_url"`
 	FeedLastUpdated time.Time `json:"feed_last_updated"`
 	NextCheckDue    time.Time `json:"next_check_due"`
+	PollStatus      string    `json:"poll_status"`
+	CheckFailures   int       `json:"check_failures"`
+	LastCheckError  string    `json:"last_check_error,omitempty"`
+	Hashtags        bool      `json:"hashtags"`
+	FloodLimit      int       `json:"flood_limit"`
+}
+
+type AccountHashtags struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Limit is the most new posts a check may toot one by one; 0 uses the configured limit, -1 means no limit
+type AccountFloodLimit struct {
+	Limit int `json:"limit"`
+}
+
+type DomainBlock struct {
+	Domain    string    `json:"domain"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Comment   string    `json:"comment"`
+}
+
+type BlockedFeed struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason,omitempty"`
+	Purge   bool   `json:"purge,omitempty"`
+}
+
+type Follower struct {
+	UserUrl string `json:"user_url"`
+	Handle  string `json:"handle,omitempty"`
+	Host    string `json:"host,omitempty"`
+}
+
+type ClaimStarted struct {
+	Token        string            `json:"token"`
+	Secret       string            `json:"secret"`
+	Instructions map[string]string `json:"instructions"`
+}
+
+type ClaimVerified struct {
+	VerifiedBy string `json:"verified_by"`
+}
+
+type ClaimedProfile struct {
+	Name             string `json:"name"`
+	Bio              string `json:"bio"`
+	PublisherAccount string `json:"publisher_account"`
+}
+
+type AccountClaim struct {
+	Handle              string     `json:"handle"`
+	VerifiedAt          time.Time  `json:"verified_at"`
+	VerifiedBy          string     `json:"verified_by"`
+	DeletionRequestedAt *time.Time `json:"deletion_requested_at,omitempty"`
 }
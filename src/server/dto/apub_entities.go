@@ -24,6 +24,17 @@ type UserInfo struct {
 	Attachments       []Attachment  `json:"attachment"`
 	Icon              Image         `json:"icon"`
 	Image             Image         `json:"image"`
+	RawAlsoKnownAs    any           `json:"alsoKnownAs,omitempty"`
+	MovedTo           string        `json:"movedTo,omitempty"`
+}
+
+// Returns the other identities of the user, which can be given as a single string or an array.
+func (x *UserInfo) GetAlsoKnownAs() []string {
+	res, err := getRecipient(x.RawAlsoKnownAs)
+	if err != nil {
+		return nil
+	}
+	return res
 }
 
 type Attachment struct {
@@ -56,6 +67,25 @@ type OrderedListSummary struct {
 	Last       *string `json:"last,omitempty"`
 }
 
+type OrderedCollection struct {
+	Context      any    `json:"@context"`
+	Id           string `json:"id"`
+	Type         string `json:"type"`
+	TotalItems   uint   `json:"totalItems"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any     `json:"@context"`
+	Id           string  `json:"id"`
+	Type         string  `json:"type"`
+	TotalItems   uint    `json:"totalItems"`
+	PartOf       string  `json:"partOf"`
+	OrderedItems []any   `json:"orderedItems"`
+	Next         *string `json:"next,omitempty"`
+	Prev         *string `json:"prev,omitempty"`
+}
+
 func getRecipient(raw any) ([]string, error) {
 	var res []string
 	if raw == nil {
@@ -86,6 +116,7 @@ type ActivityInBase struct {
 	Cc     []string `json:"-"`
 	RawCc  any      `json:"cc"`
 	Object any      `json:"object"`
+	Target any      `json:"target,omitempty"`
 }
 
 func (x *ActivityInBase) UnmarshalJSON(data []byte) error {
@@ -132,29 +163,41 @@ func (x *ActivityIn[T]) UnmarshalJSON(data []byte) error {
 }
 
 type ActivityOut struct {
-	Context any       `json:"@context"`
-	Id      string    `json:"id"`
-	Type    string    `json:"type"`
-	Actor   string    `json:"actor"`
-	To      *[]string `json:"to,omitempty"`
-	Cc      *[]string `json:"cc,omitempty"`
-	Object  any       `json:"object,omitempty"`
+	Context   any       `json:"@context,omitempty"`
+	Id        string    `json:"id"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	Published string    `json:"published,omitempty"`
+	To        *[]string `json:"to,omitempty"`
+	Cc        *[]string `json:"cc,omitempty"`
+	Object    any       `json:"object,omitempty"`
+}
+
+type Tombstone struct {
+	Context    any    `json:"@context,omitempty"`
+	Id         string `json:"id"`
+	Type       string `json:"type"`
+	FormerType string `json:"formerType,omitempty"`
+	Deleted    string `json:"deleted,omitempty"`
 }
 
 type Note struct {
-	Id           string   `json:"id"`
-	Type         string   `json:"type"`
-	Published    string   `json:"published"`
-	Summary      *string  `json:"summary"`
-	AttributedTo string   `json:"attributedTo"`
-	InReplyTo    *string  `json:"inReplyTo"`
-	To           []string `json:"-"`
-	RawTo        any      `json:"to"`
-	Cc           []string `json:"-"`
-	RawCc        any      `json:"cc"`
-	Content      string   `json:"content"`
-	Tag          *[]Tag   `json:"-"`
-	RawTag       any      `json:"tag,omitempty"`
+	Id            string     `json:"id"`
+	Type          string     `json:"type"`
+	Published     string     `json:"published"`
+	Updated       *string    `json:"updated,omitempty"`
+	Summary       *string    `json:"summary"`
+	AttributedTo  string     `json:"attributedTo"`
+	InReplyTo     *string    `json:"inReplyTo"`
+	To            []string   `json:"-"`
+	RawTo         any        `json:"to"`
+	Cc            []string   `json:"-"`
+	RawCc         any        `json:"cc"`
+	Content       string     `json:"content"`
+	Tag           *[]Tag     `json:"-"`
+	RawTag        any        `json:"tag,omitempty"`
+	Attachment    []Document `json:"-"`
+	RawAttachment any        `json:"attachment,omitempty"` // Not parsed: we ignore attachments of notes we receive
 }
 
 func (x *Note) UnmarshalJSON(data []byte) error {
@@ -182,9 +225,21 @@ func (x *Note) MarshalJSON() ([]byte, error) {
 	y.RawTo = y.To
 	y.RawCc = y.Cc
 	y.RawTag = y.Tag
+	y.RawAttachment = nil
+	if len(y.Attachment) != 0 {
+		y.RawAttachment = y.Attachment
+	}
 	return json.Marshal(y)
 }
 
+// Media attached to a note: Image, Audio or Document
+type Document struct {
+	Type      string `json:"type"`
+	MediaType string `json:"mediaType,omitempty"`
+	Url       string `json:"url"`
+	Name      string `json:"name,omitempty"`
+}
+
 type Tag struct {
 	Type string `json:"type"`
 	Href string `json:"href"`
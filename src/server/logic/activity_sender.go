@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/rsa"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/go-fed/httpsig"
 	"io"
@@ -19,10 +18,23 @@ import (
 
 type IActivitySender interface {
 	Send(privKey *rsa.PrivateKey, sendingUser, inboxUrl string, activity *dto.ActivityOut) error
+	// Like Send, but adds extra headers to the request. The extra headers are also signed.
+	SendWithHeaders(privKey *rsa.PrivateKey, sendingUser, inboxUrl string, activity *dto.ActivityOut,
+		headers map[string]string) error
 }
 
 const activityTimeoutSec = 10
 
+// Returned by Send if the receiving server responds with an error status.
+type DeliveryStatusError struct {
+	StatusCode int
+	msg        string
+}
+
+func (e *DeliveryStatusError) Error() string {
+	return e.msg
+}
+
 type activitySender struct {
 	cfg       *shared.Config
 	logger    shared.ILogger
@@ -45,6 +57,16 @@ func (sender *activitySender) Send(
 	inboxUrl string,
 	activity *dto.ActivityOut,
 ) error {
+	return sender.SendWithHeaders(privKey, sendingUser, inboxUrl, activity, nil)
+}
+
+func (sender *activitySender) SendWithHeaders(
+	privKey *rsa.PrivateKey,
+	sendingUser,
+	inboxUrl string,
+	activity *dto.ActivityOut,
+	headers map[string]string,
+) error {
 
 	obs := sender.metrics.StartApubRequestOut("post")
 	defer obs.Finish()
@@ -67,11 +89,16 @@ func (sender *activitySender) Send(
 	req.Header.Set("Content-Type", "application/activity+json")
 	req.Header.Set("host", host)
 	req.Header.Set("date", dateStr)
+	signedHeaders := []string{httpsig.RequestTarget, "Host", "date", "digest"}
+	for name, val := range headers {
+		req.Header.Set(name, val)
+		signedHeaders = append(signedHeaders, name)
+	}
 
 	signer, _, err := httpsig.NewSigner(
 		[]httpsig.Algorithm{httpsig.RSA_SHA256},
 		httpsig.DigestSha256,
-		[]string{httpsig.RequestTarget, "Host", "date", "digest"},
+		signedHeaders,
 		httpsig.Signature,
 		0)
 	if err != nil {
@@ -100,7 +127,7 @@ func (sender *activitySender) Send(
 	if resp.StatusCode >= 300 {
 		msg := fmt.Sprintf("got status %s: response: %s", resp.Status, respBody)
 		sender.logger.Warnf("Activity POST failed to %s: %s", inboxUrl, msg)
-		return errors.New(msg)
+		return &DeliveryStatusError{resp.StatusCode, msg}
 	}
 
 	return nil
@@ -2,43 +2,487 @@ package logic
 
 import (
 	"bufio"
+	"fmt"
 	"os"
+	"regexp"
+	"rss_parrot/dal"
 	"rss_parrot/shared"
 	"strings"
+	"sync"
+	"time"
 )
 
 //go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_blocked_feeds.go -package mocks rss_parrot/logic IBlockedFeeds
 
+const blockedFeedsReloadSec = 10
+const blockedFeedsAcctPageSize = 100
+
+var reBlockedRegexLine = regexp.MustCompile(`^(/.+?/)(?:\s+#(.*))?$`)
+
+// Feeds we refuse to parrot. Kept in memory, and reloaded when the blocked feeds file changes.
+// Each line of the file holds one entry, optionally followed by " # reason". Lines starting with # are comments.
+//
+//	example.com            example.com (or www.example.com) and every URL on it
+//	*.example.com          example.com and all of its subdomains
+//	example.com/blog/      URLs starting with this path
+//	/^example\.com/\d+$/   URLs without the scheme matching this regex
 type IBlockedFeeds interface {
-	IsBlocked(feedUrl string) (bool, error)
+	IsBlocked(feedUrl string) (blocked bool, reason string)
+	GetAll() []*BlockedFeed
+	Block(pattern, reason string, purge bool) (isNew bool, stoppedAccounts []string, err error)
+	Unblock(pattern string) (removed bool, err error)
 }
 
+type BlockedFeed struct {
+	Pattern string
+	Reason  string
+	kind    blockedFeedKind
+	value   string
+	rx      *regexp.Regexp
+}
+
+type blockedFeedKind int
+
+const (
+	bfkDomain blockedFeedKind = iota
+	bfkSubdomains
+	bfkPathPrefix
+	bfkRegex
+)
+
 type blockedFeeds struct {
-	cfg *shared.Config
+	cfg       *shared.Config
+	logger    shared.ILogger
+	repo      dal.IRepo
+	messenger IMessenger
+	media     IMediaCache
+	muFile    sync.Mutex
+	modTime   time.Time
+	mu        sync.RWMutex
+	entries   []*BlockedFeed
+}
+
+func NewBlockedFeeds(
+	cfg *shared.Config,
+	logger shared.ILogger,
+	repo dal.IRepo,
+	messenger IMessenger,
+	media IMediaCache,
+) IBlockedFeeds {
+	bf := blockedFeeds{
+		cfg:       cfg,
+		logger:    logger,
+		repo:      repo,
+		messenger: messenger,
+		media:     media,
+	}
+	if cfg.BlockedFeedsFile != "" {
+		if _, err := bf.reload(); err != nil {
+			logger.Errorf("Failed to load blocked feeds: %v", err)
+		}
+		// The file may have been edited while we were down
+		if _, err := bf.stopMatchingAccounts(bf.GetAll(), false); err != nil {
+			logger.Errorf("Failed to stop accounts of blocked feeds: %v", err)
+		}
+		go bf.watchFile()
+	}
+	return &bf
 }
 
-func NewBlockedFeeds(cfg *shared.Config) IBlockedFeeds {
-	return &blockedFeeds{cfg}
+// Parses one line of the blocked feeds file. Returns nil for empty and comment lines.
+func parseBlockedFeed(line string) (*BlockedFeed, error) {
+
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	pattern, reason := line, ""
+	if groups := reBlockedRegexLine.FindStringSubmatch(line); groups != nil {
+		// Regex may contain " #" itself: reason only comes after the closing slash
+		pattern, reason = groups[1], groups[2]
+	} else if ix := strings.Index(line, " #"); ix != -1 {
+		pattern, reason = line[:ix], line[ix+2:]
+	}
+	return makeBlockedFeed(pattern, reason)
 }
 
-func (bf *blockedFeeds) IsBlocked(feedUrl string) (bool, error) {
+func makeBlockedFeed(pattern, reason string) (*BlockedFeed, error) {
+
+	pattern = strings.TrimSpace(pattern)
+	reason = strings.TrimSpace(reason)
+	if strings.ContainsAny(reason, "\r\n") {
+		return nil, fmt.Errorf("reason must be a single line")
+	}
+	res := &BlockedFeed{Pattern: pattern, Reason: reason}
+
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		rx, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		res.kind = bfkRegex
+		res.rx = rx
+		return res, nil
+	}
+
+	value := normalizeBlockedUrl(pattern)
+	if value == "" || strings.ContainsAny(value, " \t") {
+		return nil, fmt.Errorf("invalid pattern: %s", pattern)
+	}
+	if strings.HasPrefix(value, "*.") {
+		value = strings.TrimPrefix(value, "*.")
+		if value == "" || strings.Contains(value, "/") {
+			return nil, fmt.Errorf("invalid wildcard domain: %s", pattern)
+		}
+		res.kind = bfkSubdomains
+	} else if strings.Contains(value, "/") {
+		res.kind = bfkPathPrefix
+	} else {
+		res.kind = bfkDomain
+	}
+	res.value = value
+	return res, nil
+}
+
+// Lowercase URL with scheme, port and leading www. removed.
+func normalizeBlockedUrl(urlStr string) string {
+	urlStr = strings.ToLower(strings.TrimSpace(urlStr))
+	urlStr = strings.TrimPrefix(urlStr, "https://")
+	urlStr = strings.TrimPrefix(urlStr, "http://")
+	host, rest := urlStr, ""
+	if ix := strings.IndexAny(urlStr, "/?#"); ix != -1 {
+		host, rest = urlStr[:ix], urlStr[ix:]
+	}
+	if ix := strings.IndexByte(host, ':'); ix != -1 {
+		host = host[:ix]
+	}
+	host = strings.TrimPrefix(host, "www.")
+	return host + rest
+}
+
+func (entry *BlockedFeed) matches(feedUrl string) bool {
+
+	if entry.kind == bfkRegex {
+		noScheme := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(feedUrl), "https://"), "http://")
+		return entry.rx.MatchString(noScheme)
+	}
+
+	norm := normalizeBlockedUrl(feedUrl)
+	host := norm
+	if ix := strings.IndexAny(norm, "/?#"); ix != -1 {
+		host = norm[:ix]
+	}
+	switch entry.kind {
+	case bfkDomain:
+		return host == entry.value
+	case bfkSubdomains:
+		return host == entry.value || strings.HasSuffix(host, "."+entry.value)
+	case bfkPathPrefix:
+		prefix := strings.TrimSuffix(entry.value, "/")
+		if !strings.HasPrefix(norm, prefix) {
+			return false
+		}
+		// Prefix must end on a path boundary: example.com/blog does not block example.com/blogroll
+		rest := norm[len(prefix):]
+		return rest == "" || strings.ContainsAny(rest[:1], "/?#")
+	}
+	return false
+}
+
+func (bf *blockedFeeds) IsBlocked(feedUrl string) (blocked bool, reason string) {
+
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	for _, entry := range bf.entries {
+		if entry.matches(feedUrl) {
+			return true, entry.Reason
+		}
+	}
+	return false, ""
+}
+
+func (bf *blockedFeeds) GetAll() []*BlockedFeed {
+
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	res := make([]*BlockedFeed, len(bf.entries))
+	copy(res, bf.entries)
+	return res
+}
+
+// Checks the file for changes every few seconds, and stops existing accounts that match newly added entries
+func (bf *blockedFeeds) watchFile() {
+	for {
+		time.Sleep(blockedFeedsReloadSec * time.Second)
+		added, err := bf.reload()
+		if err != nil {
+			bf.logger.Errorf("Failed to reload blocked feeds: %v", err)
+			continue
+		}
+		if len(added) == 0 {
+			continue
+		}
+		if _, err = bf.stopMatchingAccounts(added, false); err != nil {
+			bf.logger.Errorf("Failed to stop accounts of newly blocked feeds: %v", err)
+		}
+	}
+}
+
+// Re-reads the file if it changed since we last read it. Returns the entries that were not there before.
+func (bf *blockedFeeds) reload() (added []*BlockedFeed, err error) {
+
+	bf.muFile.Lock()
+	defer bf.muFile.Unlock()
+
+	fi, err := os.Stat(bf.cfg.BlockedFeedsFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No file yet is the same as no blocked feeds; Block creates it
+			return nil, nil
+		}
+		return nil, err
+	}
+	if fi.ModTime().Equal(bf.modTime) {
+		return nil, nil
+	}
+
+	var entries []*BlockedFeed
+	if entries, err = bf.readFile(); err != nil {
+		return nil, err
+	}
+
+	bf.mu.Lock()
+	oldPatterns := make(map[string]bool, len(bf.entries))
+	for _, entry := range bf.entries {
+		oldPatterns[entry.Pattern] = true
+	}
+	bf.entries = entries
+	bf.modTime = fi.ModTime()
+	bf.mu.Unlock()
+
+	bf.logger.Infof("Loaded %d blocked feed entries", len(entries))
+	for _, entry := range entries {
+		if !oldPatterns[entry.Pattern] {
+			added = append(added, entry)
+		}
+	}
+	return added, nil
+}
+
+func (bf *blockedFeeds) readFile() ([]*BlockedFeed, error) {
 
-	feedUrl = strings.ToLower(feedUrl)
-	feedUrl = strings.TrimPrefix(feedUrl, "https://")
-	feedUrl = strings.TrimPrefix(feedUrl, "http://")
 	readFile, err := os.Open(bf.cfg.BlockedFeedsFile)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer readFile.Close()
 	fileScanner := bufio.NewScanner(readFile)
 	fileScanner.Split(bufio.ScanLines)
 
-	for fileScanner.Scan() {
-		line := fileScanner.Text()
-		if feedUrl == line {
-			return true, nil
+	var entries []*BlockedFeed
+	for lineNum := 1; fileScanner.Scan(); lineNum++ {
+		entry, err := parseBlockedFeed(fileScanner.Text())
+		if err != nil {
+			// One bad line shouldn't unblock everything else
+			bf.logger.Warnf("Ignoring line %d in blocked feeds file: %v", lineNum, err)
+			continue
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, fileScanner.Err()
+}
+
+// Rewrites the file with lines changed by the callback, and records the new modification time
+// so we don't pick up our own change as a reload.
+func (bf *blockedFeeds) rewriteFile(update func(lines []string) []string) error {
+
+	var lines []string
+	content, err := os.ReadFile(bf.cfg.BlockedFeedsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(content) != 0 {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	}
+	lines = update(lines)
+	content = []byte(strings.Join(lines, "\n") + "\n")
+	if err = os.WriteFile(bf.cfg.BlockedFeedsFile, content, 0644); err != nil {
+		return err
+	}
+	fi, err := os.Stat(bf.cfg.BlockedFeedsFile)
+	if err != nil {
+		return err
+	}
+	bf.modTime = fi.ModTime()
+	return nil
+}
+
+// Adds the entry to the blocked feeds file and stops accounts whose feed it blocks.
+// If purge is true, the stopped accounts' posts and toots are deleted too.
+func (bf *blockedFeeds) Block(pattern, reason string, purge bool) (isNew bool, stoppedAccounts []string, err error) {
+
+	if bf.cfg.BlockedFeedsFile == "" {
+		return false, nil, fmt.Errorf("no blocked feeds file configured")
+	}
+	var entry *BlockedFeed
+	if entry, err = makeBlockedFeed(pattern, reason); err != nil {
+		return false, nil, err
+	}
+
+	bf.muFile.Lock()
+	bf.mu.RLock()
+	isNew = true
+	for _, existing := range bf.entries {
+		if existing.Pattern == entry.Pattern {
+			isNew = false
+			break
+		}
+	}
+	bf.mu.RUnlock()
+	if isNew {
+		line := entry.Pattern
+		if entry.Reason != "" {
+			line += " # " + entry.Reason
+		}
+		err = bf.rewriteFile(func(lines []string) []string { return append(lines, line) })
+		if err == nil {
+			bf.mu.Lock()
+			bf.entries = append(bf.entries, entry)
+			bf.mu.Unlock()
+		}
+	}
+	bf.muFile.Unlock()
+	if err != nil {
+		return false, nil, err
+	}
+
+	if isNew {
+		bf.logger.Infof("Blocked feeds matching %s", entry.Pattern)
+	}
+	// Stop accounts even if the entry was already there: the caller may be asking for a purge this time
+	if stoppedAccounts, err = bf.stopMatchingAccounts([]*BlockedFeed{entry}, purge); err != nil {
+		return isNew, stoppedAccounts, err
+	}
+	return isNew, stoppedAccounts, nil
+}
+
+func (bf *blockedFeeds) Unblock(pattern string) (removed bool, err error) {
+
+	pattern = strings.TrimSpace(pattern)
+	bf.muFile.Lock()
+	defer bf.muFile.Unlock()
+
+	bf.mu.RLock()
+	for _, entry := range bf.entries {
+		if entry.Pattern == pattern {
+			removed = true
+			break
+		}
+	}
+	bf.mu.RUnlock()
+	if !removed {
+		return false, nil
+	}
+
+	err = bf.rewriteFile(func(lines []string) []string {
+		var kept []string
+		for _, line := range lines {
+			if entry, _ := parseBlockedFeed(line); entry == nil || entry.Pattern != pattern {
+				kept = append(kept, line)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return false, err
+	}
+
+	bf.mu.Lock()
+	var entries []*BlockedFeed
+	for _, entry := range bf.entries {
+		if entry.Pattern != pattern {
+			entries = append(entries, entry)
+		}
+	}
+	bf.entries = entries
+	bf.mu.Unlock()
+
+	// Accounts stopped by this entry stay stopped until resumed through the API
+	bf.logger.Infof("Unblocked feeds matching %s", pattern)
+	return true, nil
+}
+
+// Sets accounts whose feed or site matches any of the entries to blocked, optionally purging their content.
+func (bf *blockedFeeds) stopMatchingAccounts(entries []*BlockedFeed, purge bool) (stopped []string, err error) {
+
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	isMatch := func(acct *dal.Account) bool {
+		for _, entry := range entries {
+			if entry.matches(acct.FeedUrl) || acct.SiteUrl != "" && entry.matches(acct.SiteUrl) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var matching []*dal.Account
+	for offset := 0; ; offset += blockedFeedsAcctPageSize {
+		accts, total, err := bf.repo.GetAccountsPage(offset, blockedFeedsAcctPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, acct := range accts {
+			if acct.Handle != bf.cfg.Birb.User && isMatch(acct) {
+				matching = append(matching, acct)
+			}
+		}
+		if len(accts) == 0 || offset+len(accts) >= total {
+			break
+		}
+	}
+
+	stopped = make([]string, 0, len(matching))
+	for _, acct := range matching {
+		if acct.PollStatus != dal.PollBlocked {
+			if err = bf.repo.SetAccountPollStatus(acct.Id, dal.PollBlocked); err != nil {
+				return stopped, err
+			}
+			bf.logger.Infof("Stopped checking blocked feed: %s: %s", acct.Handle, acct.FeedUrl)
+		}
+		stopped = append(stopped, acct.Handle)
+		if purge {
+			if err = bf.purgeAccount(acct); err != nil {
+				return stopped, err
+			}
+		}
+	}
+	return stopped, nil
+}
+
+func (bf *blockedFeeds) purgeAccount(acct *dal.Account) error {
+
+	purgedStatusIds, err := bf.repo.PurgePostsAndToots(acct.Id, time.Now().UTC().Add(time.Hour))
+	if err != nil {
+		return err
+	}
+	bf.logger.Infof("Purged %d toots of blocked feed: %s", len(purgedStatusIds), acct.Handle)
+	if err = bf.media.PruneThumbnails(acct.Handle); err != nil {
+		bf.logger.Warnf("Failed to remove thumbnails of purged toots: %s: %v", acct.Handle, err)
+	}
+	if !bf.cfg.PropagateDeletes {
+		return nil
+	}
+	for _, statusId := range purgedStatusIds {
+		if err = bf.messenger.EnqueueDelete(acct.Handle, statusId); err != nil {
+			return err
 		}
 	}
-	return false, nil
+	return nil
 }
@@ -0,0 +1,29 @@
+package logic
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+//go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_dns_resolver.go -package mocks rss_parrot/logic IDnsResolver
+
+const dnsLookupTimeoutSec = 10
+
+type IDnsResolver interface {
+	LookupTXT(name string) ([]string, error)
+}
+
+type dnsResolver struct {
+	resolver *net.Resolver
+}
+
+func NewDnsResolver() IDnsResolver {
+	return &dnsResolver{net.DefaultResolver}
+}
+
+func (dr *dnsResolver) LookupTXT(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsLookupTimeoutSec*time.Second)
+	defer cancel()
+	return dr.resolver.LookupTXT(ctx, name)
+}
@@ -0,0 +1,144 @@
+package logic
+
+import (
+	"fmt"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_domain_blocks.go -package mocks rss_parrot/logic IDomainBlocks
+
+// Remote instances we refuse to federate with. Checked on every inbox request, so blocks are kept in memory.
+type IDomainBlocks interface {
+	IsBlocked(userUrl string) bool
+	GetAll() ([]*dal.BlockedDomain, error)
+	Block(domain, comment string) (isNew bool, removedFollowers int, err error)
+	Unblock(domain string) (removed bool, err error)
+}
+
+type domainBlocks struct {
+	logger  shared.ILogger
+	repo    dal.IRepo
+	metrics IMetrics
+	mu      sync.RWMutex
+	domains map[string]bool
+}
+
+func NewDomainBlocks(logger shared.ILogger, repo dal.IRepo, metrics IMetrics) IDomainBlocks {
+	db := domainBlocks{
+		logger:  logger,
+		repo:    repo,
+		metrics: metrics,
+		domains: make(map[string]bool),
+	}
+	if err := db.load(); err != nil {
+		logger.Errorf("Failed to load blocked domains: %v", err)
+	}
+	return &db
+}
+
+func (db *domainBlocks) load() error {
+	blocks, err := db.repo.GetBlockedDomains()
+	if err != nil {
+		return err
+	}
+	domains := make(map[string]bool, len(blocks))
+	for _, bd := range blocks {
+		domains[bd.Domain] = true
+	}
+	db.mu.Lock()
+	db.domains = domains
+	db.mu.Unlock()
+	return nil
+}
+
+// Returns the lowercase domain with any scheme, path and port removed.
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "https://")
+	domain = strings.TrimPrefix(domain, "http://")
+	if ix := strings.IndexAny(domain, "/:"); ix != -1 {
+		domain = domain[:ix]
+	}
+	return strings.Trim(domain, ".")
+}
+
+// True if the user is on a blocked domain, or one of its subdomains.
+func (db *domainBlocks) IsBlocked(userUrl string) bool {
+
+	host, err := shared.GetHostName(userUrl)
+	if err != nil || host == "" {
+		return false
+	}
+	host = strings.ToLower(host)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for {
+		if db.domains[host] {
+			return true
+		}
+		ix := strings.IndexByte(host, '.')
+		if ix == -1 {
+			return false
+		}
+		host = host[ix+1:]
+	}
+}
+
+func (db *domainBlocks) GetAll() ([]*dal.BlockedDomain, error) {
+	return db.repo.GetBlockedDomains()
+}
+
+// Blocks the domain and removes existing followers from it. Followers are removed even if the domain was
+// already blocked, in case they slipped in before.
+func (db *domainBlocks) Block(domain, comment string) (isNew bool, removedFollowers int, err error) {
+
+	domain = normalizeDomain(domain)
+	if domain == "" {
+		return false, 0, fmt.Errorf("invalid domain")
+	}
+	isNew, err = db.repo.AddBlockedDomain(&dal.BlockedDomain{
+		Domain:    domain,
+		CreatedAt: time.Now().UTC(),
+		Comment:   comment,
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	db.mu.Lock()
+	db.domains[domain] = true
+	db.mu.Unlock()
+
+	if removedFollowers, err = db.repo.RemoveFollowersOnDomain(domain); err != nil {
+		return isNew, 0, err
+	}
+	db.logger.Infof("Blocked domain %s; removed %d followers", domain, removedFollowers)
+	if removedFollowers != 0 {
+		if count, countErr := db.repo.GetFeedFollowerCount(); countErr != nil {
+			db.logger.Errorf("Error getting feed follower count: %v", countErr)
+		} else {
+			db.metrics.TotalFollowers(count)
+		}
+	}
+	return isNew, removedFollowers, nil
+}
+
+func (db *domainBlocks) Unblock(domain string) (removed bool, err error) {
+
+	domain = normalizeDomain(domain)
+	if removed, err = db.repo.RemoveBlockedDomain(domain); err != nil {
+		return false, err
+	}
+	db.mu.Lock()
+	delete(db.domains, domain)
+	db.mu.Unlock()
+	if removed {
+		db.logger.Infof("Unblocked domain %s", domain)
+	}
+	return removed, nil
+}
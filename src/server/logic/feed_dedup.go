@@ -0,0 +1,198 @@
+package logic
+
+import (
+	"github.com/mmcdole/gofeed"
+	"net/url"
+	"rss_parrot/dal"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Some feeds republish old items under a new identity: the GUID changes when a site is migrated,
+// links switch from http to https or gain tracking parameters, and every build bumps the dates.
+// Our GUID hash then looks new, so without these checks we would toot the same post again.
+
+const (
+	minSimilarTitleWords = 4   // Shorter titles are too generic to tell posts apart
+	minTitleSimilarity   = 0.9 // Share of distinct title words two posts must have in common
+	minRepublishSignals  = 2   // Out of same GUID, same link and similar title
+)
+
+// Query parameters that don't change what a link points to
+var trackingParamPrefixes = []string{"utm_", "fbclid", "gclid", "mc_cid", "mc_eid"}
+
+// Reduces a link to the parts that identify the page: no scheme, no www, no trailing slash,
+// no fragment, and no tracking parameters. Returns empty string if link can't be parsed.
+func normalizeLink(link string) string {
+	parsedUrl, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsedUrl.Host == "" {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsedUrl.Host), "www.")
+	path := strings.TrimRight(parsedUrl.EscapedPath(), "/")
+
+	query := parsedUrl.Query()
+	for key := range query {
+		for _, prefix := range trackingParamPrefixes {
+			if strings.HasPrefix(strings.ToLower(key), prefix) {
+				query.Del(key)
+				break
+			}
+		}
+	}
+	res := host + path
+	if len(query) != 0 {
+		// Encode sorts by key
+		res += "?" + query.Encode()
+	}
+	return res
+}
+
+// Returns the distinct lowercase words of a title.
+func getTitleWords(title string) []string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	sort.Strings(words)
+	res := words[:0]
+	for i, w := range words {
+		if i == 0 || w != words[i-1] {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+// Returns true if two titles have almost all of their words in common.
+func areTitlesSimilar(a, b string) bool {
+	wordsA, wordsB := getTitleWords(a), getTitleWords(b)
+	if len(wordsA) < minSimilarTitleWords || len(wordsB) < minSimilarTitleWords {
+		return false
+	}
+	inA := make(map[string]bool, len(wordsA))
+	for _, w := range wordsA {
+		inA[w] = true
+	}
+	common := 0
+	for _, w := range wordsB {
+		if inA[w] {
+			common++
+		}
+	}
+	union := len(wordsA) + len(wordsB) - common
+	return float64(common)/float64(union) >= minTitleSimilarity
+}
+
+// Finds the stored post that post is a republished copy of. Only stored posts whose own
+// GUID hash is gone from the feed are candidates: a post that is still in the feed is a different item.
+// A candidate must match on at least two of GUID, link and title: plenty of feeds give every item
+// the same link, and unrelated posts can share a title. Links that more than one item has are ignored.
+func findRepublishedPost(post *dal.FeedPost, rd *republishDetector) *dal.FeedPost {
+
+	normLink := normalizeLink(post.Link)
+	if rd.sharedLinks[normLink] {
+		normLink = ""
+	}
+
+	var res *dal.FeedPost
+	bestSignals := 0
+	for _, sp := range rd.stored {
+		if rd.inFeed[sp.PostGuidHash] {
+			continue
+		}
+		signals := 0
+		if post.Guid != "" && sp.Guid == post.Guid {
+			signals++
+		}
+		if normLink != "" && rd.storedLinks[sp.PostGuidHash] == normLink {
+			signals++
+		}
+		if areTitlesSimilar(sp.Title, post.Title) {
+			signals++
+		}
+		if signals >= minRepublishSignals && signals > bestSignals {
+			res, bestSignals = sp, signals
+		}
+	}
+	return res
+}
+
+// Posts we already have for an account, to recognize republished items during a single feed check.
+type republishDetector struct {
+	stored       []*dal.FeedPost
+	storedHashes map[int64]bool
+	storedLinks  map[int64]string // Normalized links of stored posts
+	sharedLinks  map[string]bool  // Normalized links that several stored posts, or several feed items, have
+	inFeed       map[int64]bool
+}
+
+// Expects podcast links to be already fixed in the feed's items, so their hashes match what we stored.
+func (ff *feedFollower) newRepublishDetector(accountId int, feed *gofeed.Feed) (*republishDetector, error) {
+
+	stored, err := ff.repo.GetFeedPostsSince(accountId, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	rd := &republishDetector{
+		stored:       stored,
+		storedHashes: make(map[int64]bool, len(stored)),
+		storedLinks:  make(map[int64]string, len(stored)),
+		sharedLinks:  make(map[string]bool),
+		inFeed:       make(map[int64]bool, len(feed.Items)),
+	}
+	storedLinkCounts := make(map[string]int, len(stored))
+	for _, sp := range stored {
+		rd.storedHashes[sp.PostGuidHash] = true
+		normLink := normalizeLink(sp.Link)
+		rd.storedLinks[sp.PostGuidHash] = normLink
+		storedLinkCounts[normLink]++
+	}
+	feedLinkCounts := make(map[string]int, len(feed.Items))
+	for _, itm := range feed.Items {
+		rd.inFeed[int64(getItemHash(itm))] = true
+		feedLinkCounts[normalizeLink(itm.Link)]++
+	}
+	for _, counts := range []map[string]int{storedLinkCounts, feedLinkCounts} {
+		for normLink, count := range counts {
+			if count > 1 {
+				rd.sharedLinks[normLink] = true
+			}
+		}
+	}
+	return rd, nil
+}
+
+// If itm is a post we already have under a different GUID hash, moves the stored post and its toot
+// to the new hash instead of treating itm as new. Edits to the title or description still go out as updates;
+// a link that merely looks different does not.
+func (ff *feedFollower) handleIfRepublished(
+	rd *republishDetector,
+	acct *dal.Account,
+	itm *gofeed.Item,
+	tootNew bool,
+) (republished bool, err error) {
+
+	accountId, accountHandle := acct.Id, acct.Handle
+	post := makeFeedPost(time.Time{}, itm)
+	if rd.storedHashes[post.PostGuidHash] {
+		return false, nil
+	}
+	original := findRepublishedPost(post, rd)
+	if original == nil {
+		return false, nil
+	}
+
+	ff.logger.Infof("Post republished under new identity: %s: %s (was %s)", accountHandle, itm.Link, original.Link)
+	ff.metrics.PostRepublished()
+	if err = ff.repo.RekeyFeedPost(accountId, original.PostGuidHash, post.PostGuidHash, post.Guid); err != nil {
+		return true, err
+	}
+	// Original can't be matched twice, and it is now known under the new hash
+	rd.inFeed[original.PostGuidHash] = true
+	rd.storedHashes[post.PostGuidHash] = true
+
+	sendUpdate := tootNew && (original.Title != post.Title || original.Description != post.Description)
+	return true, ff.updatePostIfChanged(acct, itm, sendUpdate)
+}
@@ -1,12 +1,15 @@
 package logic
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/mmcdole/gofeed"
 	"github.com/spaolacci/murmur3"
 	"html"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
@@ -15,6 +18,7 @@ import (
 	"rss_parrot/shared"
 	"rss_parrot/texts"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +27,7 @@ import (
 //go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_feed_follower.go -package mocks rss_parrot/logic IFeedFollower
 
 const feedCheckLoopIdleWakeSec = 60
+const feedCheckClaimMins = 60
 const postCountUpdateSecs = 60
 
 type FeedStatus int32
@@ -37,22 +42,52 @@ const (
 )
 
 const (
-	feedOrSiteTimeoutSec  = 10
-	allowedFuturePostDays = 2
+	feedOrSiteTimeoutSec   = 10
+	allowedFuturePostDays  = 2
+	maxFeedRedirects       = 10
+	maxRetryAfterHours     = 24 * 7
+	maxBackoffHours        = 24 * 7
+	maxCheckErrorLen       = 512
+	floodDigestMaxItems    = 20 // Digest toot lists this many posts, then just says how many more there are
+	floodDigestMaxTitleLen = 120
 )
 
 type IFeedFollower interface {
 	GetAccountForFeed(urlStr string) (acct *dal.Account, status FeedStatus, err error)
 	PurgeOldPosts(acct *dal.Account, minCount, minAgeDays int) error
+	VerifyWebSubIntent(user, mode, topic string, leaseSeconds int) (confirmed bool, err error)
+	HandleWebSubContent(user string, body []byte, signature string) error
 }
 
 type SiteInfo struct {
-	Url          string
-	ParrotHandle string
-	FeedUrl      string
-	LastUpdated  time.Time
-	Title        string
-	Description  string
+	Url             string
+	ParrotHandle    string
+	FeedUrl         string
+	LastUpdated     time.Time
+	Title           string
+	Description     string
+	WebSubHub       string
+	WebSubTopic     string
+	ProfileImageUrl string
+	HeaderImageUrl  string
+}
+
+// Outcome of fetching a feed, including what we need for the next conditional GET
+type fetchResult struct {
+	feed         *gofeed.Feed
+	notModified  bool // Server responded with 304; feed is nil
+	etag         string
+	lastModified string
+	movedTo      string    // Feed was reached through permanent redirects; this is the new URL
+	gone         bool      // Server responded with 410; feed is nil
+	retryAfter   time.Time // Server responded with 429 or 503 and told us when to come back; feed is nil
+	hub          string    // WebSub hub advertised by feed, if any
+	topic        string    // Feed's self URL for WebSub; falls back to the URL we fetched
+}
+
+type accountLock struct {
+	mu    sync.Mutex
+	users int
 }
 
 type feedFollower struct {
@@ -65,6 +100,12 @@ type feedFollower struct {
 	txt                  texts.ITexts
 	keyStore             IKeyStore
 	metrics              IMetrics
+	media                IMediaCache
+	hostGate             *hostGate
+	muAccountLocks       sync.Mutex
+	accountLocks         map[int]*accountLock // Keeps polls and WebSub pushes of an account from overlapping
+	muMissingPosts       sync.Mutex
+	missingPosts         map[int]map[int64]bool // Posts that were missing from each account's last full poll
 	lastCheckedPostCount time.Time
 	muPurgingOldPosts    sync.Mutex
 	isPurgingOldPosts    bool
@@ -82,6 +123,7 @@ func NewFeedFollower(
 	txt texts.ITexts,
 	keyStore IKeyStore,
 	metrics IMetrics,
+	media IMediaCache,
 ) IFeedFollower {
 
 	ff := feedFollower{
@@ -94,6 +136,10 @@ func NewFeedFollower(
 		txt:                 txt,
 		keyStore:            keyStore,
 		metrics:             metrics,
+		media:               media,
+		hostGate:            newHostGate(max(cfg.FeedChecksPerHost, 1)),
+		accountLocks:        make(map[int]*accountLock),
+		missingPosts:        make(map[int]map[int64]bool),
 		isPurgingUnfollowed: false,
 	}
 
@@ -220,14 +266,38 @@ func (ff *feedFollower) getSiteInfo(urlStr string) (*SiteInfo, *gofeed.Feed, err
 	if noQueryUrlStr, err = ff.trimQueryParamsStr(urlStr); err != nil {
 		return nil, nil, err
 	}
-	feed, err = ff.fetchParseFeed(noQueryUrlStr)
-	if err == nil {
+	// The URL, the site and the feed are often on the same host
+	robots := make(robotsCache)
+	if ff.isDisallowedByRobots(robots, noQueryUrlStr) {
+		return nil, nil, errOptedOut
+	}
+	var fr *fetchResult
+	fr, err = ff.fetchParseFeed(noQueryUrlStr, "", "")
+	if err == nil && fr.feed != nil {
+		feed = fr.feed
+		if isFeedOptedOut(feed) {
+			return nil, nil, errOptedOut
+		}
 		res.FeedUrl = noQueryUrlStr
 		res.LastUpdated = getLastUpdated(feed)
 		res.Title = feed.Title
 		res.Description = feed.Description
 		res.Url = feed.Link
 		res.ParrotHandle = shared.GetHandleFromUrl(res.Url)
+		res.WebSubHub, res.WebSubTopic = fr.hub, fr.topic
+		var doc *goquery.Document
+		if res.Url != "" {
+			if ff.isDisallowedByRobots(robots, res.Url) {
+				return nil, nil, errOptedOut
+			}
+			var docErr error
+			if doc, docErr = ff.fetchSiteDoc(res.Url); docErr != nil {
+				ff.logger.Warnf("Failed to check site for opt-out: %s: %v", res.Url, docErr)
+			} else if isDocOptedOut(doc) {
+				return nil, nil, errOptedOut
+			}
+		}
+		res.ProfileImageUrl, res.HeaderImageUrl = ff.discoverImages(res.Url, doc, res.FeedUrl, feed)
 		return &res, feed, nil
 	}
 
@@ -240,9 +310,14 @@ func (ff *feedFollower) getSiteInfo(urlStr string) (*SiteInfo, *gofeed.Feed, err
 	res.ParrotHandle = shared.GetHandleFromUrl(res.Url)
 
 	// Get the page
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ff.userAgent.AddUserAgent(req)
 	client := http.Client{}
 	client.Timeout = feedOrSiteTimeoutSec * time.Second
-	resp, err := client.Get(urlStr)
+	resp, err := client.Do(req)
 	if err != nil {
 		ff.logger.Warnf("Failed to get %s: %v", siteUrl, err)
 		return nil, nil, err
@@ -261,6 +336,10 @@ func (ff *feedFollower) getSiteInfo(urlStr string) (*SiteInfo, *gofeed.Feed, err
 		return nil, nil, err
 	}
 
+	if isDocOptedOut(doc) {
+		return nil, nil, errOptedOut
+	}
+
 	// Pick out the data we're interested in
 	res.FeedUrl = ff.getFeedUrl(siteUrl, doc)
 	if res.FeedUrl == "" {
@@ -268,14 +347,26 @@ func (ff *feedFollower) getSiteInfo(urlStr string) (*SiteInfo, *gofeed.Feed, err
 		return nil, nil, fmt.Errorf("no feed URL found at %s", siteUrl)
 	}
 	ff.getMetas(doc, &res)
+	if ff.isDisallowedByRobots(robots, res.FeedUrl) {
+		return nil, nil, errOptedOut
+	}
 
 	// Get the feed to make sure it's there, and know when it's last changed
-	feed, err = ff.fetchParseFeed(res.FeedUrl)
+	fr, err = ff.fetchParseFeed(res.FeedUrl, "", "")
+	if err == nil && fr.feed == nil {
+		err = fmt.Errorf("feed is gone or temporarily unavailable")
+	}
 	if err != nil {
 		ff.logger.Warnf("Failed to retrieve and parse feed: %s, %v", res.FeedUrl, err)
 		return nil, nil, err
 	}
+	feed = fr.feed
+	if isFeedOptedOut(feed) {
+		return nil, nil, errOptedOut
+	}
 	res.LastUpdated = getLastUpdated(feed)
+	res.WebSubHub, res.WebSubTopic = fr.hub, fr.topic
+	res.ProfileImageUrl, res.HeaderImageUrl = ff.discoverImages(urlStr, doc, res.FeedUrl, feed)
 
 	return &res, feed, nil
 }
@@ -287,12 +378,15 @@ func getItemHash(itm *gofeed.Item) uint {
 	return uint(hasher.Sum32())
 }
 
+// Stores and toots new items, and updates edited ones. fullFeed is false for content that may hold only
+// some of the feed's items, like WebSub pushes: then posts missing from it are not taken for removed.
 func (ff *feedFollower) updateAccountPosts(
-	accountId int,
-	accountHandle string,
+	acct *dal.Account,
 	feed *gofeed.Feed,
 	tootNew bool,
+	fullFeed bool,
 ) (err error) {
+	accountId, accountHandle := acct.Id, acct.Handle
 	err = nil
 	var lastKnownFeedUpdated time.Time
 
@@ -300,23 +394,112 @@ func (ff *feedFollower) updateAccountPosts(
 		return
 	}
 
+	// Items' hashes, and the links we store and match on, need podcast links fixed first
+	for _, itm := range feed.Items {
+		fixPodcastLink(itm)
+	}
+
 	// Deal with feed items newer than our last seen
 	// This goes from older to newer
 	keepers, newLastUpdated := getSortedPosts(feed.Items, lastKnownFeedUpdated)
+	isKeeper := make(map[*gofeed.Item]bool, len(keepers))
+	var rd *republishDetector
+	if len(keepers) != 0 {
+		if rd, err = ff.newRepublishDetector(accountId, feed); err != nil {
+			return
+		}
+	}
+	var newItems []*gofeed.Item
 	for _, k := range keepers {
-		fixPodcastLink(k.itm)
-		if err = ff.storePostIfNew(accountId, accountHandle, k.postTime, k.itm, tootNew); err != nil {
+		isKeeper[k.itm] = true
+		var republished bool
+		if republished, err = ff.handleIfRepublished(rd, acct, k.itm, tootNew); err != nil {
+			return
+		}
+		if republished {
+			continue
+		}
+		var isNew bool
+		if isNew, err = ff.storePostIfNew(acct, k.postTime, k.itm, tootNew); err != nil {
+			return
+		}
+		if isNew {
+			newItems = append(newItems, k.itm)
+		}
+	}
+
+	// A feed that publishes lots of items at once gets a single digest toot, not a flood of toots
+	if floodLimit := ff.getFloodLimit(acct); tootNew && floodLimit > 0 && len(newItems) > floodLimit {
+		ff.logger.Infof("Feed flooded with %d new posts; tooting digest: %s", len(newItems), accountHandle)
+		if err = ff.createDigestToot(accountId, accountHandle, newItems); err != nil {
+			return
+		}
+	} else {
+		for _, itm := range newItems {
+			if err = ff.createToot(acct, itm, tootNew); err != nil {
+				return
+			}
+		}
+	}
+
+	// Older items may still have been edited since we stored them
+	for _, itm := range feed.Items {
+		if isKeeper[itm] {
+			continue
+		}
+		if err = ff.updatePostIfChanged(acct, itm, tootNew); err != nil {
+			return
+		}
+	}
+
+	if ff.cfg.PropagateDeletes && fullFeed {
+		if err = ff.deleteRemovedPosts(accountId, accountHandle, feed, tootNew); err != nil {
 			return
 		}
 	}
 
-	nextCheckDue := ff.getNextCheckTime(newLastUpdated)
+	nextCheckDue := ff.getAccountNextCheckTime(acct, newLastUpdated)
 	if err = ff.repo.UpdateAccountFeedTimes(accountId, newLastUpdated, nextCheckDue); err != nil {
 		return
 	}
 	return
 }
 
+// Waits until no one else is updating the account's posts. Caller must call the returned function when done.
+func (ff *feedFollower) lockAccount(accountId int) (unlock func()) {
+
+	ff.muAccountLocks.Lock()
+	al := ff.accountLocks[accountId]
+	if al == nil {
+		al = &accountLock{}
+		ff.accountLocks[accountId] = al
+	}
+	al.users++
+	ff.muAccountLocks.Unlock()
+
+	al.mu.Lock()
+	return func() {
+		al.mu.Unlock()
+		ff.muAccountLocks.Lock()
+		al.users--
+		if al.users == 0 {
+			delete(ff.accountLocks, accountId)
+		}
+		ff.muAccountLocks.Unlock()
+	}
+}
+
+// Account's own flood limit if it has one, or the configured one. 0 means no limit.
+func (ff *feedFollower) getFloodLimit(acct *dal.Account) int {
+	if acct.FloodLimit > 0 {
+		return acct.FloodLimit
+	}
+	if acct.FloodLimit < 0 {
+		return 0
+	}
+	return ff.cfg.FloodLimit
+}
+
 func fixPodcastLink(itm *gofeed.Item) {
 	if itm.Link != "" {
 		return
@@ -415,49 +598,179 @@ func stripHtml(htm string) string {
 	return plain
 }
 
+func getItemContentHash(itm *gofeed.Item) uint {
+	str := itm.Link + "\t" + stripHtml(itm.Title) + "\t" + stripHtml(itm.Description)
+	hasher := murmur3.New32()
+	_, _ = hasher.Write([]byte(str))
+	return uint(hasher.Sum32())
+}
+
+func makeFeedPost(postTime time.Time, itm *gofeed.Item) *dal.FeedPost {
+	return &dal.FeedPost{
+		PostGuidHash: int64(getItemHash(itm)),
+		PostTime:     postTime,
+		Link:         itm.Link,
+		Title:        stripHtml(itm.Title),
+		Description:  stripHtml(itm.Description),
+		ContentHash:  int64(getItemContentHash(itm)),
+		Guid:         itm.GUID,
+		Hashtags:     getHashtags(itm.Categories),
+	}
+}
+
+// Stores the post if we haven't seen it yet. Caller is responsible for tooting new posts.
 func (ff *feedFollower) storePostIfNew(
-	accountId int,
-	accountHandle string,
+	acct *dal.Account,
 	postTime time.Time,
 	itm *gofeed.Item,
 	tootNew bool,
-) (err error) {
-	var isNew bool
-	plainTitle := stripHtml(itm.Title)
-	plainDescription := stripHtml(itm.Description)
-	isNew, err = ff.repo.AddFeedPostIfNew(accountId, &dal.FeedPost{
-		PostGuidHash: int64(getItemHash(itm)),
-		PostTime:     postTime,
-		Link:         itm.Link,
-		Title:        plainTitle,
-		Description:  plainDescription,
-	})
+) (isNew bool, err error) {
+	isNew, err = ff.repo.AddFeedPostIfNew(acct.Id, makeFeedPost(postTime, itm))
 	if err != nil {
 		return
 	}
 	if isNew {
 		ff.metrics.NewPostSaved()
-		if err = ff.createToot(accountId, accountHandle, itm, tootNew); err != nil {
+	} else {
+		// Post's updated time moved forward: it may have been edited
+		err = ff.updatePostIfChanged(acct, itm, tootNew)
+	}
+	return
+}
+
+// If we already have this post and its content changed, updates the post and its toot,
+// and sends the edited toot to followers
+func (ff *feedFollower) updatePostIfChanged(
+	acct *dal.Account,
+	itm *gofeed.Item,
+	sendUpdate bool,
+) (err error) {
+	accountId, accountHandle := acct.Id, acct.Handle
+	var changed bool
+	if changed, err = ff.repo.UpdateFeedPostIfChanged(accountId, makeFeedPost(time.Time{}, itm)); err != nil {
+		return
+	}
+	if !changed {
+		return
+	}
+	ff.logger.Infof("Post edited: %s: %s", accountHandle, itm.Link)
+	ff.metrics.PostUpdated()
+
+	hashtags := getTootHashtags(acct, itm)
+	content := ff.getTootContent(accountHandle, itm, hashtags)
+	var toot *dal.Toot
+	if toot, err = ff.repo.UpdateTootContent(accountId, int64(getItemHash(itm)), content, hashtags); err != nil {
+		return
+	}
+	if toot == nil || !sendUpdate {
+		return
+	}
+	// Edits keep the attachments the toot got when it was created
+	return ff.messenger.EnqueueUpdate(accountHandle, toot.StatusId, toot.TootedAt, time.Now(), content,
+		toot.Attachments, hashtags)
+}
+
+// Posts we have from within the time span the feed still covers, but which are missing from it,
+// have been removed by the author. Older posts that are not in the feed have just scrolled off.
+// The span is measured the way we store post times: the later of the published and updated times.
+// A post is only deleted once it's been missing from two full polls in a row, so that a feed
+// that is briefly broken or truncated doesn't make us delete live posts.
+func (ff *feedFollower) deleteRemovedPosts(
+	accountId int,
+	accountHandle string,
+	feed *gofeed.Feed,
+	sendDelete bool,
+) (err error) {
+	var oldest time.Time
+	inFeed := make(map[int64]bool, len(feed.Items))
+	for _, itm := range feed.Items {
+		inFeed[int64(getItemHash(itm))] = true
+		if postTime := getItemPostTime(itm); !postTime.IsZero() && (oldest.IsZero() || postTime.Before(oldest)) {
+			oldest = postTime
+		}
+	}
+	if oldest.IsZero() {
+		return nil
+	}
+
+	var posts []*dal.FeedPost
+	if posts, err = ff.repo.GetFeedPostsSince(accountId, oldest); err != nil {
+		return
+	}
+	var removed []*dal.FeedPost
+	missing := make(map[int64]bool)
+	ff.muMissingPosts.Lock()
+	for _, post := range posts {
+		if inFeed[post.PostGuidHash] {
+			continue
+		}
+		missing[post.PostGuidHash] = true
+		if ff.missingPosts[accountId][post.PostGuidHash] {
+			removed = append(removed, post)
+			delete(missing, post.PostGuidHash)
+		}
+	}
+	if len(missing) == 0 {
+		delete(ff.missingPosts, accountId)
+	} else {
+		ff.missingPosts[accountId] = missing
+	}
+	ff.muMissingPosts.Unlock()
+
+	for _, post := range removed {
+		ff.logger.Infof("Post removed from feed: %s: %s", accountHandle, post.Link)
+		var statusId string
+		if statusId, err = ff.repo.DeletePostAndToot(accountId, post.PostGuidHash); err != nil {
+			return
+		}
+		ff.metrics.PostsDeleted(1)
+		if statusId == "" || !sendDelete {
+			continue
+		}
+		if err = ff.messenger.EnqueueDelete(accountHandle, statusId); err != nil {
 			return
 		}
 	}
-	return
+	if len(removed) != 0 {
+		if err = ff.media.PruneThumbnails(accountHandle); err != nil {
+			ff.logger.Warnf("Failed to remove thumbnails of deleted toots: %s: %v", accountHandle, err)
+		}
+	}
+	return nil
+}
+
+// The time we store for the item's post: the later of its published and updated times
+func getItemPostTime(itm *gofeed.Item) time.Time {
+	var res time.Time
+	if itm.PublishedParsed != nil {
+		res = *itm.PublishedParsed
+	}
+	if itm.UpdatedParsed != nil && itm.UpdatedParsed.After(res) {
+		res = *itm.UpdatedParsed
+	}
+	return res
 }
 
-func (ff *feedFollower) createToot(accountId int, accountHandle string, itm *gofeed.Item, sendToot bool) error {
+func (ff *feedFollower) getTootContent(accountHandle string, itm *gofeed.Item, hashtags []string) string {
 	prettyUrl := itm.Link
 	prettyUrl = strings.TrimPrefix(prettyUrl, "http://")
 	prettyUrl = strings.TrimPrefix(prettyUrl, "https://")
 	prettyUrl = strings.TrimRight(prettyUrl, "/")
 	plainTitle := stripHtml(itm.Title)
-	plainDescription := stripHtml(itm.Description)
-	plainDescription = shared.TruncateWithEllipsis(plainDescription, shared.MaxDescriptionLen)
 	content := ff.txt.WithVals("toot_new_post.html", map[string]string{
-		"title":       plainTitle,
-		"url":         itm.Link,
-		"prettyUrl":   prettyUrl,
-		"description": plainDescription,
+		"title":     plainTitle,
+		"url":       itm.Link,
+		"prettyUrl": prettyUrl,
 	})
+	content += getSummaryHtml(itm.Description, itm.Link, shared.MaxDescriptionLen)
+	return content + ff.getHashtagsHtml(accountHandle, hashtags)
+}
+
+func (ff *feedFollower) createToot(acct *dal.Account, itm *gofeed.Item, sendToot bool) error {
+	accountId, accountHandle := acct.Id, acct.Handle
+	hashtags := getTootHashtags(acct, itm)
+	content := ff.getTootContent(accountHandle, itm, hashtags)
+	attachments := ff.getTootAttachments(accountHandle, itm, sendToot)
 	idb := shared.IdBuilder{ff.cfg.Host}
 	id := ff.repo.GetNextId()
 	statusId := idb.UserStatus(accountHandle, id)
@@ -467,19 +780,67 @@ func (ff *feedFollower) createToot(accountId int, accountHandle string, itm *gof
 		TootedAt:     tootedAt,
 		StatusId:     statusId,
 		Content:      content,
+		Attachments:  attachments,
+		Hashtags:     hashtags,
 	})
 	if err != nil {
 		return err
 	}
 	if sendToot {
-		if err = ff.messenger.EnqueueBroadcast(accountHandle, statusId, tootedAt, content); err != nil {
+		if err = ff.messenger.EnqueueBroadcast(accountHandle, statusId, tootedAt, content, attachments,
+			hashtags); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (ff *feedFollower) filterFeed(feedUrl string, feed *gofeed.Feed) (FeedStatus, error) {
+// Toots a single list of new posts, newest first. The posts themselves get no toots of their own,
+// so followers don't later receive edits or deletes for toots they never saw.
+func (ff *feedFollower) createDigestToot(accountId int, accountHandle string, items []*gofeed.Item) error {
+
+	var links []string
+	for i := len(items) - 1; i >= 0 && len(links) < floodDigestMaxItems; i-- {
+		itm := items[i]
+		title := stripHtml(itm.Title)
+		if title == "" {
+			title = itm.Link
+		}
+		title = shared.TruncateWithEllipsis(title, floodDigestMaxTitleLen)
+		links = append(links, ff.txt.WithVals("toot_digest_item.html", map[string]string{
+			"title": title,
+			"url":   itm.Link,
+		}))
+	}
+	content := ff.txt.WithVals("toot_digest.html", map[string]string{
+		"count": strconv.Itoa(len(items)),
+	})
+	content += "<p>" + strings.Join(links, "<br>") + "</p>"
+	if len(items) > len(links) {
+		content += ff.txt.WithVals("toot_digest_more.html", map[string]string{
+			"count": strconv.Itoa(len(items) - len(links)),
+		})
+	}
+
+	idb := shared.IdBuilder{ff.cfg.Host}
+	id := ff.repo.GetNextId()
+	statusId := idb.UserStatus(accountHandle, id)
+	tootedAt := time.Now()
+	// Digest is not tied to any post: its ID stands in for the hash, and never collides with 32-bit item hashes
+	err := ff.repo.AddToot(accountId, &dal.Toot{
+		PostGuidHash: int64(id),
+		TootedAt:     tootedAt,
+		StatusId:     statusId,
+		Content:      content,
+	})
+	if err != nil {
+		return err
+	}
+	ff.metrics.PostsDigested(len(items))
+	return ff.messenger.EnqueueBroadcast(accountHandle, statusId, tootedAt, content, nil, nil)
+}
+
+func (ff *feedFollower) filterFeed(si *SiteInfo, feed *gofeed.Feed) (FeedStatus, error) {
 
 	// We don't parrot Mastond RSS feeds
 	generator := strings.ToLower(feed.Generator)
@@ -487,15 +848,12 @@ func (ff *feedFollower) filterFeed(feedUrl string, feed *gofeed.Feed) (FeedStatu
 		return FsMastodon, nil
 	}
 
-	// We don't parrot blocked feeds
-	var err error
-	var blocked bool
-	blocked, err = ff.blockedFeeds.IsBlocked(feedUrl)
-	if err != nil {
-		return FsError, err
-	}
-	if blocked {
-		return FsOptOut, nil
+	// We don't parrot blocked feeds, or feeds of blocked sites
+	for _, urlStr := range []string{si.FeedUrl, si.Url} {
+		if blocked, reason := ff.blockedFeeds.IsBlocked(urlStr); blocked {
+			ff.logger.Infof("Feed is blocked: %s: %s", urlStr, reason)
+			return FsOptOut, nil
+		}
 	}
 
 	// FsError is the OK response
@@ -519,12 +877,17 @@ func (ff *feedFollower) GetAccountForFeed(urlStr string) (acct *dal.Account, sta
 	if siErr == nil {
 		siErr = ff.validateSiteInfo(si)
 	}
+	if errors.Is(siErr, errOptedOut) {
+		ff.logger.Infof("Publisher opted out: %s", urlStr)
+		status = FsOptOut
+		return
+	}
 	if siErr != nil {
 		err = siErr
 		return
 	}
 
-	status, err = ff.filterFeed(si.FeedUrl, feed)
+	status, err = ff.filterFeed(si, feed)
 	if err != nil {
 		status = FsError
 		return
@@ -545,14 +908,17 @@ func (ff *feedFollower) GetAccountForFeed(urlStr string) (acct *dal.Account, sta
 
 	var isNew bool
 	isNew, err = ff.repo.AddAccountIfNotExist(&dal.Account{
-		CreatedAt:   time.Now(),
-		Handle:      si.ParrotHandle,
-		UserUrl:     idb.UserUrl(si.ParrotHandle),
-		FeedName:    si.Title,
-		FeedSummary: si.Description,
-		SiteUrl:     si.Url,
-		FeedUrl:     si.FeedUrl,
-		PubKey:      pubKey,
+		CreatedAt:       time.Now(),
+		Handle:          si.ParrotHandle,
+		UserUrl:         idb.UserUrl(si.ParrotHandle),
+		FeedName:        si.Title,
+		FeedSummary:     si.Description,
+		SiteUrl:         si.Url,
+		FeedUrl:         si.FeedUrl,
+		PubKey:          pubKey,
+		ProfileImageUrl: si.ProfileImageUrl,
+		HeaderImageUrl:  si.HeaderImageUrl,
+		ImagesCheckedAt: time.Now().UTC(),
 	}, privKey)
 
 	if err != nil {
@@ -561,6 +927,9 @@ func (ff *feedFollower) GetAccountForFeed(urlStr string) (acct *dal.Account, sta
 	}
 
 	ff.logger.Infof("Account is %s; newly created: %v", si.ParrotHandle, isNew)
+	if isNew {
+		ff.storeImages(si.ParrotHandle, si.ProfileImageUrl, si.HeaderImageUrl)
+	}
 
 	acct, err = ff.repo.GetAccount(si.ParrotHandle)
 	if err != nil {
@@ -569,13 +938,16 @@ func (ff *feedFollower) GetAccountForFeed(urlStr string) (acct *dal.Account, sta
 		return
 	}
 
-	err = ff.updateAccountPosts(acct.Id, si.ParrotHandle, feed, !isNew)
+	err = ff.updateAccountPosts(acct, feed, !isNew, true)
 	if err != nil {
 		ff.logger.Errorf("Failed to update account's posts: %s: %v", acct.Handle, err)
 		acct = nil
 		return
 	}
 
+	// If feed has a WebSub hub, get new posts pushed to us
+	ff.maybeSubscribeWebSub(acct, si.WebSubHub, si.WebSubTopic)
+
 	if isNew {
 		status = FsNew
 		feedLabel = "new"
@@ -586,27 +958,112 @@ func (ff *feedFollower) GetAccountForFeed(urlStr string) (acct *dal.Account, sta
 	return
 }
 
-func (ff *feedFollower) fetchParseFeed(feedUrl string) (feed *gofeed.Feed, err error) {
+// Retrieves and parses feed. If etag or lastModified are provided, sends a conditional GET,
+// and the result has notModified set if the server says the feed hasn't changed.
+// 410 Gone, and 429/503 with a Retry-After header, are not errors: they are reported in the result.
+func (ff *feedFollower) fetchParseFeed(feedUrl, etag, lastModified string) (res *fetchResult, err error) {
 
 	var req *http.Request
 	if req, err = http.NewRequest("GET", feedUrl, nil); err != nil {
 		return nil, err
 	}
 	ff.userAgent.AddUserAgent(req)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
 
+	// Follow redirects like the default client does, but remember if all hops so far were permanent
+	movedTo := ""
+	allPermanent := true
 	client := http.Client{}
 	client.Timeout = time.Second * feedOrSiteTimeoutSec
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFeedRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxFeedRedirects)
+		}
+		code := req.Response.StatusCode
+		if allPermanent && (code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect) {
+			movedTo = req.URL.String()
+		} else {
+			allPermanent = false
+		}
+		return nil
+	}
+
 	var resp *http.Response
 	if resp, err = client.Do(req); err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	res = &fetchResult{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		movedTo:      movedTo,
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		// Server may omit validators from a 304; keep using the ones we sent
+		if res.etag == "" {
+			res.etag = etag
+		}
+		if res.lastModified == "" {
+			res.lastModified = lastModified
+		}
+		res.notModified = true
+		return res, nil
+	}
+	if resp.StatusCode == http.StatusGone {
+		res.gone = true
+		return res, nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if res.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); !res.retryAfter.IsZero() {
+			return res, nil
+		}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("request failed with status %v", resp.StatusCode)
 	}
 
-	fp := gofeed.NewParser()
-	return fp.Parse(resp.Body)
+	var body []byte
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	fp := newFeedParser()
+	if res.feed, err = fp.Parse(bytes.NewReader(body)); err != nil {
+		return nil, err
+	}
+	res.hub, res.topic = getWebSubLinks(resp.Header, body)
+	if res.topic == "" {
+		res.topic = resp.Request.URL.String()
+	}
+	return res, nil
+}
+
+// Interprets Retry-After header, which is either a number of seconds or an HTTP date.
+// Returns zero time if the value is missing or invalid; caps what we accept at maxRetryAfterHours.
+func parseRetryAfter(val string, now time.Time) time.Time {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return time.Time{}
+	}
+	var res time.Time
+	if secs, err := strconv.Atoi(val); err == nil {
+		if secs < 0 {
+			return time.Time{}
+		}
+		res = now.Add(time.Duration(secs) * time.Second)
+	} else if t, err := http.ParseTime(val); err == nil {
+		res = t
+	} else {
+		return time.Time{}
+	}
+	if limit := now.Add(maxRetryAfterHours * time.Hour); res.After(limit) {
+		res = limit
+	}
+	return res
 }
 
 func (ff *feedFollower) updateFeed(acct *dal.Account) error {
@@ -615,14 +1072,75 @@ func (ff *feedFollower) updateFeed(acct *dal.Account) error {
 	ff.logger.Infof("Updating account %s: %s", acct.Handle, acct.FeedUrl)
 	ff.metrics.FeedUpdated()
 
-	var feed *gofeed.Feed
-	if feed, err = ff.fetchParseFeed(acct.FeedUrl); err != nil {
+	// A WebSub push for the same account may bring the same items at the same time
+	unlock := ff.lockAccount(acct.Id)
+	defer unlock()
+
+	// Publisher may have opted out since we started following them
+	if ff.recheckOptOut(acct) {
+		return ff.stopOptedOutFeed(acct)
+	}
+
+	var fr *fetchResult
+	if fr, err = ff.fetchParseFeed(acct.FeedUrl, acct.FeedEtag, acct.FeedLastModified); err != nil {
 		return err
 	}
 
-	if err = ff.updateAccountPosts(acct.Id, acct.Handle, feed, true); err != nil {
+	if fr.gone {
+		ff.logger.Warnf("Feed is gone; no longer checking it: %s: %s", acct.Handle, acct.FeedUrl)
+		ff.metrics.FeedCheckProblem("gone")
+		return ff.repo.SetAccountPollStatus(acct.Id, dal.PollGone)
+	}
+
+	if !fr.retryAfter.IsZero() {
+		// Publisher asked us to come back later: do so, but not sooner than we normally would
+		nextCheckDue := ff.getAccountNextCheckTime(acct, acct.FeedLastUpdated)
+		if fr.retryAfter.After(nextCheckDue) {
+			nextCheckDue = fr.retryAfter
+		}
+		ff.logger.Infof("Feed asked us to retry after %s: %s", fr.retryAfter.Format(time.RFC3339), acct.Handle)
+		ff.metrics.FeedCheckProblem("throttled")
+		return ff.repo.UpdateAccountFeedTimes(acct.Id, acct.FeedLastUpdated, nextCheckDue)
+	}
+
+	if fr.movedTo != "" && fr.movedTo != acct.FeedUrl {
+		ff.logger.Infof("Feed moved permanently: %s: %s -> %s", acct.Handle, acct.FeedUrl, fr.movedTo)
+		ff.metrics.FeedCheckProblem("moved")
+		if err = ff.repo.UpdateAccountFeedUrl(acct.Id, fr.movedTo); err != nil {
+			return err
+		}
+		// Validators were reset with the URL; make sure we store the ones from this response
+		acct.FeedUrl = fr.movedTo
+		acct.FeedEtag = ""
+		acct.FeedLastModified = ""
+	}
+
+	if fr.feed != nil && isFeedOptedOut(fr.feed) {
+		return ff.stopOptedOutFeed(acct)
+	}
+
+	// Looking for images means fetching the site and several images: don't hold the host's slot while we do
+	go ff.maybeRefreshImages(*acct, fr.feed)
+
+	if fr.notModified {
+		// Nothing new: just schedule next check as if we had found no new posts
+		ff.logger.Infof("Feed not modified: %s", acct.Handle)
+		ff.metrics.FeedNotModified()
+		ff.maybeSubscribeWebSub(acct, acct.WebSubHub, acct.WebSubTopic)
+		nextCheckDue := ff.getAccountNextCheckTime(acct, acct.FeedLastUpdated)
+		return ff.repo.UpdateAccountFeedTimes(acct.Id, acct.FeedLastUpdated, nextCheckDue)
+	}
+
+	if err = ff.updateAccountPosts(acct, fr.feed, true, true); err != nil {
 		return err
 	}
+	ff.maybeSubscribeWebSub(acct, fr.hub, fr.topic)
+
+	if fr.etag != acct.FeedEtag || fr.lastModified != acct.FeedLastModified {
+		if err = ff.repo.UpdateAccountFeedValidators(acct.Id, fr.etag, fr.lastModified); err != nil {
+			return err
+		}
+	}
 
 	go func() {
 		if err = ff.PurgeOldPosts(acct, ff.cfg.PostsMinCountKept, ff.cfg.PostsMinDaysKept); err != nil {
@@ -634,6 +1152,48 @@ func (ff *feedFollower) updateFeed(acct *dal.Account) error {
 	return nil
 }
 
+// Records a failed check, and either reschedules the feed with backoff or suspends it
+func (ff *feedFollower) handleCheckFailure(acct *dal.Account, lastUpdated time.Time, checkErr error) {
+
+	failures := acct.CheckFailures + 1
+	errStr := truncateText(checkErr.Error(), maxCheckErrorLen)
+	ff.metrics.FeedCheckProblem("failed")
+	if err := ff.repo.SetAccountCheckFailures(acct.Id, failures, errStr); err != nil {
+		ff.logger.Errorf("Failed to record check failure: %s: %v", acct.Handle, err)
+	}
+
+	if ff.cfg.FeedMaxFailures > 0 && failures >= ff.cfg.FeedMaxFailures {
+		ff.logger.Warnf("Feed failed %d times in a row; no longer checking it: %s: %s",
+			failures, acct.Handle, acct.FeedUrl)
+		ff.metrics.FeedCheckProblem("suspended")
+		if err := ff.repo.SetAccountPollStatus(acct.Id, dal.PollSuspended); err != nil {
+			ff.logger.Errorf("Failed to suspend feed: %s: %v", acct.Handle, err)
+		}
+		return
+	}
+
+	// Reschedule for updating as if there was no new post, backing off after repeated failures
+	nextCheckDue := ff.getBackoffCheckTime(lastUpdated, failures)
+	if err := ff.repo.UpdateAccountFeedTimes(acct.Id, lastUpdated, nextCheckDue); err != nil {
+		ff.logger.Errorf("Failed to reschedule for checking after error: %s: %v", acct.Handle, err)
+	}
+}
+
+// Regular check interval, doubled for every consecutive failure after the first
+func (ff *feedFollower) getBackoffCheckTime(lastChanged time.Time, failures int) time.Time {
+
+	now := time.Now()
+	interval := ff.getNextCheckTime(lastChanged).Sub(now)
+	limit := maxBackoffHours * time.Hour
+	for i := 1; i < failures && interval < limit; i++ {
+		interval *= 2
+	}
+	if interval > limit {
+		interval = limit
+	}
+	return now.Add(interval)
+}
+
 func (ff *feedFollower) PurgeOldPosts(acct *dal.Account, minCount, minAgeDays int) error {
 
 	if minCount <= 0 || minAgeDays <= 0 {
@@ -698,10 +1258,22 @@ func (ff *feedFollower) PurgeOldPosts(acct *dal.Account, minCount, minAgeDays in
 
 	// Purge 'em
 	ff.logger.Infof("Purging %d old toots+posts from account %s", nToDel, acct.Handle)
-	if err = ff.repo.PurgePostsAndToots(acct.Id, *fromBefore); err != nil {
+	var purgedStatusIds []string
+	if purgedStatusIds, err = ff.repo.PurgePostsAndToots(acct.Id, *fromBefore); err != nil {
 		return err
 	}
 	ff.metrics.PostsDeleted(nToDel)
+	if err = ff.media.PruneThumbnails(acct.Handle); err != nil {
+		ff.logger.Warnf("Failed to remove thumbnails of purged toots: %s: %v", acct.Handle, err)
+	}
+	if !ff.cfg.PropagateDeletes {
+		return nil
+	}
+	for _, statusId := range purgedStatusIds {
+		if err = ff.messenger.EnqueueDelete(acct.Handle, statusId); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -777,9 +1349,17 @@ func (ff *feedFollower) updateTotalPostsMetric() {
 }
 
 func (ff *feedFollower) feedCheckLoop() {
+
+	nWorkers := max(ff.cfg.FeedCheckWorkers, 1)
+	jobs := make(chan *dal.Account)
+	for i := 0; i < nWorkers; i++ {
+		go ff.feedCheckWorker(jobs)
+	}
+
+	var waiting []*dal.Account
 	for {
 		// This is why we're here
-		ff.feedCheckLoopInner()
+		waiting = ff.feedCheckLoopInner(jobs, nWorkers, waiting)
 
 		// This is real doggone ugly here, but -
 		// Other option is to create a logic class just for this
@@ -790,8 +1370,16 @@ func (ff *feedFollower) feedCheckLoop() {
 	}
 }
 
-func (ff *feedFollower) feedCheckLoopInner() {
+// Hands claimed accounts to the workers, and claims a new batch of due accounts.
+// Accounts whose host is busy wait with us. We don't claim accounts on hosts that are busy, or that
+// have accounts waiting, so feeds on other hosts keep being checked. Returns the accounts still waiting.
+func (ff *feedFollower) feedCheckLoopInner(
+	jobs chan<- *dal.Account,
+	batchSize int,
+	waiting []*dal.Account,
+) (res []*dal.Account) {
 
+	res = waiting
 	defer func() {
 		if r := recover(); r != nil {
 			const panicSleepSec = 10
@@ -801,28 +1389,93 @@ func (ff *feedFollower) feedCheckLoopInner() {
 		}
 	}()
 
+	res = ff.dispatchFeedChecks(jobs, res)
+	excludeHosts := ff.hostGate.getBusyHosts()
+	for _, acct := range res {
+		excludeHosts[shared.GetFeedHost(acct.FeedUrl)] = true
+	}
+
 	var err error
-	var acct *dal.Account
+	var accts []*dal.Account
 	var total int
-	if acct, total, err = ff.repo.GetAccountToCheck(time.Now()); err != nil {
-		ff.logger.Errorf("Failed to get next feed due for checking: %v", err)
+	now := time.Now()
+	claimedUntil := now.Add(feedCheckClaimMins * time.Minute)
+	if accts, total, err = ff.repo.ClaimAccountsToCheck(now, batchSize, claimedUntil, excludeHosts); err != nil {
+		ff.logger.Errorf("Failed to get next feeds due for checking: %v", err)
 		time.Sleep(feedCheckLoopIdleWakeSec * time.Second)
 		return
 	}
 	ff.metrics.CheckableFeedCount(total)
-	if acct == nil {
+	if len(accts) == 0 {
+		if len(res) != 0 {
+			ff.hostGate.waitForFree(feedCheckLoopIdleWakeSec * time.Second)
+			return
+		}
 		ff.logger.Debugf("No feeds to check; sleeping %d seconds", feedCheckLoopIdleWakeSec)
 		time.Sleep(feedCheckLoopIdleWakeSec * time.Second)
 		return
 	}
+	res = ff.dispatchFeedChecks(jobs, appendNotWaiting(res, accts))
+	return
+}
+
+// Adds the accounts that aren't waiting yet. An account may wait longer than its claim, and be claimed again.
+func appendNotWaiting(waiting, accts []*dal.Account) []*dal.Account {
+	waitingIds := make(map[int]bool, len(waiting))
+	for _, acct := range waiting {
+		waitingIds[acct.Id] = true
+	}
+	for _, acct := range accts {
+		if !waitingIds[acct.Id] {
+			waitingIds[acct.Id] = true
+			waiting = append(waiting, acct)
+		}
+	}
+	return waiting
+}
+
+// Hands each account whose host has a free slot to a worker. Returns the rest.
+func (ff *feedFollower) dispatchFeedChecks(jobs chan<- *dal.Account, accts []*dal.Account) []*dal.Account {
+	var res []*dal.Account
+	for _, acct := range accts {
+		if ff.hostGate.tryEnter(shared.GetFeedHost(acct.FeedUrl)) {
+			jobs <- acct
+		} else {
+			res = append(res, acct)
+		}
+	}
+	return res
+}
+
+func (ff *feedFollower) feedCheckWorker(jobs <-chan *dal.Account) {
+	for acct := range jobs {
+		// Checking may move the account to a new feed URL: free the slot we took
+		host := shared.GetFeedHost(acct.FeedUrl)
+		ff.checkFeed(acct)
+		ff.hostGate.leave(host)
+	}
+}
+
+func (ff *feedFollower) checkFeed(acct *dal.Account) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			const panicSleepSec = 10
+			ff.logger.Errorf("Feed check panicked: %s: %v", acct.Handle, r)
+			ff.logger.Infof("Sleeping %d seconds after panic", panicSleepSec)
+			time.Sleep(time.Second * panicSleepSec)
+		}
+	}()
+
+	var err error
 	lastUpdated := acct.FeedLastUpdated
 	err = ff.updateFeed(acct)
 	if err != nil {
 		ff.logger.Errorf("Error updating feed: %s: %v", acct.Handle, err)
-		// Reschedule for updating as if there was no new post
-		nextCheckDue := ff.getNextCheckTime(lastUpdated)
-		if err = ff.repo.UpdateAccountFeedTimes(acct.Id, lastUpdated, nextCheckDue); err != nil {
-			ff.logger.Errorf("Failed to reschedule for checking after error: %s: %v", acct.Handle, err)
+		ff.handleCheckFailure(acct, lastUpdated, err)
+	} else if acct.CheckFailures != 0 {
+		if err = ff.repo.SetAccountCheckFailures(acct.Id, 0, ""); err != nil {
+			ff.logger.Errorf("Failed to reset check failures: %s: %v", acct.Handle, err)
 		}
 	}
 	// If no error, updateFeed has set next due date for checking
@@ -0,0 +1,286 @@
+package logic
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/extensions"
+	"mime"
+	"net/url"
+	"path"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/shared"
+	"strings"
+)
+
+// Toots get the feed item's images and enclosures as attachments, so they show with previews.
+// Images, best first:
+// - media:content images, directly in the item or in a media:group
+// - media:thumbnail, or the item's iTunes image
+// - image enclosures
+// - the first <img> in the item's content or description
+// - the article's og:image, only for toots we send, and only if the item has no image at all
+// Audio enclosures become Audio attachments, other enclosures Documents.
+// Images are stored in the media cache as thumbnails; ones we fail to download are left out.
+
+const (
+	maxTootAttachments = 4
+	maxAltTextLen      = 1500
+)
+
+var imageFileExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".webp": true,
+}
+
+func getAttachmentType(mediaType string) string {
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		return "Image"
+	case strings.HasPrefix(mediaType, "audio/"):
+		return "Audio"
+	default:
+		return "Document"
+	}
+}
+
+func getAltText(text string) string {
+	return shared.TruncateWithEllipsis(stripHtml(text), maxAltTextLen)
+}
+
+// Alt text of a media:content or media:thumbnail element, from its own description or title, or the group's
+func getMediaAltText(elm, group *ext.Extension) string {
+	for _, e := range []*ext.Extension{elm, group} {
+		if e == nil {
+			continue
+		}
+		for _, name := range []string{"description", "title"} {
+			if children := e.Children[name]; len(children) != 0 && strings.TrimSpace(children[0].Value) != "" {
+				return getAltText(children[0].Value)
+			}
+		}
+	}
+	return ""
+}
+
+func isMediaContentImage(elm *ext.Extension) bool {
+	if medium := elm.Attrs["medium"]; medium != "" {
+		return medium == "image"
+	}
+	if mediaType := elm.Attrs["type"]; mediaType != "" {
+		return strings.HasPrefix(mediaType, "image/")
+	}
+	parsedUrl, err := url.Parse(elm.Attrs["url"])
+	return err == nil && imageFileExts[strings.ToLower(path.Ext(parsedUrl.Path))]
+}
+
+// Images from the item's Media RSS elements: media:content first, then media:thumbnail
+func getMediaRssImages(itm *gofeed.Item, baseUrl *url.URL) (res []*dal.TootAttachment) {
+
+	media := itm.Extensions["media"]
+	if media == nil {
+		return nil
+	}
+	type mediaElm struct {
+		elm, group *ext.Extension
+	}
+	var contents, thumbnails []mediaElm
+	collect := func(parent map[string][]ext.Extension, group *ext.Extension) {
+		for i := range parent["content"] {
+			contents = append(contents, mediaElm{&parent["content"][i], group})
+		}
+		for i := range parent["thumbnail"] {
+			thumbnails = append(thumbnails, mediaElm{&parent["thumbnail"][i], group})
+		}
+	}
+	collect(media, nil)
+	for i := range media["group"] {
+		collect(media["group"][i].Children, &media["group"][i])
+	}
+
+	for _, me := range contents {
+		if !isMediaContentImage(me.elm) {
+			continue
+		}
+		if imgUrl := resolveImageUrl(baseUrl, me.elm.Attrs["url"]); imgUrl != "" {
+			res = append(res, &dal.TootAttachment{
+				Type:      "Image",
+				MediaType: me.elm.Attrs["type"],
+				Url:       imgUrl,
+				Name:      getMediaAltText(me.elm, me.group),
+			})
+		}
+	}
+	if len(res) != 0 {
+		return res
+	}
+	for _, me := range thumbnails {
+		if imgUrl := resolveImageUrl(baseUrl, me.elm.Attrs["url"]); imgUrl != "" {
+			return []*dal.TootAttachment{{
+				Type: "Image",
+				Url:  imgUrl,
+				Name: getMediaAltText(me.elm, me.group),
+			}}
+		}
+	}
+	return nil
+}
+
+// First <img> in the item's content, or in its description
+func getFirstContentImage(itm *gofeed.Item, baseUrl *url.URL) *dal.TootAttachment {
+	for _, htm := range []string{itm.Content, itm.Description} {
+		if !strings.Contains(htm, "<img") {
+			continue
+		}
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(htm))
+		if err != nil {
+			continue
+		}
+		img := doc.Find("img[src]").First()
+		if imgUrl := resolveImageUrl(baseUrl, img.AttrOr("src", "")); imgUrl != "" {
+			return &dal.TootAttachment{
+				Type: "Image",
+				Url:  imgUrl,
+				Name: getAltText(img.AttrOr("alt", "")),
+			}
+		}
+	}
+	return nil
+}
+
+// Attachments we can find in the feed item itself, without fetching anything
+func getItemAttachments(itm *gofeed.Item) []*dal.TootAttachment {
+
+	baseUrl, _ := url.Parse(itm.Link)
+	var images, others []*dal.TootAttachment
+
+	images = getMediaRssImages(itm, baseUrl)
+	if len(images) == 0 && itm.Image != nil {
+		if imgUrl := resolveImageUrl(baseUrl, itm.Image.URL); imgUrl != "" {
+			images = append(images, &dal.TootAttachment{Type: "Image", Url: imgUrl, Name: getAltText(itm.Image.Title)})
+		}
+	}
+	for _, enc := range itm.Enclosures {
+		encUrl := resolveImageUrl(baseUrl, enc.URL)
+		if encUrl == "" {
+			continue
+		}
+		mediaType, _, _ := mime.ParseMediaType(enc.Type)
+		att := &dal.TootAttachment{
+			Type:      getAttachmentType(mediaType),
+			MediaType: mediaType,
+			Url:       encUrl,
+		}
+		if att.Type == "Image" {
+			images = append(images, att)
+		} else {
+			// Enclosures have no description of their own; the item's title is the best we have
+			att.Name = getAltText(itm.Title)
+			others = append(others, att)
+		}
+	}
+	if len(images) == 0 {
+		if img := getFirstContentImage(itm, baseUrl); img != nil {
+			images = append(images, img)
+		}
+	}
+
+	var res []*dal.TootAttachment
+	seen := make(map[string]bool)
+	for _, att := range append(images, others...) {
+		if seen[att.Url] || len(res) == maxTootAttachments {
+			continue
+		}
+		seen[att.Url] = true
+		res = append(res, att)
+	}
+	return res
+}
+
+// The og:image of the item's page, or nil
+func (ff *feedFollower) getArticleImage(itm *gofeed.Item) *dal.TootAttachment {
+	if itm.Link == "" {
+		return nil
+	}
+	doc, err := ff.fetchSiteDoc(itm.Link)
+	if err != nil {
+		ff.logger.Debugf("Failed to get article to look for image: %s: %v", itm.Link, err)
+		return nil
+	}
+	baseUrl, _ := url.Parse(itm.Link)
+	images := getMetaImages(doc, baseUrl, "og:image:secure_url", "og:image", "twitter:image")
+	for _, imgUrl := range images {
+		if imgUrl == "" {
+			continue
+		}
+		alt := ""
+		if alts := getMetaContents(doc, "og:image:alt", "twitter:image:alt"); len(alts) != 0 {
+			alt = getAltText(alts[0])
+		}
+		return &dal.TootAttachment{Type: "Image", Url: imgUrl, Name: alt}
+	}
+	return nil
+}
+
+// Attachments for the item's toot, with images replaced by our stored thumbnails.
+// Toots we don't send, like those for a new account's existing posts, don't get the article's image,
+// and link to the original images: storing them all would hold up creating the account.
+func (ff *feedFollower) getTootAttachments(user string, itm *gofeed.Item, sendToot bool) []*dal.TootAttachment {
+
+	atts := getItemAttachments(itm)
+	if !sendToot {
+		return atts
+	}
+	if !hasImageAttachment(atts) && len(atts) < maxTootAttachments {
+		if img := ff.getArticleImage(itm); img != nil {
+			atts = append([]*dal.TootAttachment{img}, atts...)
+		}
+	}
+
+	res := make([]*dal.TootAttachment, 0, len(atts))
+	for _, att := range atts {
+		if att.Type != "Image" {
+			res = append(res, att)
+			continue
+		}
+		localUrl, err := ff.media.Store(user, att.Url, MediaThumbnail)
+		if err != nil {
+			ff.logger.Infof("Leaving out image we failed to store: %s: %v", att.Url, err)
+			continue
+		}
+		if localUrl != "" && localUrl != att.Url {
+			att.Url = localUrl
+			att.MediaType = mime.TypeByExtension(path.Ext(localUrl))
+		}
+		res = append(res, att)
+	}
+	return res
+}
+
+func hasImageAttachment(atts []*dal.TootAttachment) bool {
+	for _, att := range atts {
+		if att.Type == "Image" {
+			return true
+		}
+	}
+	return false
+}
+
+func getNoteAttachments(atts []*dal.TootAttachment) []dto.Document {
+	if len(atts) == 0 {
+		return nil
+	}
+	res := make([]dto.Document, 0, len(atts))
+	for _, att := range atts {
+		res = append(res, dto.Document{
+			Type:      att.Type,
+			MediaType: att.MediaType,
+			Url:       att.Url,
+			Name:      att.Name,
+		})
+	}
+	return res
+}
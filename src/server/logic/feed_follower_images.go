@@ -0,0 +1,314 @@
+package logic
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/atom"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"rss_parrot/dal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile image candidates, best first:
+// - apple-touch-icon links on the site's page
+// - the Atom feed's <icon>
+// - the feed's image: RSS <image>, or the Atom feed's <logo>
+// - icon links on the site's page, largest first
+// Header image candidates: og:image and twitter:image metas on the site's page, then the Atom feed's <logo>.
+// A candidate is only used if it's an image in a format Fediverse servers accept, not too large, and,
+// where we can read its size, the right shape.
+
+const (
+	maxImageBytes         = 2 * 1024 * 1024
+	minProfileImageSize   = 48
+	maxProfileImageAspect = 2
+	minHeaderImageWidth   = 300
+	feedCustomAtomIcon    = "atom_icon"
+)
+
+var acceptedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Keeps the Atom feed's icon, which gofeed's translator drops, in the feed's custom values
+type atomIconTranslator struct {
+	gofeed.DefaultAtomTranslator
+}
+
+func (t *atomIconTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	res, err := t.DefaultAtomTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+	if atomFeed, ok := feed.(*atom.Feed); ok && atomFeed.Icon != "" {
+		if res.Custom == nil {
+			res.Custom = make(map[string]string)
+		}
+		res.Custom[feedCustomAtomIcon] = atomFeed.Icon
+	}
+	return res, nil
+}
+
+func newFeedParser() *gofeed.Parser {
+	fp := gofeed.NewParser()
+	fp.AtomTranslator = &atomIconTranslator{}
+	return fp
+}
+
+type iconLink struct {
+	url  string
+	size int
+}
+
+// Largest side from a sizes attribute like "32x32 192x192"; "any" (SVG) counts as large
+func getIconLinkSize(sizes string) int {
+	res := 0
+	for _, size := range strings.Fields(strings.ToLower(sizes)) {
+		if size == "any" {
+			return 1 << 16
+		}
+		w, h, found := strings.Cut(size, "x")
+		if !found {
+			continue
+		}
+		wVal, _ := strconv.Atoi(w)
+		hVal, _ := strconv.Atoi(h)
+		res = max(res, wVal, hVal)
+	}
+	return res
+}
+
+// URLs of <link> elements with any of the rel values, largest first
+func getIconLinks(doc *goquery.Document, baseUrl *url.URL, rels ...string) []string {
+	var links []iconLink
+	doc.Find("link[rel][href]").Each(func(_ int, s *goquery.Selection) {
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			for _, wanted := range rels {
+				if rel == wanted {
+					links = append(links, iconLink{resolveImageUrl(baseUrl, s.AttrOr("href", "")), getIconLinkSize(s.AttrOr("sizes", ""))})
+					return
+				}
+			}
+		}
+	})
+	sort.SliceStable(links, func(i, j int) bool { return links[i].size > links[j].size })
+	res := make([]string, 0, len(links))
+	for _, link := range links {
+		res = append(res, link.url)
+	}
+	return res
+}
+
+// Values of <meta> elements with any of the names, as property or name, in the order of the names
+func getMetaContents(doc *goquery.Document, names ...string) []string {
+	var res []string
+	for _, name := range names {
+		doc.Find("meta[content]").Each(func(_ int, s *goquery.Selection) {
+			if strings.EqualFold(s.AttrOr("property", ""), name) || strings.EqualFold(s.AttrOr("name", ""), name) {
+				res = append(res, s.AttrOr("content", ""))
+			}
+		})
+	}
+	return res
+}
+
+func getMetaImages(doc *goquery.Document, baseUrl *url.URL, names ...string) []string {
+	var res []string
+	for _, content := range getMetaContents(doc, names...) {
+		res = append(res, resolveImageUrl(baseUrl, content))
+	}
+	return res
+}
+
+// Absolute http(s) URL, or empty string if the reference is not usable
+func resolveImageUrl(baseUrl *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "data:") {
+		return ""
+	}
+	refUrl, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if baseUrl != nil {
+		refUrl = baseUrl.ResolveReference(refUrl)
+	}
+	if refUrl.Scheme != "http" && refUrl.Scheme != "https" || refUrl.Host == "" {
+		return ""
+	}
+	return refUrl.String()
+}
+
+// Collects image candidates from the site's page and the feed; either can be nil
+func getImageCandidates(siteUrl string, doc *goquery.Document, feedUrl string, feed *gofeed.Feed) (profile, header []string) {
+
+	parsedSiteUrl, _ := url.Parse(siteUrl)
+	parsedFeedUrl, _ := url.Parse(feedUrl)
+
+	var feedIcon, feedImage, atomLogo string
+	if feed != nil {
+		feedIcon = resolveImageUrl(parsedFeedUrl, feed.Custom[feedCustomAtomIcon])
+		if feed.Image != nil {
+			feedImage = resolveImageUrl(parsedFeedUrl, feed.Image.URL)
+			if feed.FeedType == "atom" {
+				atomLogo = feedImage
+			}
+		}
+	}
+
+	if doc != nil {
+		profile = append(profile, getIconLinks(doc, parsedSiteUrl, "apple-touch-icon", "apple-touch-icon-precomposed")...)
+		header = append(header, getMetaImages(doc, parsedSiteUrl, "og:image:secure_url", "og:image", "twitter:image")...)
+	}
+	profile = append(profile, feedIcon, feedImage)
+	header = append(header, atomLogo)
+	if doc != nil {
+		profile = append(profile, getIconLinks(doc, parsedSiteUrl, "icon")...)
+	}
+	return profile, header
+}
+
+// Fetches the image and checks that it's fit for a profile picture or a header.
+// Formats we cannot decode are accepted on their content type and size alone.
+func (ff *feedFollower) isImageUsable(imageUrl string, forHeader bool) error {
+
+	req, err := http.NewRequest("GET", imageUrl, nil)
+	if err != nil {
+		return err
+	}
+	ff.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = feedOrSiteTimeoutSec * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !acceptedImageTypes[mediaType] {
+		return fmt.Errorf("unsupported content type: '%s'", mediaType)
+	}
+	if resp.ContentLength > maxImageBytes {
+		return fmt.Errorf("image too large: %d bytes", resp.ContentLength)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxImageBytes {
+		return fmt.Errorf("image larger than %d bytes", maxImageBytes)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(body))
+	if err != nil {
+		if mediaType == "image/webp" {
+			return nil
+		}
+		return fmt.Errorf("failed to decode image: %v", err)
+	}
+	if forHeader {
+		if cfg.Width < minHeaderImageWidth || cfg.Width < cfg.Height {
+			return fmt.Errorf("image is %dx%d; not wide enough for a header", cfg.Width, cfg.Height)
+		}
+		return nil
+	}
+	shorter, longer := min(cfg.Width, cfg.Height), max(cfg.Width, cfg.Height)
+	if shorter < minProfileImageSize || longer > shorter*maxProfileImageAspect {
+		return fmt.Errorf("image is %dx%d; not fit for a profile picture", cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// First usable image among the candidates
+func (ff *feedFollower) pickImage(candidates []string, forHeader bool) string {
+	checked := make(map[string]bool)
+	for _, candidate := range candidates {
+		if candidate == "" || checked[candidate] {
+			continue
+		}
+		checked[candidate] = true
+		if err := ff.isImageUsable(candidate, forHeader); err != nil {
+			ff.logger.Debugf("Image not usable: %s: %v", candidate, err)
+			continue
+		}
+		return candidate
+	}
+	return ""
+}
+
+// Finds the profile and header images for a feed. Without the site's page, only the feed's images are checked.
+func (ff *feedFollower) discoverImages(siteUrl string, doc *goquery.Document, feedUrl string, feed *gofeed.Feed) (profileUrl, headerUrl string) {
+	profileCandidates, headerCandidates := getImageCandidates(siteUrl, doc, feedUrl, feed)
+	profileUrl = ff.pickImage(profileCandidates, false)
+	headerUrl = ff.pickImage(headerCandidates, true)
+	return
+}
+
+// Looks for images again if it's been long enough since we last did. An image we don't find this time
+// is kept, so that a site that's down for a while doesn't lose its parrot's looks.
+// Takes a copy of the account, as it runs alongside the rest of the feed check.
+func (ff *feedFollower) maybeRefreshImages(acct dal.Account, feed *gofeed.Feed) {
+
+	if ff.cfg.ImageRefreshHours <= 0 {
+		return
+	}
+	now := time.Now().UTC()
+	if now.Sub(acct.ImagesCheckedAt) < time.Duration(ff.cfg.ImageRefreshHours)*time.Hour {
+		return
+	}
+
+	var doc *goquery.Document
+	if acct.SiteUrl != "" {
+		var err error
+		if doc, err = ff.fetchSiteDoc(acct.SiteUrl); err != nil {
+			ff.logger.Infof("Failed to get site to look for images: %s: %v", acct.SiteUrl, err)
+		}
+	}
+	profileUrl, headerUrl := ff.discoverImages(acct.SiteUrl, doc, acct.FeedUrl, feed)
+	if profileUrl == "" {
+		profileUrl = acct.ProfileImageUrl
+	}
+	if headerUrl == "" {
+		headerUrl = acct.HeaderImageUrl
+	}
+	if profileUrl != acct.ProfileImageUrl || headerUrl != acct.HeaderImageUrl {
+		ff.logger.Infof("Images changed: %s: profile %s, header %s", acct.Handle, profileUrl, headerUrl)
+	}
+	if err := ff.repo.SetAccountImages(acct.Id, profileUrl, headerUrl, now); err != nil {
+		ff.logger.Errorf("Failed to store images: %s: %v", acct.Handle, err)
+		return
+	}
+	ff.storeImages(acct.Handle, profileUrl, headerUrl)
+}
+
+// Keeps local copies of the images; if that fails, we keep linking to the originals
+func (ff *feedFollower) storeImages(user, profileUrl, headerUrl string) {
+	if profileUrl != "" {
+		if _, err := ff.media.Store(user, profileUrl, MediaAvatar); err != nil {
+			ff.logger.Warnf("Failed to store profile image of %s: %v", user, err)
+		}
+	}
+	if headerUrl != "" {
+		if _, err := ff.media.Store(user, headerUrl, MediaHeader); err != nil {
+			ff.logger.Warnf("Failed to store header image of %s: %v", user, err)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package logic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strings"
+	"time"
+)
+
+// Publishers can tell us not to parrot them in three ways:
+// - robots.txt disallows our user agent (or all user agents) for the site or feed URL
+// - the site has <meta name="fediverse:parrot" content="noparrot">
+// - the feed has a channel-level <fediverse:parrot>noparrot</fediverse:parrot> element, in any namespace
+
+const (
+	optOutMetaName    = "fediverse:parrot"
+	optOutElementName = "parrot"
+	optOutValue       = "noparrot"
+	maxRobotsTxtBytes = 512 * 1024
+
+	defaultOptOutCheckHours = 24
+)
+
+var errOptedOut = errors.New("publisher opted out")
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	rx      *regexp.Regexp
+}
+
+// Rules from a robots.txt that apply to one user agent. Nil means everything is allowed.
+type robotsRules []robotsRule
+
+// Collects the rules of the groups for our product token, or of the * group if there's none for us.
+// https://www.rfc-editor.org/rfc/rfc9309
+func parseRobotsTxt(r io.Reader, product string) robotsRules {
+
+	var ourRules, anyRules robotsRules
+	var foundOurs bool
+	var groupAgents []string
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if ix := strings.IndexByte(line, '#'); ix != -1 {
+			line = line[:ix]
+		}
+		key, val, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "user-agent":
+			// User-agent after rules starts a new group
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(val))
+		case "allow", "disallow":
+			inRules = true
+			// Empty Disallow allows everything: same as no rule
+			if val == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: val, rx: compileRobotsPattern(val)}
+			for _, agent := range groupAgents {
+				if agent == strings.ToLower(product) {
+					foundOurs = true
+					ourRules = append(ourRules, rule)
+				} else if agent == "*" {
+					anyRules = append(anyRules, rule)
+				}
+			}
+		}
+	}
+	if foundOurs {
+		return ourRules
+	}
+	return anyRules
+}
+
+// Turns a robots.txt path pattern, with * wildcards and an optional $ end anchor, into a regex
+func compileRobotsPattern(pattern string) *regexp.Regexp {
+
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// Most specific matching rule wins; if an Allow and a Disallow are equally specific, Allow wins
+func (rr robotsRules) isAllowed(path string) bool {
+
+	allowed := true
+	matchLen := -1
+	for _, rule := range rr {
+		if !rule.rx.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > matchLen || len(rule.pattern) == matchLen && rule.allow {
+			allowed = rule.allow
+			matchLen = len(rule.pattern)
+		}
+	}
+	return allowed
+}
+
+func isDocOptedOut(doc *goquery.Document) bool {
+	optedOut := false
+	doc.Find("meta[name]").Each(func(_ int, s *goquery.Selection) {
+		if strings.EqualFold(s.AttrOr("name", ""), optOutMetaName) && hasOptOutValue(s.AttrOr("content", "")) {
+			optedOut = true
+		}
+	})
+	return optedOut
+}
+
+func isFeedOptedOut(feed *gofeed.Feed) bool {
+	for _, elms := range feed.Extensions {
+		for _, elm := range elms[optOutElementName] {
+			if hasOptOutValue(elm.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasOptOutValue(val string) bool {
+	for _, token := range strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if strings.EqualFold(token, optOutValue) {
+			return true
+		}
+	}
+	return false
+}
+
+// Retrieves the rules in the robots.txt of the URL's host that apply to us. Missing robots.txt allows everything.
+func (ff *feedFollower) getRobotsRules(urlStr string) (robotsRules, error) {
+
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, err
+	}
+	robotsUrl := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	var req *http.Request
+	if req, err = http.NewRequest("GET", robotsUrl.String(), nil); err != nil {
+		return nil, err
+	}
+	ff.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = feedOrSiteTimeoutSec * time.Second
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode >= 500 {
+			return nil, fmt.Errorf("request for %s failed with status %d", robotsUrl.String(), resp.StatusCode)
+		}
+		return nil, nil
+	}
+	return parseRobotsTxt(io.LimitReader(resp.Body, maxRobotsTxtBytes), shared.UserAgentProduct), nil
+}
+
+// Rules from the robots.txt of hosts we've already checked, by scheme and host.
+// Lets the checks for one feed fetch each host's robots.txt only once.
+type robotsCache map[string]robotsRules
+
+// True if robots.txt disallows us for any of the URLs
+func (ff *feedFollower) isDisallowedByRobots(rulesByHost robotsCache, urls ...string) bool {
+
+	for _, urlStr := range urls {
+		u, err := url.Parse(urlStr)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		hostKey := u.Scheme + "://" + strings.ToLower(u.Host)
+		rules, checked := rulesByHost[hostKey]
+		if !checked {
+			if rules, err = ff.getRobotsRules(urlStr); err != nil {
+				// Rather than dropping a feed for good because of a server hiccup, assume we're allowed
+				ff.logger.Warnf("Failed to get robots.txt for %s: %v", urlStr, err)
+			}
+			rulesByHost[hostKey] = rules
+		}
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		if u.RawQuery != "" {
+			path += "?" + u.RawQuery
+		}
+		if !rules.isAllowed(path) {
+			ff.logger.Infof("robots.txt disallows us: %s", urlStr)
+			return true
+		}
+	}
+	return false
+}
+
+// Fetches and parses the site's page
+func (ff *feedFollower) fetchSiteDoc(siteUrl string) (*goquery.Document, error) {
+
+	req, err := http.NewRequest("GET", siteUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	ff.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = feedOrSiteTimeoutSec * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request for %s failed with status %d", siteUrl, resp.StatusCode)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+// Fetches the site's page and looks for the opt-out meta tag
+func (ff *feedFollower) isSiteOptedOut(siteUrl string) (bool, error) {
+	doc, err := ff.fetchSiteDoc(siteUrl)
+	if err != nil {
+		return false, err
+	}
+	return isDocOptedOut(doc), nil
+}
+
+// Checks robots.txt and the site again if it's been long enough since we last did.
+// Returns true if the publisher has opted out.
+func (ff *feedFollower) recheckOptOut(acct *dal.Account) bool {
+
+	checkHours := defaultOptOutCheckHours
+	if ff.cfg.OptOutCheckHours < 0 {
+		return false
+	} else if ff.cfg.OptOutCheckHours > 0 {
+		checkHours = ff.cfg.OptOutCheckHours
+	}
+	now := time.Now().UTC()
+	if now.Sub(acct.OptOutCheckedAt) < time.Duration(checkHours)*time.Hour {
+		return false
+	}
+	if err := ff.repo.SetAccountOptOutChecked(acct.Id, now); err != nil {
+		ff.logger.Errorf("Failed to record opt-out check: %s: %v", acct.Handle, err)
+	}
+
+	urls := []string{acct.FeedUrl}
+	if acct.SiteUrl != "" {
+		urls = append(urls, acct.SiteUrl)
+	}
+	if ff.isDisallowedByRobots(make(robotsCache), urls...) {
+		return true
+	}
+	if acct.SiteUrl == "" {
+		return false
+	}
+	optedOut, err := ff.isSiteOptedOut(acct.SiteUrl)
+	if err != nil {
+		// Site may be down for a while; the feed is what we really care about
+		ff.logger.Warnf("Failed to check site for opt-out: %s: %v", acct.SiteUrl, err)
+		return false
+	}
+	return optedOut
+}
+
+func (ff *feedFollower) stopOptedOutFeed(acct *dal.Account) error {
+	ff.logger.Warnf("Publisher opted out; no longer checking feed: %s: %s", acct.Handle, acct.FeedUrl)
+	ff.metrics.FeedCheckProblem("opted-out")
+	return ff.repo.SetAccountPollStatus(acct.Id, dal.PollOptedOut)
+}
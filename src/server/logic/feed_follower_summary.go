@@ -0,0 +1,284 @@
+package logic
+
+import (
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Toots show the item's description as HTML, limited to what Mastodon keeps anyway:
+// paragraphs, line breaks, links, emphasis and lists. Headings, divs and blockquotes become paragraphs;
+// everything else is dropped, keeping its text. Relative links are resolved against the item's URL.
+// The summary is cut to shared.MaxDescriptionLen characters of text. Elements that fit are kept whole;
+// the first one that doesn't is cut at a word, and all open elements are closed.
+// Descriptions that are plain text, or that have no usable markup, are shown as a single paragraph.
+
+var summaryPolicy = newSummaryPolicy()
+
+func newSummaryPolicy() *bluemonday.Policy {
+	pol := bluemonday.NewPolicy()
+	pol.AllowElements("p", "br", "em", "strong", "b", "i", "ul", "ol", "li",
+		"div", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6")
+	pol.AllowAttrs("href").OnElements("a")
+	pol.AllowURLSchemes("http", "https")
+	pol.AllowRelativeURLs(true)
+	pol.RequireParseableURLs(true)
+	return pol
+}
+
+// Elements we keep, under the name we keep them as
+var summaryInlineTags = map[string]string{
+	"a":      "a",
+	"em":     "em",
+	"i":      "em",
+	"strong": "strong",
+	"b":      "strong",
+}
+
+var summaryBlockTags = map[string]bool{
+	"p": true, "div": true, "blockquote": true, "ul": true, "ol": true, "li": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+type summarizer struct {
+	sb      strings.Builder
+	baseUrl *url.URL
+	left    int
+	done    bool
+}
+
+// HTML summary of the item's description, or empty string if it has no text
+func getSummaryHtml(description, link string, maxLen int) string {
+
+	if !strings.Contains(description, "<") {
+		return getPlainSummaryHtml(description, maxLen)
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(summaryPolicy.Sanitize(description)), root)
+	if err != nil {
+		return getPlainSummaryHtml(description, maxLen)
+	}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	s := summarizer{left: maxLen}
+	s.baseUrl, _ = url.Parse(link)
+	s.writeBlocks(root)
+	res := s.sb.String()
+	if strings.TrimSpace(stripHtml(res)) == "" {
+		return getPlainSummaryHtml(description, maxLen)
+	}
+	return res
+}
+
+func getPlainSummaryHtml(description string, maxLen int) string {
+	plain := truncateText(stripHtml(description), maxLen)
+	if plain == "" {
+		return ""
+	}
+	return "<p>" + html.EscapeString(plain) + "</p>"
+}
+
+// Like shared.TruncateWithEllipsis, but counts characters, not bytes, and copes with maxLen of zero
+func truncateText(text string, maxLen int) string {
+	if utf8.RuneCountInString(text) <= maxLen {
+		return text
+	}
+	cutIx, lastSpaceIx := len(text), -1
+	count := 0
+	for i, r := range text {
+		if count == maxLen {
+			cutIx = i
+			break
+		}
+		if unicode.IsSpace(r) {
+			lastSpaceIx = i
+		}
+		count++
+	}
+	if lastSpaceIx > 0 {
+		cutIx = lastSpaceIx
+	}
+	return strings.TrimRightFunc(text[:cutIx], unicode.IsSpace) + "…"
+}
+
+func isInlineNode(n *html.Node) bool {
+	return n.Type == html.TextNode || n.Type == html.ElementNode && !summaryBlockTags[n.Data]
+}
+
+// Children of a block container: inline runs are wrapped in paragraphs, containers are flattened
+func (s *summarizer) writeBlocks(parent *html.Node) {
+	for c := parent.FirstChild; c != nil && !s.done; {
+		if isInlineNode(c) {
+			var run []*html.Node
+			for ; c != nil && isInlineNode(c); c = c.NextSibling {
+				run = append(run, c)
+			}
+			if nodeHasText(run) {
+				s.writeParagraph(run)
+			}
+			continue
+		}
+		switch c.Data {
+		case "div", "blockquote":
+			s.writeBlocks(c)
+		case "ul", "ol":
+			s.writeList(c)
+		case "li":
+			s.writeParagraph(nodeChildren(c))
+		default:
+			if nodeHasText(nodeChildren(c)) {
+				s.writeParagraph(nodeChildren(c))
+			}
+		}
+		c = c.NextSibling
+	}
+}
+
+func (s *summarizer) writeParagraph(nodes []*html.Node) {
+	if s.left == 0 {
+		s.done = true
+		return
+	}
+	s.sb.WriteString("<p>")
+	s.writeInline(nodes)
+	s.sb.WriteString("</p>")
+}
+
+func (s *summarizer) writeList(list *html.Node) {
+	var items []*html.Node
+	for c := list.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Data == "li" {
+			items = append(items, c)
+		}
+	}
+	if s.left == 0 {
+		s.done = true
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	s.sb.WriteString("<" + list.Data + ">")
+	for _, item := range items {
+		if s.done {
+			break
+		}
+		if s.left == 0 {
+			s.done = true
+			break
+		}
+		s.sb.WriteString("<li>")
+		for c := item.FirstChild; c != nil && !s.done; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.Data == "ul" || c.Data == "ol") {
+				s.writeList(c)
+			} else {
+				s.writeInline([]*html.Node{c})
+			}
+		}
+		s.sb.WriteString("</li>")
+	}
+	s.sb.WriteString("</" + list.Data + ">")
+}
+
+// Text, links, emphasis and line breaks; the tags of anything else are left out
+func (s *summarizer) writeInline(nodes []*html.Node) {
+	for _, n := range nodes {
+		if s.done {
+			return
+		}
+		switch {
+		case n.Type == html.TextNode:
+			s.writeText(n.Data)
+		case n.Type != html.ElementNode:
+			continue
+		case n.Data == "br":
+			s.sb.WriteString("<br>")
+		case !nodeHasText(nodeChildren(n)):
+			// Links around images we dropped, empty emphasis
+			continue
+		case summaryInlineTags[n.Data] == "a":
+			href := resolveImageUrl(s.baseUrl, getNodeAttr(n, "href"))
+			if href == "" {
+				s.writeInline(nodeChildren(n))
+				continue
+			}
+			s.sb.WriteString(`<a href="` + html.EscapeString(href) + `">`)
+			s.writeInline(nodeChildren(n))
+			s.sb.WriteString("</a>")
+		case summaryInlineTags[n.Data] != "":
+			tag := summaryInlineTags[n.Data]
+			s.sb.WriteString("<" + tag + ">")
+			s.writeInline(nodeChildren(n))
+			s.sb.WriteString("</" + tag + ">")
+		default:
+			s.writeInline(nodeChildren(n))
+		}
+	}
+}
+
+func (s *summarizer) writeText(text string) {
+	text = collapseSpaces(text)
+	if text == "" {
+		return
+	}
+	count := utf8.RuneCountInString(text)
+	if count > s.left {
+		text = truncateText(text, s.left)
+		s.done = true
+		count = s.left
+	}
+	s.sb.WriteString(html.EscapeString(text))
+	s.left -= count
+}
+
+func collapseSpaces(text string) string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+	res := strings.Join(words, " ")
+	if first, _ := utf8.DecodeRuneInString(text); unicode.IsSpace(first) {
+		res = " " + res
+	}
+	if last, _ := utf8.DecodeLastRuneInString(text); unicode.IsSpace(last) {
+		res += " "
+	}
+	return res
+}
+
+func nodeChildren(n *html.Node) []*html.Node {
+	var res []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		res = append(res, c)
+	}
+	return res
+}
+
+func nodeHasText(nodes []*html.Node) bool {
+	for _, n := range nodes {
+		if n.Type == html.TextNode && strings.TrimSpace(n.Data) != "" {
+			return true
+		}
+		if n.Type == html.ElementNode && nodeHasText(nodeChildren(n)) {
+			return true
+		}
+	}
+	return false
+}
+
+func getNodeAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
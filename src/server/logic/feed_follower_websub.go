@@ -0,0 +1,309 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/mmcdole/gofeed"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://www.w3.org/TR/websub/
+
+const (
+	webSubLeaseSec   = 10 * 24 * 60 * 60 // Lease we ask hubs for
+	webSubRenewHours = 48                // Renew subscription when lease has less than this left
+	webSubRetryHours = 24                // Ask again if hub hasn't verified our request after this long
+	webSubPollHours  = 24                // Safety-net polling while subscription is active
+	webSubTimeoutSec = 10
+)
+
+func isWebSubActive(acct *dal.Account, now time.Time) bool {
+	return acct.WebSubHub != "" && acct.WebSubExpires.After(now)
+}
+
+// Like getNextCheckTime, but polls much less often while feed has an active WebSub subscription
+func (ff *feedFollower) getAccountNextCheckTime(acct *dal.Account, lastChanged time.Time) time.Time {
+
+	res := ff.getNextCheckTime(lastChanged)
+	now := time.Now()
+	if !isWebSubActive(acct, now) {
+		return res
+	}
+	// Don't wait past end of lease: if it lapses, we're back to regular polling
+	pushed := now.Add(webSubPollHours * time.Hour)
+	if pushed.After(acct.WebSubExpires) {
+		pushed = acct.WebSubExpires
+	}
+	if pushed.After(res) {
+		res = pushed
+	}
+	return res
+}
+
+// Finds the hub and self URLs in a feed's Link headers or in its channel-level link elements
+func getWebSubLinks(header http.Header, body []byte) (hub, self string) {
+
+	for _, val := range header.Values("Link") {
+		for _, link := range strings.Split(val, ",") {
+			parts := strings.Split(link, ";")
+			href := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), `"`, "")
+				if param == "rel=hub" && hub == "" {
+					hub = href
+				} else if param == "rel=self" && self == "" {
+					self = href
+				}
+			}
+		}
+	}
+	if hub != "" {
+		return
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.Strict = false
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		// Hub links belong to the channel, not to individual posts
+		if se.Name.Local == "item" || se.Name.Local == "entry" {
+			return
+		}
+		if se.Name.Local != "link" {
+			continue
+		}
+		var rel, href string
+		for _, attr := range se.Attr {
+			if attr.Name.Local == "rel" {
+				rel = attr.Value
+			} else if attr.Name.Local == "href" {
+				href = attr.Value
+			}
+		}
+		if rel == "hub" && hub == "" {
+			hub = href
+		} else if rel == "self" && self == "" {
+			self = href
+		}
+	}
+}
+
+// Subscribes to feed's hub if we're not subscribed yet, or if our lease is running out
+func (ff *feedFollower) maybeSubscribeWebSub(acct *dal.Account, hub, topic string) {
+
+	if hub == "" {
+		return
+	}
+	now := time.Now()
+	if hub == acct.WebSubHub && topic == acct.WebSubTopic {
+		if isWebSubActive(acct, now) && acct.WebSubExpires.Sub(now) > webSubRenewHours*time.Hour {
+			return
+		}
+		if !isWebSubActive(acct, now) && now.Sub(acct.WebSubRequestedAt) < webSubRetryHours*time.Hour {
+			return
+		}
+	}
+	go func() {
+		if err := ff.subscribeWebSub(acct, hub, topic); err != nil {
+			ff.logger.Warnf("Failed to subscribe to WebSub hub %s for %s: %v", hub, acct.Handle, err)
+			ff.metrics.WebSubEvent("request_failed")
+		}
+	}()
+}
+
+func (ff *feedFollower) subscribeWebSub(acct *dal.Account, hub, topic string) error {
+
+	var err error
+	ff.logger.Infof("Subscribing to WebSub hub %s for %s: %s", hub, acct.Handle, topic)
+
+	// Keep secret when renewing, so deliveries signed with it stay valid while hub processes our request
+	var secret string
+	if hub == acct.WebSubHub && topic == acct.WebSubTopic {
+		if secret, err = ff.repo.GetWebSubSecret(acct.Id); err != nil {
+			return err
+		}
+	}
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err = rand.Read(buf); err != nil {
+			return err
+		}
+		secret = hex.EncodeToString(buf)
+	}
+	if err = ff.repo.SetAccountWebSub(acct.Id, hub, topic, secret, time.Now()); err != nil {
+		return err
+	}
+
+	idb := shared.IdBuilder{Host: ff.cfg.Host}
+	form := url.Values{}
+	form.Set("hub.mode", "subscribe")
+	form.Set("hub.topic", topic)
+	form.Set("hub.callback", idb.WebSubCallback(acct.Handle))
+	form.Set("hub.secret", secret)
+	form.Set("hub.lease_seconds", strconv.Itoa(webSubLeaseSec))
+
+	var req *http.Request
+	if req, err = http.NewRequest("POST", hub, strings.NewReader(form.Encode())); err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ff.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = webSubTimeoutSec * time.Second
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hub responded with status %d", resp.StatusCode)
+	}
+	ff.metrics.WebSubEvent("requested")
+	return nil
+}
+
+func (ff *feedFollower) VerifyWebSubIntent(user, mode, topic string, leaseSeconds int) (confirmed bool, err error) {
+
+	var acct *dal.Account
+	if acct, err = ff.repo.GetAccount(user); err != nil {
+		return false, err
+	}
+	if acct == nil || acct.WebSubHub == "" || topic != acct.WebSubTopic {
+		ff.logger.Infof("Rejecting WebSub %s for %s that we didn't ask for: %s", mode, user, topic)
+		ff.metrics.WebSubEvent("unexpected")
+		return false, nil
+	}
+
+	switch mode {
+	case "subscribe":
+		if leaseSeconds <= 0 {
+			leaseSeconds = webSubLeaseSec
+		}
+		expires := time.Now().Add(time.Duration(leaseSeconds) * time.Second)
+		if err = ff.repo.SetAccountWebSubExpires(acct.Id, expires); err != nil {
+			return false, err
+		}
+		ff.logger.Infof("WebSub subscription for %s verified until %s", user, expires.Format(time.RFC3339))
+		ff.metrics.WebSubEvent("verified")
+		return true, nil
+	case "denied":
+		// We'll ask again after webSubRetryHours; meanwhile we just keep polling
+		if err = ff.repo.SetAccountWebSubExpires(acct.Id, time.Time{}); err != nil {
+			return false, err
+		}
+		ff.logger.Infof("WebSub hub denied subscription for %s", user)
+		ff.metrics.WebSubEvent("denied")
+		return true, nil
+	default:
+		// We don't unsubscribe from feeds we still have
+		ff.logger.Infof("Rejecting WebSub %s for %s", mode, user)
+		ff.metrics.WebSubEvent("unexpected")
+		return false, nil
+	}
+}
+
+// Checks X-Hub-Signature header, which looks like "sha256=<hex digest of body>"
+func checkWebSubSignature(secret string, body []byte, signature string) bool {
+
+	method, sigHex, found := strings.Cut(signature, "=")
+	if !found {
+		return false
+	}
+	var newHash func() hash.Hash
+	switch strings.ToLower(method) {
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	case "sha384":
+		newHash = sha512.New384
+	case "sha512":
+		newHash = sha512.New
+	default:
+		return false
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func (ff *feedFollower) HandleWebSubContent(user string, body []byte, signature string) error {
+
+	var err error
+	var acct *dal.Account
+	if acct, err = ff.repo.GetAccount(user); err != nil {
+		return err
+	}
+	if acct == nil || acct.WebSubHub == "" {
+		ff.logger.Infof("Ignoring WebSub content for %s: no subscription", user)
+		ff.metrics.WebSubEvent("unexpected")
+		return nil
+	}
+	if acct.PollStatus != dal.PollActive {
+		ff.logger.Infof("Ignoring WebSub content for %s: feed is %s", user, acct.PollStatus)
+		ff.metrics.WebSubEvent("unexpected")
+		return nil
+	}
+
+	// Content with a missing or bad signature must be ignored, but we still acknowledge it
+	var secret string
+	if secret, err = ff.repo.GetWebSubSecret(acct.Id); err != nil {
+		return err
+	}
+	if secret == "" || !checkWebSubSignature(secret, body, signature) {
+		ff.logger.Warnf("Ignoring WebSub content for %s with invalid signature", user)
+		ff.metrics.WebSubEvent("bad_signature")
+		return nil
+	}
+
+	fp := newFeedParser()
+	var feed *gofeed.Feed
+	if feed, err = fp.Parse(bytes.NewReader(body)); err != nil {
+		return err
+	}
+	ff.logger.Infof("Received WebSub content for %s with %d items", user, len(feed.Items))
+	ff.metrics.WebSubEvent("delivered")
+
+	// A scheduled poll of the same account may bring the same items at the same time
+	unlock := ff.lockAccount(acct.Id)
+	defer unlock()
+	// While we waited for the lock, that poll may have found the feed gone, or stopped it for other reasons
+	if acct, err = ff.repo.GetAccount(user); err != nil {
+		return err
+	}
+	if acct == nil || acct.PollStatus != dal.PollActive {
+		ff.logger.Infof("Ignoring WebSub content for %s: feed was stopped", user)
+		return nil
+	}
+	if isFeedOptedOut(feed) {
+		return ff.stopOptedOutFeed(acct)
+	}
+	return ff.updateAccountPosts(acct, feed, true, false)
+}
@@ -0,0 +1,56 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"rss_parrot/dal"
+	"strings"
+)
+
+// Followers collection synchronization, as implemented by Mastodon:
+// https://github.com/mastodon/mastodon/pull/14510
+// Deliveries to followers carry a digest of the followers on the receiving host.
+// If it doesn't match the receiver's own records, the receiver fetches the partial followers collection
+// for its domain, and removes follows that we don't know about.
+
+const collectionSyncHeader = "Collection-Synchronization"
+
+// Returns the lowercase host of a URL, or an empty string if it's not a valid URL.
+func getUrlHost(urlStr string) string {
+	parsedUrl, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsedUrl.Host)
+}
+
+// Returns the actor URLs of followers whose actor is on the given host.
+func getFollowersOnHost(followers []*dal.FollowerInfo, host string) []string {
+	var res []string
+	for _, fi := range followers {
+		if getUrlHost(fi.UserUrl) == host {
+			res = append(res, fi.UserUrl)
+		}
+	}
+	return res
+}
+
+// XORs the SHA256 hashes of the actor URLs, and returns the result as lowercase hex.
+func getFollowersDigest(actorUrls []string) string {
+	var digest [sha256.Size]byte
+	for _, actorUrl := range actorUrls {
+		hash := sha256.Sum256([]byte(actorUrl))
+		for i := range digest {
+			digest[i] ^= hash[i]
+		}
+	}
+	return hex.EncodeToString(digest[:])
+}
+
+// Returns the value of the Collection-Synchronization header for a delivery to an inbox on the given host.
+func getCollectionSyncValue(followersUrl, syncUrl string, followers []*dal.FollowerInfo, host string) string {
+	digest := getFollowersDigest(getFollowersOnHost(followers, host))
+	return fmt.Sprintf(`collectionId="%s", url="%s", digest="%s"`, followersUrl, syncUrl, digest)
+}
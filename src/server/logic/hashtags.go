@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"github.com/mmcdole/gofeed"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/shared"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Feed items' categories become hashtags, so that parrots' toots show up in hashtag searches.
+// A category of several words becomes a single CamelCase hashtag: "open source" is #OpenSource.
+// Markup, and anything but letters, digits and underscores, is dropped; hashtags without a letter, or too long
+// to be a real tag, are left out. Toots get the first few distinct hashtags, unless the account turned them off.
+
+const (
+	maxTootHashtags = 5
+	maxHashtagLen   = 30
+)
+
+var apostropheRemover = strings.NewReplacer("'", "", "’", "")
+
+func isHashtagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+// Hashtag from a category, without the leading #, or empty string if the category doesn't make one
+func normalizeHashtag(category string) string {
+
+	category = strings.TrimPrefix(stripHtml(category), "#")
+	words := strings.FieldsFunc(apostropheRemover.Replace(category), func(r rune) bool { return !isHashtagRune(r) })
+	if len(words) > 1 {
+		for i, word := range words {
+			first, size := utf8.DecodeRuneInString(word)
+			words[i] = string(unicode.ToUpper(first)) + word[size:]
+		}
+	}
+	res := strings.Join(words, "")
+	if utf8.RuneCountInString(res) > maxHashtagLen || strings.IndexFunc(res, unicode.IsLetter) == -1 {
+		return ""
+	}
+	return res
+}
+
+// Distinct hashtags from the categories, at most maxTootHashtags of them.
+// Categories from iTunes keywords may hold several comma-separated values.
+func getHashtags(categories []string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, category := range categories {
+		for _, part := range strings.Split(category, ",") {
+			hashtag := normalizeHashtag(part)
+			key := strings.ToLower(hashtag)
+			if hashtag == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			res = append(res, hashtag)
+			if len(res) == maxTootHashtags {
+				return res
+			}
+		}
+	}
+	return res
+}
+
+func getTootHashtags(acct *dal.Account, itm *gofeed.Item) []string {
+	if acct.NoHashtags {
+		return nil
+	}
+	return getHashtags(itm.Categories)
+}
+
+// Paragraph of hashtag links that goes at the end of the toot, or empty string if there are no hashtags
+func (ff *feedFollower) getHashtagsHtml(user string, hashtags []string) string {
+	if len(hashtags) == 0 {
+		return ""
+	}
+	idb := shared.IdBuilder{ff.cfg.Host}
+	links := make([]string, 0, len(hashtags))
+	for _, hashtag := range hashtags {
+		links = append(links, ff.txt.WithVals("toot_hashtag.html", map[string]string{
+			"url":     idb.UserHashtag(user, hashtag),
+			"hashtag": hashtag,
+		}))
+	}
+	return "<p>" + strings.Join(links, " ") + "</p>"
+}
+
+func getNoteHashtags(idb *shared.IdBuilder, user string, hashtags []string) *[]dto.Tag {
+	if len(hashtags) == 0 {
+		return nil
+	}
+	res := make([]dto.Tag, 0, len(hashtags))
+	for _, hashtag := range hashtags {
+		res = append(res, dto.Tag{
+			Type: "Hashtag",
+			Href: idb.UserHashtag(user, hashtag),
+			Name: "#" + hashtag,
+		})
+	}
+	return &res
+}
@@ -0,0 +1,76 @@
+package logic
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits how many feeds on the same host are checked at the same time.
+// The dispatcher only hands a worker an account whose host has a free slot, and doesn't claim accounts
+// on busy hosts, so one slow or popular host doesn't hold up feeds on other hosts.
+type hostGate struct {
+	mu     sync.Mutex
+	limit  int
+	active map[string]int
+	freed  chan struct{}
+}
+
+func newHostGate(limit int) *hostGate {
+	return &hostGate{
+		limit:  limit,
+		active: make(map[string]int),
+		freed:  make(chan struct{}, 1),
+	}
+}
+
+// Takes a slot on host and returns true, or returns false if host has no free slot.
+func (g *hostGate) tryEnter(host string) bool {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.active[host] < g.limit {
+		g.active[host]++
+		return true
+	}
+	return false
+}
+
+// Hosts that have no free slot
+func (g *hostGate) getBusyHosts() map[string]bool {
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	res := make(map[string]bool)
+	for host, count := range g.active {
+		if count >= g.limit {
+			res[host] = true
+		}
+	}
+	return res
+}
+
+// Frees caller's slot on host, and wakes up anyone waiting for a free slot.
+func (g *hostGate) leave(host string) {
+
+	g.mu.Lock()
+	g.active[host]--
+	if g.active[host] == 0 {
+		delete(g.active, host)
+	}
+	g.mu.Unlock()
+
+	select {
+	case g.freed <- struct{}{}:
+	default:
+	}
+}
+
+// Waits until a slot is freed on any host, or timeout passes.
+func (g *hostGate) waitForFree(timeout time.Duration) {
+	select {
+	case <-g.freed:
+	case <-time.After(timeout):
+	}
+}
@@ -14,6 +14,8 @@ import (
 
 type IHttpSigChecker interface {
 	Check(actor string, w http.ResponseWriter, r *http.Request) (*dto.UserInfo, string, error)
+	// Like Check, for requests with no activity in the body: the actor is taken from the signature's keyId.
+	CheckByKeyId(w http.ResponseWriter, r *http.Request) (*dto.UserInfo, string, error)
 }
 
 type httpSigChecker struct {
@@ -67,3 +69,15 @@ func (chk *httpSigChecker) Check(actor string, w http.ResponseWriter, r *http.Re
 
 	return userInfo, "", nil
 }
+
+func (chk *httpSigChecker) CheckByKeyId(w http.ResponseWriter, r *http.Request) (*dto.UserInfo, string, error) {
+
+	var sigHeader = r.Header.Get("Signature")
+	groups := chk.reKeyId.FindStringSubmatch(sigHeader)
+	if groups == nil {
+		return nil, "Missing or invalid 'Signature' header", nil
+	}
+	// keyId is the actor's URL, plus a fragment like #main-key
+	actor, _, _ := strings.Cut(groups[1], "#")
+	return chk.Check(actor, w, r)
+}
@@ -17,12 +17,16 @@ type IInbox interface {
 	HandleFollow(receivingUser string, senderInfo *dto.UserInfo, bodyBytes []byte) (string, error)
 	HandleUndo(receivingUser string, senderInfo *dto.UserInfo, bodyBytes []byte) (string, error)
 	HandleCreateNote(actBase dto.ActivityInBase, senderInfo *dto.UserInfo, bodyBytes []byte) (string, error)
+	// senderInfo is nil if the signature could not be verified, which is expected if the actor has been deleted
+	HandleDelete(senderInfo *dto.UserInfo, bodyBytes []byte) (string, error)
+	HandleMove(senderInfo *dto.UserInfo, bodyBytes []byte) (string, error)
 }
 
 const (
 	firstPurgeDelayMin     = 1
 	purgeActivitiesLoopMin = 60
 	activitiesKeptHr       = 48
+	defaultTombstoneDays   = 90
 )
 
 type inbox struct {
@@ -37,6 +41,8 @@ type inbox struct {
 	sender          IActivitySender
 	messenger       IMessenger
 	fdfol           IFeedFollower
+	userRetriever   IUserRetriever
+	domainBlocks    IDomainBlocks
 	reUserUrlParser *regexp.Regexp
 	reHttps         *regexp.Regexp
 }
@@ -52,12 +58,14 @@ func NewInbox(
 	sender IActivitySender,
 	messenger IMessenger,
 	fdfol IFeedFollower,
+	userRetriever IUserRetriever,
+	domainBlocks IDomainBlocks,
 ) IInbox {
 
 	reUserUrlParser := regexp.MustCompile("https://" + cfg.Host + "/u/([^/]+)/?")
 	reHttps := regexp.MustCompile("https?://[^ ]+")
 	res := inbox{cfg, logger, shared.IdBuilder{cfg.Host}, repo, txt, metrics, udir,
-		keyStore, sender, messenger, fdfol,
+		keyStore, sender, messenger, fdfol, userRetriever, domainBlocks,
 		reUserUrlParser, reHttps}
 
 	go res.purgeOldAvititiesLoop()
@@ -77,6 +85,14 @@ func (ib *inbox) purgeOldAvititiesLoop() {
 		if err != nil {
 			ib.logger.Errorf("Failed to purge old handled activities: %v", err)
 		}
+		tombstoneDays := defaultTombstoneDays
+		if ib.cfg.TombstoneDays > 0 {
+			tombstoneDays = ib.cfg.TombstoneDays
+		}
+		before = time.Now().UTC().AddDate(0, 0, -tombstoneDays)
+		if err = ib.repo.DeleteDeletedToots(before); err != nil {
+			ib.logger.Errorf("Failed to purge old deleted toots: %v", err)
+		}
 		time.Sleep(time.Minute * purgeActivitiesLoopMin)
 	}
 }
@@ -135,13 +151,25 @@ func (ib *inbox) HandleFollow(
 		return
 	}
 
+	// Sent to shared inbox without a recipient: object tells us who is being followed
+	if receivingUser == "" {
+		if groups := ib.reUserUrlParser.FindStringSubmatch(actFollow.Object); groups != nil {
+			receivingUser = groups[1]
+		}
+	}
+	if receivingUser == "" {
+		reqProblem = fmt.Sprintf("Follow object is not a user here: %s", actFollow.Object)
+		return
+	}
+
 	var account *dal.Account
 	account, err = ib.repo.GetAccount(receivingUser)
 	if err != nil {
 		return "", err
 	}
 	if account == nil {
-		reqProblem = fmt.Sprintf("User does not exist: %s", receivingUser)
+		ib.logger.Infof("Rejecting follow of non-existent user: %s", receivingUser)
+		ib.rejectFollow(&actFollow, senderInfo, receivingUser)
 		return
 	}
 
@@ -165,6 +193,13 @@ func (ib *inbox) HandleFollow(
 		return
 	}
 
+	// We don't let users of blocked instances follow us
+	if ib.domainBlocks.IsBlocked(actFollow.Actor) {
+		ib.logger.Infof("Rejecting follow from blocked domain: %s", actFollow.Actor)
+		ib.rejectFollow(&actFollow, senderInfo, receivingUser)
+		return
+	}
+
 	// Store new follower
 	var actorHostName string
 	var urlError error
@@ -207,6 +242,16 @@ func (ib *inbox) HandleFollow(
 	return
 }
 
+// Sends a Reject in the background, so the follow request doesn't wait for the remote server.
+func (ib *inbox) rejectFollow(actFollow *dto.ActivityIn[string], senderInfo *dto.UserInfo, followedUser string) {
+	go func() {
+		err := ib.udir.RejectFollower(actFollow.Id, actFollow.Actor, senderInfo.Inbox, followedUser)
+		if err != nil {
+			ib.logger.Errorf("Error rejecting follower: %v", err)
+		}
+	}()
+}
+
 func (ib *inbox) updateFollowerMetric() {
 	if count, err := ib.repo.GetFeedFollowerCount(); err != nil {
 		ib.logger.Errorf("Error getting feed follower count: %v", err)
@@ -0,0 +1,154 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/shared"
+	"slices"
+	"time"
+)
+
+// Returns the ID of an activity's object or target, which can be given as a URL or as an embedded object.
+func getObjectId(obj any) string {
+	if str, ok := obj.(string); ok {
+		return str
+	}
+	if objMap, ok := obj.(map[string]interface{}); ok {
+		if idStr, ok := objMap["id"].(string); ok {
+			return idStr
+		}
+	}
+	return ""
+}
+
+func (ib *inbox) HandleDelete(senderInfo *dto.UserInfo, bodyBytes []byte) (reqProblem string, err error) {
+
+	reqProblem = ""
+	err = nil
+
+	var act dto.ActivityInBase
+	if jsonErr := json.Unmarshal(bodyBytes, &act); jsonErr != nil {
+		ib.logger.Info("Invalid JSON in Delete activity body")
+		reqProblem = fmt.Sprintf("Invalid JSON: %d", jsonErr)
+		return
+	}
+
+	// We only care about actors deleting themselves; the rest is about posts we never stored
+	if getObjectId(act.Object) != act.Actor {
+		return
+	}
+	ib.logger.Infof("Handling Delete activity for actor %s", act.Actor)
+
+	// Most servers send actor deletes everywhere; only look further if this is one of our followers
+	var isFollower bool
+	if isFollower, err = ib.repo.DoesFollowerExist(act.Actor); err != nil || !isFollower {
+		return
+	}
+
+	// Unsigned delete: only believe it if the actor's server confirms the actor is gone
+	if senderInfo == nil {
+		_, retrieveErr := ib.userRetriever.Retrieve(act.Actor)
+		if retrieveErr == nil {
+			reqProblem = fmt.Sprintf("Unverified Delete for actor that still exists: %s", act.Actor)
+			return
+		}
+		if !errors.Is(retrieveErr, ErrUserGone) {
+			reqProblem = fmt.Sprintf("Cannot verify that deleted actor is gone: %s: %v", act.Actor, retrieveErr)
+			return
+		}
+	}
+
+	var alreadyHandled bool
+	if alreadyHandled, err = ib.repo.MarkActivityHandled(act.Id, time.Now()); err != nil {
+		return
+	}
+	if alreadyHandled {
+		ib.logger.Infof("Activity has already been handled: %s", act.Id)
+		return
+	}
+
+	var removed int
+	if removed, err = ib.repo.RemoveFollowerEverywhere(act.Actor); err != nil {
+		ib.logger.Errorf("Error removing deleted follower '%s': %v", act.Actor, err)
+		return
+	}
+	ib.logger.Infof("Removed deleted actor %s from %d followed accounts", act.Actor, removed)
+	ib.updateFollowerMetric()
+
+	return
+}
+
+func (ib *inbox) HandleMove(senderInfo *dto.UserInfo, bodyBytes []byte) (reqProblem string, err error) {
+
+	reqProblem = ""
+	err = nil
+
+	var act dto.ActivityInBase
+	if jsonErr := json.Unmarshal(bodyBytes, &act); jsonErr != nil {
+		ib.logger.Info("Invalid JSON in Move activity body")
+		reqProblem = fmt.Sprintf("Invalid JSON: %d", jsonErr)
+		return
+	}
+	ib.logger.Infof("Handling Move activity for actor %s", act.Actor)
+
+	// Only the actor can move itself
+	if getObjectId(act.Object) != act.Actor {
+		reqProblem = fmt.Sprintf("Move object is not the actor: %s", getObjectId(act.Object))
+		return
+	}
+	target := getObjectId(act.Target)
+	if target == "" {
+		reqProblem = "Move activity has no target"
+		return
+	}
+
+	var isFollower bool
+	if isFollower, err = ib.repo.DoesFollowerExist(act.Actor); err != nil || !isFollower {
+		return
+	}
+
+	// The new account must confirm that it is also the old one
+	var targetInfo *dto.UserInfo
+	var retrieveErr error
+	if targetInfo, retrieveErr = ib.userRetriever.Retrieve(target); retrieveErr != nil {
+		reqProblem = fmt.Sprintf("Failed to retrieve Move target %s: %v", target, retrieveErr)
+		return
+	}
+	if targetInfo.Id != target || !slices.Contains(targetInfo.GetAlsoKnownAs(), act.Actor) {
+		reqProblem = fmt.Sprintf("Move target %s does not list %s in alsoKnownAs", target, act.Actor)
+		return
+	}
+
+	var targetHostName string
+	var urlError error
+	if targetHostName, urlError = shared.GetHostName(target); urlError != nil {
+		reqProblem = urlError.Error()
+		return
+	}
+
+	var moved int
+	moved, err = ib.repo.MoveFollower(act.Actor, &dal.FollowerInfo{
+		UserUrl:     target,
+		Handle:      targetInfo.PreferredUserName,
+		Host:        targetHostName,
+		UserInbox:   targetInfo.Inbox,
+		SharedInbox: targetInfo.Endpoints.SharedInbox,
+	})
+	if err != nil {
+		ib.logger.Errorf("Error moving follower '%s' to '%s': %v", act.Actor, target, err)
+		return
+	}
+	ib.logger.Infof("Moved %d follows from %s to %s", moved, act.Actor, target)
+	ib.updateFollowerMetric()
+
+	// Only once the move is done: if anything above failed, the sender may try again.
+	// Repeated deliveries find the old actor no longer following, so they change nothing.
+	if _, err = ib.repo.MarkActivityHandled(act.Id, time.Now()); err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,402 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_media_cache.go -package mocks rss_parrot/logic IMediaCache
+
+// Images we show for parrots are downloaded into cfg.MediaDir, scaled to standard sizes, and served by us,
+// so that followers' instances don't fetch them from publishers' sites. Each account has its own folder,
+// and files are named after a hash of the original URL, a hash of the stored image, and the kind of image.
+// A file name always means the same image, so a publisher changing the image behind a URL gives us a new file.
+// Thumbnails are part of toots: they are kept as long as a stored toot shows them, and never replaced.
+// Without a media folder configured, everything links to the original images.
+
+const (
+	mediaGcIntervalMin   = 60
+	maxMediaSourceBytes  = 8 * 1024 * 1024
+	maxMediaSourcePixels = 40 * 1000 * 1000
+	mediaJpegQuality     = 85
+	newThumbnailGraceMin = 60
+)
+
+type MediaKind int
+
+const (
+	MediaAvatar MediaKind = iota
+	MediaHeader
+	MediaThumbnail
+)
+
+func (mk MediaKind) String() string {
+	switch mk {
+	case MediaAvatar:
+		return "avatar"
+	case MediaHeader:
+		return "header"
+	default:
+		return "thumb"
+	}
+}
+
+// Avatars and headers are cropped to their aspect ratio; thumbnails only need to fit
+var mediaSizes = map[MediaKind]struct {
+	w, h int
+	crop bool
+}{
+	MediaAvatar:    {400, 400, true},
+	MediaHeader:    {1500, 500, true},
+	MediaThumbnail: {800, 800, false},
+}
+
+type IMediaCache interface {
+	// Downloads and stores the image, unless we already have it. Returns its local URL.
+	Store(user, srcUrl string, kind MediaKind) (string, error)
+	// Local URL of the image if we have it, or the original URL if we don't.
+	GetUrl(user, srcUrl string, kind MediaKind) string
+	// Removes all images of an account.
+	PurgeAccount(user string) error
+	// Removes the account's thumbnails that none of its stored toots show anymore.
+	PruneThumbnails(user string) error
+}
+
+type mediaCache struct {
+	cfg       *shared.Config
+	logger    shared.ILogger
+	userAgent shared.IUserAgent
+	repo      dal.IRepo
+	overLimit atomic.Bool // Media takes up more than cfg.MediaCacheMaxMB: no new thumbnails
+}
+
+func NewMediaCache(
+	cfg *shared.Config,
+	logger shared.ILogger,
+	userAgent shared.IUserAgent,
+	repo dal.IRepo,
+) IMediaCache {
+	mc := mediaCache{
+		cfg:       cfg,
+		logger:    logger,
+		userAgent: userAgent,
+		repo:      repo,
+	}
+	if cfg.MediaDir != "" {
+		go mc.gcLoop()
+	}
+	return &mc
+}
+
+func isSafeMediaUser(user string) bool {
+	return user != "" && !strings.ContainsAny(user, `/\`) && !strings.Contains(user, "..")
+}
+
+func getMediaUrlHash(srcUrl string) string {
+	digest := sha256.Sum256([]byte(srcUrl))
+	return hex.EncodeToString(digest[:16])
+}
+
+func getMediaFileName(srcUrl string, data []byte, kind MediaKind, ext string) string {
+	digest := sha256.Sum256(data)
+	return getMediaUrlHash(srcUrl) + "-" + hex.EncodeToString(digest[:8]) + "-" + kind.String() + ext
+}
+
+// File name of the stored image, with its extension, or empty string if we don't have it
+func (mc *mediaCache) findFile(user, srcUrl string, kind MediaKind) string {
+	pattern := getMediaUrlHash(srcUrl) + "-*-" + kind.String() + ".*"
+	matches, err := filepath.Glob(filepath.Join(mc.cfg.MediaDir, user, pattern))
+	if err != nil || len(matches) == 0 {
+		return ""
+	}
+	return filepath.Base(matches[0])
+}
+
+func (mc *mediaCache) GetUrl(user, srcUrl string, kind MediaKind) string {
+	if mc.cfg.MediaDir == "" || srcUrl == "" || !isSafeMediaUser(user) {
+		return srcUrl
+	}
+	fileName := mc.findFile(user, srcUrl, kind)
+	if fileName == "" {
+		return srcUrl
+	}
+	idb := shared.IdBuilder{mc.cfg.Host}
+	return idb.MediaUrl(user, fileName)
+}
+
+func (mc *mediaCache) Store(user, srcUrl string, kind MediaKind) (string, error) {
+
+	if mc.cfg.MediaDir == "" {
+		return srcUrl, nil
+	}
+	if !isSafeMediaUser(user) {
+		return "", fmt.Errorf("invalid user for media: '%s'", user)
+	}
+	idb := shared.IdBuilder{mc.cfg.Host}
+	// Avatars and headers are downloaded again each time, as the image behind the URL may have changed
+	if kind == MediaThumbnail {
+		if fileName := mc.findFile(user, srcUrl, kind); fileName != "" {
+			return idb.MediaUrl(user, fileName), nil
+		}
+		if mc.overLimit.Load() {
+			return srcUrl, nil
+		}
+	}
+
+	data, mediaType, err := mc.download(srcUrl)
+	if err != nil {
+		return "", err
+	}
+	data, ext, err := scaleImage(data, mediaType, kind)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(mc.cfg.MediaDir, user)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	fileName := getMediaFileName(srcUrl, data, kind, ext)
+	if _, err = os.Stat(filepath.Join(dir, fileName)); err == nil {
+		// Same image as the one we have
+		return idb.MediaUrl(user, fileName), nil
+	}
+	if err = writeMediaFile(dir, fileName, data); err != nil {
+		return "", err
+	}
+	// Only one avatar and one header per account: the new one replaces any old one
+	if kind != MediaThumbnail {
+		mc.removeKind(dir, kind, fileName)
+	}
+	mc.logger.Debugf("Stored %s for %s: %s", kind, user, srcUrl)
+	return idb.MediaUrl(user, fileName), nil
+}
+
+// Writes to a temp file first, so we never serve half-written images
+func writeMediaFile(dir, fileName string, data []byte) error {
+	tmpFile, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filepath.Join(dir, fileName))
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+// Removes the files of this kind, except the one we keep
+func (mc *mediaCache) removeKind(dir string, kind MediaKind, keepFileName string) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*-"+kind.String()+".*"))
+	for _, match := range matches {
+		if filepath.Base(match) == keepFileName {
+			continue
+		}
+		if err := os.Remove(match); err != nil {
+			mc.logger.Warnf("Failed to remove old media file %s: %v", match, err)
+		}
+	}
+}
+
+func (mc *mediaCache) download(srcUrl string) ([]byte, string, error) {
+
+	req, err := http.NewRequest("GET", srcUrl, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	mc.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = feedOrSiteTimeoutSec * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("request for %s failed with status %d", srcUrl, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !acceptedImageTypes[mediaType] {
+		return nil, "", fmt.Errorf("unsupported content type: '%s'", mediaType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaSourceBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxMediaSourceBytes {
+		return nil, "", fmt.Errorf("image larger than %d bytes: %s", maxMediaSourceBytes, srcUrl)
+	}
+	return data, mediaType, nil
+}
+
+// Crops and scales the image to the kind's standard size. Returns the encoded image and its file extension.
+// Formats we cannot decode are kept as they are, if they're small enough.
+func scaleImage(data []byte, mediaType string, kind MediaKind) ([]byte, string, error) {
+
+	// A small file may claim a huge size: check before the decoder allocates it
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if mediaType == "image/webp" && len(data) <= maxImageBytes {
+			return data, ".webp", nil
+		}
+		return nil, "", fmt.Errorf("failed to decode image: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxMediaSourcePixels {
+		return nil, "", fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	size := mediaSizes[kind]
+	area := src.Bounds()
+	if size.crop {
+		area = cropToAspect(src, size.w, size.h)
+	}
+	w, h := fitSize(area.Dx(), area.Dy(), size.w, size.h)
+	scaled := resizeArea(src, area, w, h)
+
+	var buf bytes.Buffer
+	// PNGs and GIFs may be transparent; photos are much smaller as JPEG
+	if mediaType == "image/png" || mediaType == "image/gif" {
+		err = png.Encode(&buf, scaled)
+		return buf.Bytes(), ".png", err
+	}
+	err = jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: mediaJpegQuality})
+	return buf.Bytes(), ".jpg", err
+}
+
+func (mc *mediaCache) PurgeAccount(user string) error {
+	if mc.cfg.MediaDir == "" || !isSafeMediaUser(user) {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(mc.cfg.MediaDir, user))
+}
+
+func (mc *mediaCache) PruneThumbnails(user string) error {
+
+	if mc.cfg.MediaDir == "" || !isSafeMediaUser(user) {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(mc.cfg.MediaDir, user, "*-"+MediaThumbnail.String()+".*"))
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+	urls, err := mc.repo.GetTootAttachmentUrls(user)
+	if err != nil {
+		return err
+	}
+	idb := shared.IdBuilder{mc.cfg.Host}
+	urlPrefix := idb.MediaUrl(user, "")
+	inUse := make(map[string]bool)
+	for _, u := range urls {
+		if strings.HasPrefix(u, urlPrefix) {
+			inUse[strings.TrimPrefix(u, urlPrefix)] = true
+		}
+	}
+	// New thumbnails may be for toots we're just about to save
+	graceStart := time.Now().Add(-newThumbnailGraceMin * time.Minute)
+	removed := 0
+	for _, match := range matches {
+		if inUse[filepath.Base(match)] {
+			continue
+		}
+		if fi, err := os.Stat(match); err != nil || fi.ModTime().After(graceStart) {
+			continue
+		}
+		if err = os.Remove(match); err != nil {
+			mc.logger.Warnf("Failed to remove media file %s: %v", match, err)
+			continue
+		}
+		removed++
+	}
+	if removed != 0 {
+		mc.logger.Infof("Removed %d thumbnails no toot shows anymore: %s", removed, user)
+	}
+	return nil
+}
+
+func (mc *mediaCache) gcLoop() {
+	for {
+		mc.collectGarbage()
+		time.Sleep(mediaGcIntervalMin * time.Minute)
+	}
+}
+
+// Removes the images of accounts that no longer exist, and thumbnails that no toot shows anymore.
+// Images that are in use are never removed: if what's left takes up more than the limit,
+// we stop storing new thumbnails until it doesn't.
+func (mc *mediaCache) collectGarbage() {
+
+	entries, err := os.ReadDir(mc.cfg.MediaDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			mc.logger.Errorf("Failed to list media folder: %v", err)
+		}
+		return
+	}
+
+	var totalSize int64
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		user := entry.Name()
+		exists, err := mc.repo.DoesAccountExist(user)
+		if err != nil {
+			mc.logger.Errorf("Failed to check account for media GC: %s: %v", user, err)
+			continue
+		}
+		if !exists {
+			mc.logger.Infof("Removing media of deleted account: %s", user)
+			if err = mc.PurgeAccount(user); err != nil {
+				mc.logger.Errorf("Failed to remove media of %s: %v", user, err)
+			}
+			continue
+		}
+		if err = mc.PruneThumbnails(user); err != nil {
+			mc.logger.Errorf("Failed to remove unused thumbnails of %s: %v", user, err)
+		}
+		userFiles, _ := os.ReadDir(filepath.Join(mc.cfg.MediaDir, user))
+		for _, userFile := range userFiles {
+			if fi, err := userFile.Info(); err == nil && !fi.IsDir() {
+				totalSize += fi.Size()
+			}
+		}
+	}
+
+	maxSize := int64(mc.cfg.MediaCacheMaxMB) * 1024 * 1024
+	overLimit := maxSize > 0 && totalSize > maxSize
+	if overLimit != mc.overLimit.Load() {
+		if overLimit {
+			mc.logger.Warnf("Media takes up %d MB, over the %d MB limit: not storing new thumbnails",
+				totalSize/1024/1024, mc.cfg.MediaCacheMaxMB)
+		} else {
+			mc.logger.Infof("Media is within the %d MB limit again: storing new thumbnails", mc.cfg.MediaCacheMaxMB)
+		}
+	}
+	mc.overLimit.Store(overLimit)
+}
@@ -0,0 +1,61 @@
+package logic
+
+import (
+	"image"
+	"image/color"
+)
+
+// Cuts the largest centered area with the given aspect ratio out of the image
+func cropToAspect(src image.Image, aspectW, aspectH int) image.Rectangle {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w*aspectH > h*aspectW {
+		newW := h * aspectW / aspectH
+		x0 := b.Min.X + (w-newW)/2
+		return image.Rect(x0, b.Min.Y, x0+newW, b.Max.Y)
+	}
+	newH := w * aspectH / aspectW
+	y0 := b.Min.Y + (h-newH)/2
+	return image.Rect(b.Min.X, y0, b.Max.X, y0+newH)
+}
+
+// Size that fits within maxW x maxH, keeping the aspect ratio. Never scales up.
+func fitSize(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
+
+// Scales the area of the image down to w x h by averaging the source pixels that fall into each target pixel
+func resizeArea(src image.Image, area image.Rectangle, w, h int) *image.RGBA {
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	srcW, srcH := area.Dx(), area.Dy()
+	for y := 0; y < h; y++ {
+		sy0 := area.Min.Y + y*srcH/h
+		sy1 := max(sy0+1, area.Min.Y+(y+1)*srcH/h)
+		for x := 0; x < w; x++ {
+			sx0 := area.Min.X + x*srcW/w
+			sx1 := max(sx0+1, area.Min.X+(x+1)*srcW/w)
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -6,6 +6,7 @@ import (
 	"rss_parrot/dto"
 	"rss_parrot/shared"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -13,7 +14,11 @@ import (
 
 type IMessenger interface {
 	SendMessageAsync(byUser string, toInbox, msg string, mentions []*MsgMention, to, cc []string, inReplyTo string)
-	EnqueueBroadcast(user string, statusId string, tootedAt time.Time, msg string) error
+	EnqueueBroadcast(user string, statusId string, tootedAt time.Time, msg string,
+		attachments []*dal.TootAttachment, hashtags []string) error
+	EnqueueUpdate(user string, statusId string, tootedAt, updatedAt time.Time, msg string,
+		attachments []*dal.TootAttachment, hashtags []string) error
+	EnqueueDelete(user string, statusId string) error
 }
 
 type MsgMention struct {
@@ -21,8 +26,10 @@ type MsgMention struct {
 	UserUrl string
 }
 
-const maxParallelSends = 5
+const defaultDeliveryWorkers = 5
+const defaultDeliveriesPerHost = 2
 const tootLoopIdleWakeSec = 5
+const syncFollowersKeptSec = 60
 
 type messenger struct {
 	cfg             *shared.Config
@@ -34,7 +41,17 @@ type messenger struct {
 	idb             shared.IdBuilder
 	reStatusId      *regexp.Regexp
 	newTootsInQueue chan struct{}
-	tqProgress      map[int]interface{}
+	maxSends        int            // Global cap on deliveries in progress
+	maxSendsPerHost int            // Cap on deliveries in progress to the same host
+	tqProgress      map[int]string // Queue items being delivered, with their destination host
+	hostSends       map[string]int // Number of deliveries in progress by host
+	muSyncFollowers sync.Mutex
+	syncFollowers   map[string]cachedFollowers // Followers by user for the Collection-Synchronization header
+}
+
+type cachedFollowers struct {
+	followers []*dal.FollowerInfo
+	loadedAt  time.Time
 }
 
 func NewMessenger(
@@ -56,10 +73,21 @@ func NewMessenger(
 		idb:      shared.IdBuilder{cfg.Host},
 	}
 
+	m.maxSends = defaultDeliveryWorkers
+	if cfg.DeliveryWorkers > 0 {
+		m.maxSends = cfg.DeliveryWorkers
+	}
+	m.maxSendsPerHost = defaultDeliveriesPerHost
+	if cfg.DeliveriesPerHost > 0 {
+		m.maxSendsPerHost = cfg.DeliveriesPerHost
+	}
+
 	m.reStatusId = regexp.MustCompile("^https://[^/]+/u/[^/]+/status/([0-9]+)$")
 
 	m.newTootsInQueue = make(chan struct{})
-	m.tqProgress = make(map[int]interface{})
+	m.tqProgress = make(map[int]string)
+	m.hostSends = make(map[string]int)
+	m.syncFollowers = make(map[string]cachedFollowers)
 	go m.tootQueueLoop()
 
 	return &m
@@ -84,18 +112,57 @@ func (m *messenger) sendMessage(byUser string, toInbox, msg string,
 		ptags = &tags
 	}
 	id := m.repo.GetNextId()
-	err := m.sendToInbox(byUser, id, to, cc, toInbox, &inReplyTo, published, msg, ptags)
+	err := m.sendToInbox(byUser, id, "Create", to, cc, toInbox, &inReplyTo, published, nil, msg, ptags, nil, nil)
 	if err != nil {
 		m.logger.Errorf("Failed to send message to inbox %s", toInbox)
 	}
 }
 
-func (m *messenger) EnqueueBroadcast(user string, statusId string, tootedAt time.Time, msg string) error {
+func (m *messenger) EnqueueBroadcast(user string, statusId string, tootedAt time.Time, msg string,
+	attachments []*dal.TootAttachment, hashtags []string) error {
+	return m.enqueueForFollowers(&dal.TootQueueItem{
+		SendingUser:  user,
+		TootedAt:     tootedAt,
+		StatusId:     statusId,
+		Content:      msg,
+		ActivityType: "Create",
+		Attachments:  attachments,
+		Hashtags:     hashtags,
+	})
+}
 
-	followers, err := m.repo.GetFollowersByUser(user, true)
+func (m *messenger) EnqueueUpdate(user string, statusId string, tootedAt, updatedAt time.Time, msg string,
+	attachments []*dal.TootAttachment, hashtags []string) error {
+	return m.enqueueForFollowers(&dal.TootQueueItem{
+		SendingUser:  user,
+		TootedAt:     tootedAt,
+		StatusId:     statusId,
+		Content:      msg,
+		ActivityType: "Update",
+		UpdatedAt:    updatedAt,
+		Attachments:  attachments,
+		Hashtags:     hashtags,
+	})
+}
+
+func (m *messenger) EnqueueDelete(user string, statusId string) error {
+	return m.enqueueForFollowers(&dal.TootQueueItem{
+		SendingUser:  user,
+		TootedAt:     time.Now(),
+		StatusId:     statusId,
+		ActivityType: "Delete",
+	})
+}
+
+// Queues a copy of tqi for every distinct inbox of the sending user's followers
+func (m *messenger) enqueueForFollowers(tqi *dal.TootQueueItem) error {
+
+	followers, err := m.repo.GetFollowersByUser(tqi.SendingUser, true)
 	if err != nil {
 		return err
 	}
+	// Deliveries of this broadcast will need the same list for their Collection-Synchronization header
+	m.keepSyncFollowers(tqi.SendingUser, followers)
 
 	// Collect distinct shared inboxes
 	inboxes := make(map[string]struct{})
@@ -115,14 +182,10 @@ func (m *messenger) EnqueueBroadcast(user string, statusId string, tootedAt time
 
 	// Create a queue item for each inbox
 	for inboxUrl := range inboxes {
-		err = m.repo.AddTootQueueItem(&dal.TootQueueItem{
-			SendingUser: user,
-			ToInbox:     inboxUrl,
-			TootedAt:    tootedAt,
-			StatusId:    statusId,
-			Content:     msg,
-		})
-		if err != nil {
+		item := *tqi
+		item.ToInbox = inboxUrl
+		item.ToHost = getUrlHost(inboxUrl)
+		if err = m.repo.AddTootQueueItem(&item); err != nil {
 			return err
 		}
 	}
@@ -136,36 +199,54 @@ func (m *messenger) EnqueueBroadcast(user string, statusId string, tootedAt time
 
 func (m *messenger) tootQueueLoop() {
 
-	tootSent := make(chan int)
+	tootSent := make(chan deliveryResult)
 
+	// A slow or busy host doesn't hold up the rest: we take turns between hosts, and never have more than
+	// maxSendsPerHost deliveries going to the same host.
 	sendToots := func() {
-		if len(m.tqProgress) >= maxParallelSends {
+		if len(m.tqProgress) >= m.maxSends {
 			return
 		}
-		maxId := -1
-		for id := range m.tqProgress {
-			maxId = max(maxId, id)
-		}
-		var err error
-		var items []*dal.TootQueueItem
-		var qlen int
-		items, qlen, err = m.repo.GetTootQueueItems(maxId, maxParallelSends-len(m.tqProgress))
+		// Items in progress are still in the queue and come back first for their host, so we ask for those too
+		items, depths, err := m.repo.GetTootQueueItems(m.maxSendsPerHost, m.maxSends+len(m.tqProgress), time.Now().UTC())
 		if err != nil {
 			m.logger.Errorf("Failed to get toot queue items: %v", err)
 			return
 		}
+		qlen := 0
+		for _, depth := range depths {
+			qlen += depth
+		}
 		m.metrics.TootQueueLength(qlen)
+		m.metrics.TootQueueHostDepths(depths)
 		for _, item := range items {
-			m.tqProgress[item.Id] = struct{}{}
+			if len(m.tqProgress) >= m.maxSends {
+				break
+			}
+			if _, inProgress := m.tqProgress[item.Id]; inProgress {
+				continue
+			}
+			if m.hostSends[item.ToHost] >= m.maxSendsPerHost {
+				continue
+			}
+			m.tqProgress[item.Id] = item.ToHost
+			m.hostSends[item.ToHost]++
 			go m.sendQueuedToot(item, tootSent)
 		}
 	}
 
-	removeSentToot := func(id int) {
-		if err := m.repo.DeleteTootQueueItem(id); err != nil {
-			m.logger.Errorf("Failed to remove sent toot from queue: %d: %v", id, err)
+	removeSentToot := func(res deliveryResult) {
+		// Items to be retried were already rescheduled, and stay in the queue
+		if !res.retry {
+			if err := m.repo.DeleteTootQueueItem(res.id); err != nil {
+				m.logger.Errorf("Failed to remove sent toot from queue: %d: %v", res.id, err)
+			}
+		}
+		host := m.tqProgress[res.id]
+		delete(m.tqProgress, res.id)
+		if m.hostSends[host]--; m.hostSends[host] <= 0 {
+			delete(m.hostSends, host)
 		}
-		delete(m.tqProgress, id)
 	}
 
 	for {
@@ -176,9 +257,9 @@ func (m *messenger) tootQueueLoop() {
 		case <-time.After(tootLoopIdleWakeSec * time.Second):
 			m.logger.Debug("Toot queue idle loop")
 			sendToots()
-		case id := <-tootSent:
-			m.logger.Debugf("Toot sent: %d", id)
-			removeSentToot(id)
+		case res := <-tootSent:
+			m.logger.Debugf("Toot sent: %d", res.id)
+			removeSentToot(res)
 			sendToots()
 		}
 	}
@@ -198,37 +279,115 @@ func (m *messenger) getIdVal(statusIdUrl string) uint64 {
 	return uint64(idVal)
 }
 
-func (m *messenger) sendQueuedToot(item *dal.TootQueueItem, tootSent chan int) {
+func (m *messenger) sendQueuedToot(item *dal.TootQueueItem, tootSent chan deliveryResult) {
 
 	var err error
 	idb := shared.IdBuilder{m.cfg.Host}
 	to := []string{shared.ActivityPublic}
 	userFollowers := idb.UserFollowers(item.SendingUser)
 
+	// Don't waste time on inboxes that have been dead for a while
+	if !m.isInboxAvailable(item.ToInbox) {
+		m.metrics.DeliveryOutcome("skipped")
+		tootSent <- deliveryResult{id: item.Id}
+		return
+	}
+
 	// This should never fail, but if it does, we just make up a new ID
 	idVal := m.getIdVal(item.StatusId)
 
-	err = m.sendToInbox(
-		item.SendingUser,
-		idVal,
-		to,
-		[]string{userFollowers},
-		item.ToInbox,
-		nil,
-		item.TootedAt.UTC().Format(time.RFC3339),
-		item.Content,
-		nil)
+	actType := item.ActivityType
+	if actType == "" {
+		actType = "Create"
+	}
+	var updated *string
+	if actType == "Update" {
+		updatedStr := item.UpdatedAt.UTC().Format(time.RFC3339)
+		updated = &updatedStr
+	}
+
+	// Let the receiving server check if its view of our followers is in sync
+	var headers map[string]string
+	if syncVal, syncErr := m.getCollectionSyncHeader(item.SendingUser, item.ToInbox); syncErr != nil {
+		m.logger.Warnf("Failed to get followers for collection synchronization: %v", syncErr)
+	} else {
+		headers = map[string]string{collectionSyncHeader: syncVal}
+	}
+
+	if actType == "Delete" {
+		err = m.sendDelete(item.SendingUser, idVal, to, []string{userFollowers}, item.ToInbox, headers)
+	} else {
+		err = m.sendToInbox(
+			item.SendingUser,
+			idVal,
+			actType,
+			to,
+			[]string{userFollowers},
+			item.ToInbox,
+			nil,
+			item.TootedAt.UTC().Format(time.RFC3339),
+			updated,
+			item.Content,
+			getNoteHashtags(&m.idb, item.SendingUser, item.Hashtags),
+			getNoteAttachments(item.Attachments),
+			headers)
+	}
+
+	retry := m.handleDeliveryResult(item, err)
+	if err == nil {
+		m.metrics.FeedTootSent()
+	}
+
+	tootSent <- deliveryResult{id: item.Id, retry: retry}
+}
+
+func (m *messenger) getCollectionSyncHeader(user, toInbox string) (string, error) {
+	followers, err := m.getSyncFollowers(user)
 	if err != nil {
-		m.logger.Errorf("Failed to send queued toot: %v", err)
+		return "", err
 	}
+	followersUrl := m.idb.UserFollowers(user)
+	syncUrl := m.idb.UserFollowersSync(user)
+	return getCollectionSyncValue(followersUrl, syncUrl, followers, getUrlHost(toInbox)), nil
+}
+
+// Returns the user's followers, loading them only if we haven't in the last minute.
+// A broadcast is delivered to many inboxes within a short time, and each delivery needs the same list.
+func (m *messenger) getSyncFollowers(user string) ([]*dal.FollowerInfo, error) {
 
-	m.metrics.FeedTootSent()
+	m.muSyncFollowers.Lock()
+	cached, found := m.syncFollowers[user]
+	m.muSyncFollowers.Unlock()
+	if found && time.Since(cached.loadedAt) < syncFollowersKeptSec*time.Second {
+		return cached.followers, nil
+	}
 
-	tootSent <- item.Id
+	followers, err := m.repo.GetFollowersByUser(user, true)
+	if err != nil {
+		return nil, err
+	}
+	m.keepSyncFollowers(user, followers)
+	return followers, nil
 }
 
-func (m *messenger) sendToInbox(byUser string, idVal uint64, to, cc []string, toInbox string,
-	inReplyTo *string, published, message string, tag *[]dto.Tag) error {
+func (m *messenger) keepSyncFollowers(user string, followers []*dal.FollowerInfo) {
+
+	m.muSyncFollowers.Lock()
+	defer m.muSyncFollowers.Unlock()
+
+	now := time.Now()
+	// Drop lists that have gone stale, so users who stopped posting don't stay in memory
+	for key, cached := range m.syncFollowers {
+		if now.Sub(cached.loadedAt) >= syncFollowersKeptSec*time.Second {
+			delete(m.syncFollowers, key)
+		}
+	}
+	m.syncFollowers[user] = cachedFollowers{followers, now}
+}
+
+func (m *messenger) sendToInbox(byUser string, idVal uint64, actType string, to, cc []string, toInbox string,
+	inReplyTo *string, published string, updated *string, message string, tag *[]dto.Tag,
+	attachments []dto.Document, headers map[string]string) error {
 
 	m.logger.Infof("Sending to inbox: %s", toInbox)
 
@@ -241,6 +400,7 @@ func (m *messenger) sendToInbox(byUser string, idVal uint64, to, cc []string, to
 		Id:           m.idb.UserStatus(byUser, idVal),
 		Type:         "Note",
 		Published:    published,
+		Updated:      updated,
 		Summary:      nil,
 		AttributedTo: m.idb.UserUrl(byUser),
 		InReplyTo:    inReplyTo,
@@ -248,18 +408,48 @@ func (m *messenger) sendToInbox(byUser string, idVal uint64, to, cc []string, to
 		To:           to,
 		Cc:           cc,
 		Tag:          tag,
+		Attachment:   attachments,
+	}
+	// Every update is a separate activity about the same note
+	actId := m.idb.UserStatusActivity(byUser, idVal)
+	if updated != nil {
+		actId += "#updates/" + *updated
 	}
 	act := &dto.ActivityOut{
 		Context: "https://www.w3.org/ns/activitystreams",
-		Id:      m.idb.UserStatusActivity(byUser, idVal),
-		Type:    "Create",
+		Id:      actId,
+		Type:    actType,
 		Actor:   m.idb.UserUrl(byUser),
 		To:      &to,
 		Cc:      &cc,
 		Object:  note,
 	}
 
-	m.sender.Send(privKey, byUser, toInbox, act)
+	return m.sender.SendWithHeaders(privKey, byUser, toInbox, act, headers)
+}
 
-	return nil
+func (m *messenger) sendDelete(byUser string, idVal uint64, to, cc []string, toInbox string,
+	headers map[string]string) error {
+
+	m.logger.Infof("Sending delete to inbox: %s", toInbox)
+
+	privKey, err := m.keyStore.GetPrivKey(byUser)
+	if err != nil {
+		return err
+	}
+
+	act := &dto.ActivityOut{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Id:      m.idb.UserStatusActivity(byUser, idVal) + "#delete",
+		Type:    "Delete",
+		Actor:   m.idb.UserUrl(byUser),
+		To:      &to,
+		Cc:      &cc,
+		Object: &dto.Tombstone{
+			Id:   m.idb.UserStatus(byUser, idVal),
+			Type: "Tombstone",
+		},
+	}
+
+	return m.sender.SendWithHeaders(privKey, byUser, toInbox, act, headers)
 }
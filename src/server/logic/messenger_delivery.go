@@ -0,0 +1,131 @@
+package logic
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"time"
+)
+
+const (
+	maxDeliveryAttempts    = 8       // Give up on a queued delivery after this many failures
+	deliveryRetryBaseMin   = 5       // Wait before first retry; doubles with every failed attempt
+	deliveryRetryMaxMin    = 12 * 60 // Longest wait between retries
+	suspendedInboxProbeHrs = 24      // Suspended inboxes still get one delivery attempt this often
+	maxDeliveryErrorLen    = 512     // Delivery errors are truncated to this length in inbox health
+)
+
+// Tells the toot queue loop that a queued item has been dealt with.
+type deliveryResult struct {
+	id    int
+	retry bool // Item was rescheduled and stays in the queue
+}
+
+// Classifies a delivery error. Remote errors count against the inbox's health; local ones, such as
+// a missing key, don't. Transient errors are worth retrying: server errors, rate limits and network failures.
+func classifyDeliveryError(err error) (remote, transient bool) {
+	var statusErr *DeliveryStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		transient = code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+		return true, transient
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, true
+	}
+	return false, false
+}
+
+// Returns when a delivery that has failed attempts times should be tried again.
+func getDeliveryRetryTime(attempts int, now time.Time) time.Time {
+	waitMin := deliveryRetryBaseMin
+	for i := 1; i < attempts && waitMin < deliveryRetryMaxMin; i++ {
+		waitMin *= 2
+	}
+	waitMin = min(waitMin, deliveryRetryMaxMin)
+	return now.Add(time.Duration(waitMin) * time.Minute)
+}
+
+// False if the inbox is suspended, unless it's time to probe whether it has come back.
+func (m *messenger) isInboxAvailable(inbox string) bool {
+	health, err := m.repo.GetInboxHealth(inbox)
+	if err != nil {
+		m.logger.Errorf("Failed to get health of inbox %s: %v", inbox, err)
+		return true
+	}
+	if health == nil || !health.Suspended {
+		return true
+	}
+	return time.Since(health.LastFailure) >= suspendedInboxProbeHrs*time.Hour
+}
+
+// Records the outcome of a delivery in the inbox's health, and suspends the inbox if it has been failing for too long.
+// Returns true if the item was rescheduled for another attempt.
+func (m *messenger) handleDeliveryResult(item *dal.TootQueueItem, err error) (retry bool) {
+
+	now := time.Now().UTC()
+
+	if err == nil {
+		recovered, healthErr := m.repo.RecordInboxSuccess(item.ToInbox, now)
+		if healthErr != nil {
+			m.logger.Errorf("Failed to record delivery success to inbox %s: %v", item.ToInbox, healthErr)
+		}
+		if recovered {
+			m.logger.Infof("Inbox has recovered: %s", item.ToInbox)
+			m.metrics.DeliveryOutcome("recovered")
+			m.updateSuspendedInboxMetric()
+		}
+		m.metrics.DeliveryOutcome("delivered")
+		return false
+	}
+
+	m.logger.Errorf("Failed to send queued toot to %s: %v", item.ToInbox, err)
+	remote, transient := classifyDeliveryError(err)
+	if !remote {
+		m.metrics.DeliveryOutcome("failed")
+		return false
+	}
+
+	errMsg := shared.TruncateWithEllipsis(err.Error(), maxDeliveryErrorLen)
+	health, healthErr := m.repo.RecordInboxFailure(item.ToInbox, now, errMsg)
+	if healthErr != nil {
+		m.logger.Errorf("Failed to record delivery failure to inbox %s: %v", item.ToInbox, healthErr)
+	} else if health.Suspended {
+		// Failed probe of an inbox that's already suspended
+		m.metrics.DeliveryOutcome("failed")
+		return false
+	} else if m.cfg.InboxSuspendDays > 0 && now.Sub(health.FailingSince) >= time.Duration(m.cfg.InboxSuspendDays)*24*time.Hour {
+		m.logger.Warnf("Suspending deliveries to inbox failing since %s: %s",
+			health.FailingSince.Format(time.RFC3339), item.ToInbox)
+		if suspendErr := m.repo.SuspendInbox(item.ToInbox); suspendErr != nil {
+			m.logger.Errorf("Failed to suspend inbox %s: %v", item.ToInbox, suspendErr)
+		}
+		m.metrics.DeliveryOutcome("suspended")
+		m.updateSuspendedInboxMetric()
+		return false
+	}
+
+	attempts := item.Attempts + 1
+	if !transient || attempts >= maxDeliveryAttempts {
+		m.metrics.DeliveryOutcome("failed")
+		return false
+	}
+	if retryErr := m.repo.RetryTootQueueItem(item.Id, attempts, getDeliveryRetryTime(attempts, now)); retryErr != nil {
+		m.logger.Errorf("Failed to reschedule queued toot %d: %v", item.Id, retryErr)
+		m.metrics.DeliveryOutcome("failed")
+		return false
+	}
+	m.metrics.DeliveryOutcome("retried")
+	return true
+}
+
+func (m *messenger) updateSuspendedInboxMetric() {
+	if count, err := m.repo.GetSuspendedInboxCount(); err != nil {
+		m.logger.Errorf("Error getting suspended inbox count: %v", err)
+	} else {
+		m.metrics.SuspendedInboxCount(count)
+	}
+}
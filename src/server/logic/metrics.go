@@ -14,13 +14,22 @@ type IMetrics interface {
 	StartApubRequestOut(label string) IRequestObserver
 	FeedRequested(label string)
 	FeedUpdated()
+	FeedNotModified()
+	FeedCheckProblem(label string)
+	WebSubEvent(label string)
 	NewPostSaved()
+	PostUpdated()
 	PostsDeleted(count int)
+	PostsDigested(count int)
+	PostRepublished()
 	TotalPosts(count int)
 	FeedTootSent()
+	DeliveryOutcome(label string)
+	SuspendedInboxCount(count int)
 	ServiceStarted()
 	TotalFollowers(count int)
 	TootQueueLength(length int)
+	TootQueueHostDepths(depths map[string]int)
 	CheckableFeedCount(count int)
 	DbFileSize(size int64)
 }
@@ -37,12 +46,18 @@ type metrics struct {
 	feedsRequested     *prometheus.CounterVec
 	postFlow           *prometheus.CounterVec
 	feedsUpdated       prometheus.Counter
+	feedsNotModified   prometheus.Counter
+	feedCheckProblems  *prometheus.CounterVec
+	webSubEvents       *prometheus.CounterVec
 	newPostsSaved      prometheus.Counter
 	feedTootsSent      prometheus.Counter
+	deliveryOutcomes   *prometheus.CounterVec
+	suspendedInboxes   prometheus.Gauge
 	serviceStarted     prometheus.Counter
 	totalFollowers     prometheus.Gauge
 	totalPosts         prometheus.Gauge
 	tootQueueLength    prometheus.Gauge
+	tootQueueHosts     *prometheus.GaugeVec
 	checkableFeedCount prometheus.Gauge
 	dbFileSize         prometheus.Gauge
 }
@@ -88,6 +103,24 @@ func NewMetrics(cfg *shared.Config) IMetrics {
 	})
 	_ = prometheus.Register(res.feedsUpdated)
 
+	res.feedsNotModified = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "feeds_not_modified",
+		Help: "Number of feed checks answered with 304 Not Modified",
+	})
+	_ = prometheus.Register(res.feedsNotModified)
+
+	res.feedCheckProblems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "feed_check_problems",
+		Help: "Feed checks where the feed moved, is gone, or asked us to back off",
+	}, []string{"label"})
+	_ = prometheus.Register(res.feedCheckProblems)
+
+	res.webSubEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "websub_events",
+		Help: "WebSub subscription requests, hub verifications, and content deliveries",
+	}, []string{"label"})
+	_ = prometheus.Register(res.webSubEvents)
+
 	res.newPostsSaved = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "new_posts_saved",
 		Help: "Number of new posts saved",
@@ -100,6 +133,18 @@ func NewMetrics(cfg *shared.Config) IMetrics {
 	})
 	_ = prometheus.Register(res.feedTootsSent)
 
+	res.deliveryOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "delivery_outcomes",
+		Help: "Outcomes of queued deliveries: delivered, retried, failed, skipped for suspended inboxes, etc.",
+	}, []string{"label"})
+	_ = prometheus.Register(res.deliveryOutcomes)
+
+	res.suspendedInboxes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "suspended_inbox_count",
+		Help: "Number of remote inboxes that deliveries are suspended to",
+	})
+	_ = prometheus.Register(res.suspendedInboxes)
+
 	res.serviceStarted = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "service_started",
 		Help: "Service has started up",
@@ -124,6 +169,12 @@ func NewMetrics(cfg *shared.Config) IMetrics {
 	})
 	_ = prometheus.Register(res.tootQueueLength)
 
+	res.tootQueueHosts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "toot_queue_host_depth",
+		Help: "Items in toot queue by destination host",
+	}, []string{"host"})
+	_ = prometheus.Register(res.tootQueueHosts)
+
 	res.checkableFeedCount = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "checkable_feed_count",
 		Help: "Number of feeds waiting to be checked",
@@ -171,23 +222,63 @@ func (m *metrics) TootQueueLength(length int) {
 	m.tootQueueLength.Set(float64(length))
 }
 
+// Replaces all per-host depths, so hosts whose items have all gone out disappear.
+func (m *metrics) TootQueueHostDepths(depths map[string]int) {
+	m.tootQueueHosts.Reset()
+	for host, depth := range depths {
+		m.tootQueueHosts.WithLabelValues(host).Set(float64(depth))
+	}
+}
+
 func (m *metrics) FeedUpdated() {
 	m.feedsUpdated.Add(1)
 }
 
+func (m *metrics) FeedNotModified() {
+	m.feedsNotModified.Add(1)
+}
+
+func (m *metrics) FeedCheckProblem(label string) {
+	m.feedCheckProblems.WithLabelValues(label).Add(1)
+}
+
+func (m *metrics) WebSubEvent(label string) {
+	m.webSubEvents.WithLabelValues(label).Add(1)
+}
+
 func (m *metrics) FeedTootSent() {
 	m.feedTootsSent.Add(1)
 }
 
+func (m *metrics) DeliveryOutcome(label string) {
+	m.deliveryOutcomes.WithLabelValues(label).Add(1)
+}
+
+func (m *metrics) SuspendedInboxCount(count int) {
+	m.suspendedInboxes.Set(float64(count))
+}
+
 func (m *metrics) NewPostSaved() {
 	m.newPostsSaved.Add(1)
 	m.postFlow.WithLabelValues("saved").Add(1)
 }
 
+func (m *metrics) PostUpdated() {
+	m.postFlow.WithLabelValues("updated").Add(1)
+}
+
 func (m *metrics) PostsDeleted(count int) {
 	m.postFlow.WithLabelValues("purged").Add(float64(count))
 }
 
+func (m *metrics) PostsDigested(count int) {
+	m.postFlow.WithLabelValues("digested").Add(float64(count))
+}
+
+func (m *metrics) PostRepublished() {
+	m.postFlow.WithLabelValues("republished").Add(1)
+}
+
 func (m *metrics) ServiceStarted() {
 	m.serviceStarted.Add(1)
 }
@@ -0,0 +1,373 @@
+package logic
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+//go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_publisher_claims.go -package mocks rss_parrot/logic IPublisherClaims
+
+// Site owners prove they own a parrot account's site by publishing a token in one of these ways:
+// - <meta name="fediverse:parrot-claim" content="TOKEN"> on the site's page
+// - a file at /.well-known/rss-parrot-claim.txt on the site's host, with the token on a line of its own
+// - a TXT record "rss-parrot-claim=TOKEN" on _rss-parrot.<site's host>
+
+const (
+	ClaimMetaName       = "fediverse:parrot-claim"
+	ClaimWellKnownPath  = "/.well-known/rss-parrot-claim.txt"
+	ClaimDnsPrefix      = "_rss-parrot."
+	ClaimDnsValuePrefix = "rss-parrot-claim="
+
+	claimVerifiedByMeta = "meta"
+	claimVerifiedByFile = "file"
+	claimVerifiedByDns  = "dns"
+
+	unverifiedClaimMaxAgeDays = 7
+	maxOpenClaimsPerAccount   = 3
+	maxClaimFileBytes         = 64 * 1024
+	maxClaimedNameLen         = 100
+	maxClaimedBioLen          = 500
+)
+
+var (
+	ErrClaimNotFound     = errors.New("no such account or claim")
+	ErrClaimNotVerified  = errors.New("claim is not verified")
+	ErrClaimTokenMissing = errors.New("claim token not found on site")
+)
+
+// Returned when a publisher's profile changes are not acceptable
+type ClaimValidationError struct {
+	msg string
+}
+
+func (e *ClaimValidationError) Error() string {
+	return e.msg
+}
+
+type IPublisherClaims interface {
+	Start(user string) (token, secret string, err error)
+	Verify(user, secret string) (verifiedBy string, err error)
+	SetProfile(user, secret string, profile *ClaimedProfile) error
+	RequestDeletion(user, secret string) error
+	GetVerifiedClaims() ([]*dal.AccountClaim, error)
+}
+
+// What a verified publisher can customize. Empty values restore the defaults from the feed.
+type ClaimedProfile struct {
+	Name             string
+	Bio              string
+	PublisherAccount string
+}
+
+type publisherClaims struct {
+	cfg       *shared.Config
+	logger    shared.ILogger
+	userAgent shared.IUserAgent
+	repo      dal.IRepo
+	resolver  IDnsResolver
+}
+
+func NewPublisherClaims(
+	cfg *shared.Config,
+	logger shared.ILogger,
+	userAgent shared.IUserAgent,
+	repo dal.IRepo,
+	resolver IDnsResolver,
+) IPublisherClaims {
+	return &publisherClaims{
+		cfg:       cfg,
+		logger:    logger,
+		userAgent: userAgent,
+		repo:      repo,
+		resolver:  resolver,
+	}
+}
+
+// Name to show for the account: the publisher's, if they claimed it and set one, or the feed's title.
+func GetAccountName(acct *dal.Account) string {
+	if acct.ClaimedName != "" {
+		return acct.ClaimedName
+	}
+	return acct.FeedName
+}
+
+// Description to show at the end of the account's bio, as an HTML paragraph.
+// It goes after the acct_bio.html snippet, not into it: snippet values are escaped, and we need line breaks.
+func GetAccountDescription(acct *dal.Account) string {
+	if acct.ClaimedBio != "" {
+		return "<p>" + strings.ReplaceAll(html.EscapeString(acct.ClaimedBio), "\n", "<br>") + "</p>"
+	}
+	return "<p>" + html.EscapeString(acct.FeedSummary) + "</p>"
+}
+
+func hashClaimSecret(secret string) string {
+	digest := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(digest[:])
+}
+
+func makeClaimRandom(nBytes int) (string, error) {
+	buf := make([]byte, nBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (pc *publisherClaims) getClaimableAccount(user string) (*dal.Account, error) {
+	user = strings.ToLower(user)
+	if user == pc.cfg.Birb.User {
+		return nil, ErrClaimNotFound
+	}
+	acct, err := pc.repo.GetAccount(user)
+	if err != nil {
+		return nil, err
+	}
+	if acct == nil {
+		return nil, ErrClaimNotFound
+	}
+	return acct, nil
+}
+
+// Returns the account and the claim that the secret belongs to, if the claim is verified.
+func (pc *publisherClaims) getVerifiedClaim(user, secret string) (*dal.Account, *dal.AccountClaim, error) {
+	acct, err := pc.getClaimableAccount(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	claim, err := pc.repo.GetAccountClaim(acct.Id, hashClaimSecret(secret))
+	if err != nil {
+		return nil, nil, err
+	}
+	if claim == nil {
+		return nil, nil, ErrClaimNotFound
+	}
+	if claim.VerifiedBy == "" {
+		return nil, nil, ErrClaimNotVerified
+	}
+	return acct, claim, nil
+}
+
+// Creates a new claim for the account. The token goes on the site; the secret must be kept to verify the claim,
+// and to make changes afterwards.
+func (pc *publisherClaims) Start(user string) (token, secret string, err error) {
+
+	var acct *dal.Account
+	if acct, err = pc.getClaimableAccount(user); err != nil {
+		return "", "", err
+	}
+	if err = pc.repo.DeleteUnverifiedAccountClaims(time.Now().UTC().AddDate(0, 0, -unverifiedClaimMaxAgeDays)); err != nil {
+		pc.logger.Errorf("Failed to delete stale claims: %v", err)
+	}
+	// Anyone can start a claim, so the account's older open claims make way for the new one
+	if err = pc.repo.DeleteOlderUnverifiedAccountClaims(acct.Id, maxOpenClaimsPerAccount-1); err != nil {
+		return "", "", err
+	}
+	if token, err = makeClaimRandom(16); err != nil {
+		return "", "", err
+	}
+	if secret, err = makeClaimRandom(32); err != nil {
+		return "", "", err
+	}
+	err = pc.repo.AddAccountClaim(&dal.AccountClaim{
+		Token:      token,
+		AccountId:  acct.Id,
+		SecretHash: hashClaimSecret(secret),
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	pc.logger.Infof("Claim started for account %s", acct.Handle)
+	return token, secret, nil
+}
+
+// Looks for the claim's token on the account's site. Verifying an already verified claim succeeds without
+// checking the site again.
+func (pc *publisherClaims) Verify(user, secret string) (verifiedBy string, err error) {
+
+	var acct *dal.Account
+	if acct, err = pc.getClaimableAccount(user); err != nil {
+		return "", err
+	}
+	var claim *dal.AccountClaim
+	if claim, err = pc.repo.GetAccountClaim(acct.Id, hashClaimSecret(secret)); err != nil {
+		return "", err
+	}
+	if claim == nil {
+		return "", ErrClaimNotFound
+	}
+	if claim.VerifiedBy != "" {
+		return claim.VerifiedBy, nil
+	}
+
+	siteUrl, err := url.Parse(acct.SiteUrl)
+	if err != nil || siteUrl.Host == "" {
+		return "", fmt.Errorf("account has no valid site URL: %s", acct.SiteUrl)
+	}
+	if pc.isTokenInMeta(acct.SiteUrl, claim.Token) {
+		verifiedBy = claimVerifiedByMeta
+	} else if pc.isTokenInFile(siteUrl, claim.Token) {
+		verifiedBy = claimVerifiedByFile
+	} else if pc.isTokenInDns(siteUrl.Hostname(), claim.Token) {
+		verifiedBy = claimVerifiedByDns
+	} else {
+		return "", ErrClaimTokenMissing
+	}
+
+	if err = pc.repo.SetAccountClaimVerified(claim.Token, time.Now().UTC(), verifiedBy); err != nil {
+		return "", err
+	}
+	pc.logger.Infof("Claim verified for account %s through %s", acct.Handle, verifiedBy)
+	return verifiedBy, nil
+}
+
+func (pc *publisherClaims) get(urlStr string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	pc.userAgent.AddUserAgent(req)
+	client := http.Client{}
+	client.Timeout = feedOrSiteTimeoutSec * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("request for %s failed with status %d", urlStr, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (pc *publisherClaims) isTokenInMeta(siteUrl, token string) bool {
+	resp, err := pc.get(siteUrl)
+	if err != nil {
+		pc.logger.Infof("Failed to get site for claim verification: %v", err)
+		return false
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		pc.logger.Infof("Failed to parse site for claim verification: %s: %v", siteUrl, err)
+		return false
+	}
+	found := false
+	doc.Find("meta[name]").Each(func(_ int, s *goquery.Selection) {
+		if strings.EqualFold(s.AttrOr("name", ""), ClaimMetaName) && strings.TrimSpace(s.AttrOr("content", "")) == token {
+			found = true
+		}
+	})
+	return found
+}
+
+func (pc *publisherClaims) isTokenInFile(siteUrl *url.URL, token string) bool {
+	fileUrl := url.URL{Scheme: siteUrl.Scheme, Host: siteUrl.Host, Path: ClaimWellKnownPath}
+	resp, err := pc.get(fileUrl.String())
+	if err != nil {
+		pc.logger.Infof("Failed to get claim file: %v", err)
+		return false
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, maxClaimFileBytes))
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == token {
+			return true
+		}
+	}
+	return false
+}
+
+func (pc *publisherClaims) isTokenInDns(host, token string) bool {
+	names := []string{ClaimDnsPrefix + host}
+	if strings.HasPrefix(host, "www.") {
+		names = append(names, ClaimDnsPrefix+strings.TrimPrefix(host, "www."))
+	}
+	for _, name := range names {
+		records, err := pc.resolver.LookupTXT(name)
+		if err != nil {
+			pc.logger.Infof("Failed to look up claim TXT record: %s: %v", name, err)
+			continue
+		}
+		for _, record := range records {
+			if strings.TrimSpace(record) == ClaimDnsValuePrefix+token {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateClaimedProfile(profile *ClaimedProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	profile.Bio = strings.TrimSpace(profile.Bio)
+	profile.PublisherAccount = strings.TrimSpace(profile.PublisherAccount)
+	if utf8.RuneCountInString(profile.Name) > maxClaimedNameLen {
+		return &ClaimValidationError{fmt.Sprintf("name must be at most %d characters", maxClaimedNameLen)}
+	}
+	if utf8.RuneCountInString(profile.Bio) > maxClaimedBioLen {
+		return &ClaimValidationError{fmt.Sprintf("bio must be at most %d characters", maxClaimedBioLen)}
+	}
+	if strings.ContainsAny(profile.Name, "\r\n") {
+		return &ClaimValidationError{"name must be a single line"}
+	}
+	if profile.PublisherAccount != "" {
+		u, err := url.Parse(profile.PublisherAccount)
+		// Goes into a link in the profile as is
+		if err != nil || u.Scheme != "https" || u.Host == "" || strings.ContainsAny(profile.PublisherAccount, "'\"<> ") {
+			return &ClaimValidationError{"publisher account must be an https profile URL"}
+		}
+	}
+	return nil
+}
+
+func (pc *publisherClaims) SetProfile(user, secret string, profile *ClaimedProfile) error {
+
+	acct, _, err := pc.getVerifiedClaim(user, secret)
+	if err != nil {
+		return err
+	}
+	if err = validateClaimedProfile(profile); err != nil {
+		return err
+	}
+	if err = pc.repo.SetAccountClaimedProfile(acct.Id, profile.Name, profile.Bio, profile.PublisherAccount); err != nil {
+		return err
+	}
+	pc.logger.Infof("Claimed profile updated for account %s", acct.Handle)
+	return nil
+}
+
+// Stops parroting the feed right away, and flags the account for the admins to delete.
+func (pc *publisherClaims) RequestDeletion(user, secret string) error {
+
+	acct, claim, err := pc.getVerifiedClaim(user, secret)
+	if err != nil {
+		return err
+	}
+	if err = pc.repo.SetAccountClaimDeletionRequested(claim.Token, time.Now().UTC()); err != nil {
+		return err
+	}
+	if err = pc.repo.SetAccountPollStatus(acct.Id, dal.PollOptedOut); err != nil {
+		return err
+	}
+	pc.logger.Infof("Publisher requested deletion of account %s", acct.Handle)
+	return nil
+}
+
+func (pc *publisherClaims) GetVerifiedClaims() ([]*dal.AccountClaim, error) {
+	return pc.repo.GetVerifiedAccountClaims()
+}
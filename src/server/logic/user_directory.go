@@ -6,6 +6,7 @@ import (
 	"rss_parrot/dto"
 	"rss_parrot/shared"
 	"rss_parrot/texts"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +15,8 @@ import (
 //go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_user_director.go -package mocks rss_parrot/logic IUserDirectory
 
 const pageSize = 2
+const outboxPageSize = 20
+const followersPageSize = 40
 const websiteLinkTemplate = "<a href='%s' target='_blank' rel='nofollow noopener noreferrer me' translate='no'>%s</a>"
 
 // TODO: return error in all of these
@@ -21,11 +24,15 @@ const websiteLinkTemplate = "<a href='%s' target='_blank' rel='nofollow noopener
 type IUserDirectory interface {
 	GetWebfinger(user string) *dto.WebfingerResp
 	GetUserInfo(user string) *dto.UserInfo
-	GetOutboxSummary(user string) *dto.OrderedListSummary
+	GetOutboxSummary(user string) (*dto.OrderedListSummary, error)
+	GetOutboxPage(user string, page int) (*dto.OrderedCollectionPage, error)
 	GetFollowersSummary(user string) *dto.OrderedListSummary
+	GetFollowersPage(user string, page int) (*dto.OrderedCollectionPage, error)
+	GetFollowersOnHost(user, host string) (*dto.OrderedCollection, error)
 	GetFollowingSummary(user string) *dto.OrderedListSummary
-	GetUserStatus(user, statusId string) (*dto.Note, error)
+	GetUserStatus(user, statusId string) (*dto.Note, *dto.Tombstone, error)
 	AcceptFollower(followActId, followerUserUrl, followerInbox, followedUser string) error
+	RejectFollower(followActId, followerUserUrl, followerInbox, followedUser string) error
 }
 
 type userDirectory struct {
@@ -36,6 +43,7 @@ type userDirectory struct {
 	keyStore IKeyStore
 	sender   IActivitySender
 	txt      texts.ITexts
+	media    IMediaCache
 }
 
 func NewUserDirectory(
@@ -45,6 +53,7 @@ func NewUserDirectory(
 	keyStore IKeyStore,
 	sender IActivitySender,
 	txt texts.ITexts,
+	media IMediaCache,
 ) IUserDirectory {
 	return &userDirectory{
 		cfg:      cfg,
@@ -53,7 +62,9 @@ func NewUserDirectory(
 		idb:      shared.IdBuilder{cfg.Host},
 		keyStore: keyStore,
 		sender:   sender,
-		txt:      txt}
+		txt:      txt,
+		media:    media,
+	}
 }
 
 func (udir *userDirectory) GetWebfinger(user string) *dto.WebfingerResp {
@@ -117,11 +128,10 @@ func (udir *userDirectory) fillBirbUserInfo(ui *dto.UserInfo) {
 }
 
 func (udir *userDirectory) fillFeedUserInfo(ui *dto.UserInfo, acct *dal.Account) {
-	ui.Name = shared.GetNameWithParrot(acct.FeedName)
+	ui.Name = shared.GetNameWithParrot(GetAccountName(acct))
 	ui.Summary = udir.txt.WithVals("acct_bio.html", map[string]string{
-		"siteUrl":     udir.idb.SiteUrl(),
-		"description": acct.FeedSummary,
-	})
+		"siteUrl": udir.idb.SiteUrl(),
+	}) + GetAccountDescription(acct)
 	ui.ManuallyApproves = false
 	ui.PublicKey = dto.PublicKey{
 		Id:           udir.idb.UserKeyId(acct.Handle),
@@ -133,13 +143,20 @@ func (udir *userDirectory) fillFeedUserInfo(ui *dto.UserInfo, acct *dal.Account)
 		Name:  "Website",
 		Value: udir.getWebsiteAttachment(acct.SiteUrl),
 	})
+	if acct.PublisherAccount != "" {
+		ui.Attachments = append(ui.Attachments, dto.Attachment{
+			Type:  "PropertyValue",
+			Name:  "Publisher",
+			Value: udir.getWebsiteAttachment(acct.PublisherAccount),
+		})
+	}
 	ui.Icon = dto.Image{
 		Type: "Image",
-		Url:  acct.ProfileImageUrl,
+		Url:  udir.media.GetUrl(acct.Handle, acct.ProfileImageUrl, MediaAvatar),
 	}
 	ui.Image = dto.Image{
 		Type: "Image",
-		Url:  acct.HeaderImageUrl,
+		Url:  udir.media.GetUrl(acct.Handle, acct.HeaderImageUrl, MediaHeader),
 	}
 	if ui.Icon.Url == "" {
 		ui.Icon.Url = udir.cfg.FallbackProfilePic
@@ -181,65 +198,137 @@ func (udir *userDirectory) GetUserInfo(user string) *dto.UserInfo {
 	return &resp
 }
 
-func (udir *userDirectory) GetUserStatus(user, id string) (*dto.Note, error) {
+// Returns the note for a toot we still have, or a tombstone if we had it but deleted it.
+func (udir *userDirectory) GetUserStatus(user, id string) (*dto.Note, *dto.Tombstone, error) {
 
 	var err error
 	var idVal int64
 	var toot *dal.Toot
 
 	if idVal, err = strconv.ParseInt(id, 10, 64); err != nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 	statusIdUrl := udir.idb.UserStatus(user, uint64(idVal))
 
 	if toot, err = udir.repo.GetToot(statusIdUrl); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if toot == nil {
-		return nil, nil
+		var deletedAt time.Time
+		var deleted bool
+		if deletedAt, deleted, err = udir.repo.GetDeletedToot(statusIdUrl); err != nil || !deleted {
+			return nil, nil, err
+		}
+		tombstone := &dto.Tombstone{
+			Context:    "https://www.w3.org/ns/activitystreams",
+			Id:         statusIdUrl,
+			Type:       "Tombstone",
+			FormerType: "Note",
+			Deleted:    deletedAt.UTC().Format(time.RFC3339),
+		}
+		return nil, tombstone, nil
 	}
 
-	// Doing this here is a bit ugly / not-DRY
-	// There should be a single piece of code that creates the note when:
-	// - Toot is actually sent
-	// - Status is retrieved
-	// For now, good enough.
+	return udir.getTootNote(user, toot), nil, nil
+}
 
-	note := &dto.Note{
-		Id:           statusIdUrl,
+// Creates the note for a toot we have stored, in the same shape as the messenger sends it.
+func (udir *userDirectory) getTootNote(user string, toot *dal.Toot) *dto.Note {
+	return &dto.Note{
+		Id:           toot.StatusId,
 		Type:         "Note",
-		Published:    toot.TootedAt.Format(time.RFC3339), // Check: UTC?
+		Published:    toot.TootedAt.UTC().Format(time.RFC3339),
 		Summary:      nil,
 		AttributedTo: udir.idb.UserUrl(user),
 		InReplyTo:    nil,
 		Content:      toot.Content,
 		To:           []string{shared.ActivityPublic},
 		Cc:           []string{udir.idb.UserFollowers(user)},
-		Tag:          nil,
+		Tag:          getNoteHashtags(&udir.idb, user, toot.Hashtags),
+		Attachment:   getNoteAttachments(toot.Attachments),
 	}
-	return note, nil
 }
 
-func (udir *userDirectory) GetOutboxSummary(user string) *dto.OrderedListSummary {
+func getLastPage(itemCount uint, pageSize int) int {
+	return max(1, (int(itemCount)+pageSize-1)/pageSize)
+}
+
+// Returns nil if the user doesn't exist.
+func (udir *userDirectory) GetOutboxSummary(user string) (*dto.OrderedListSummary, error) {
 
 	var err error
-	var exists bool
+	var acct *dal.Account
 	user = strings.ToLower(user)
-	exists, err = udir.repo.DoesAccountExist(user)
-	if err != nil || !exists {
-		return nil // TODO errors
+	if acct, err = udir.repo.GetAccount(user); err != nil || acct == nil {
+		return nil, err
 	}
 
-	var postCount uint
-	postCount, err = udir.repo.GetPostCount(user) // TODO errors
+	// The outbox lists toots, not posts: digests, republished and initial posts make the two counts differ
+	var tootCount uint
+	if tootCount, err = udir.repo.GetTootCount(acct.Id); err != nil {
+		return nil, err
+	}
 
+	first := udir.idb.UserOutboxPage(user, 1)
+	last := udir.idb.UserOutboxPage(user, getLastPage(tootCount, outboxPageSize))
 	resp := dto.OrderedListSummary{
 		Context:    "https://www.w3.org/ns/activitystreams",
-		Id:         udir.idb.UserUrl(user),
+		Id:         udir.idb.UserOutbox(user),
 		Type:       "OrderedCollection",
-		TotalItems: postCount,
+		TotalItems: tootCount,
+		First:      &first,
+		Last:       &last,
 	}
-	return &resp
+	return &resp, nil
+}
+
+// Returns one page of the outbox, with each toot wrapped in the Create activity that announced it.
+// Page numbers start at 1. Returns nil if the user doesn't exist.
+func (udir *userDirectory) GetOutboxPage(user string, page int) (*dto.OrderedCollectionPage, error) {
+
+	var err error
+	var acct *dal.Account
+	user = strings.ToLower(user)
+	if acct, err = udir.repo.GetAccount(user); err != nil || acct == nil {
+		return nil, err
+	}
+
+	var toots []*dal.Toot
+	var total int
+	offset := (page - 1) * outboxPageSize
+	if toots, total, err = udir.repo.GetTootsPage(acct.Id, offset, outboxPageSize); err != nil {
+		return nil, err
+	}
+
+	resp := dto.OrderedCollectionPage{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		Id:           udir.idb.UserOutboxPage(user, page),
+		Type:         "OrderedCollectionPage",
+		TotalItems:   uint(total),
+		PartOf:       udir.idb.UserOutbox(user),
+		OrderedItems: []any{},
+	}
+	for _, toot := range toots {
+		note := udir.getTootNote(user, toot)
+		resp.OrderedItems = append(resp.OrderedItems, &dto.ActivityOut{
+			Id:        toot.StatusId + "/activity",
+			Type:      "Create",
+			Actor:     note.AttributedTo,
+			Published: note.Published,
+			To:        &note.To,
+			Cc:        &note.Cc,
+			Object:    note,
+		})
+	}
+	if page > 1 {
+		prev := udir.idb.UserOutboxPage(user, page-1)
+		resp.Prev = &prev
+	}
+	if offset+len(toots) < total {
+		next := udir.idb.UserOutboxPage(user, page+1)
+		resp.Next = &next
+	}
+	return &resp, nil
 }
 
 func (udir *userDirectory) GetFollowersSummary(user string) *dto.OrderedListSummary {
@@ -261,9 +350,91 @@ func (udir *userDirectory) GetFollowersSummary(user string) *dto.OrderedListSumm
 		Type:       "OrderedCollection",
 		TotalItems: followerCount,
 	}
+	if !udir.cfg.HideFollowers {
+		first := udir.idb.UserFollowersPage(user, 1)
+		last := udir.idb.UserFollowersPage(user, getLastPage(followerCount, followersPageSize))
+		resp.First = &first
+		resp.Last = &last
+	}
 	return &resp
 }
 
+// Returns one page of approved followers' actor URLs. Page numbers start at 1.
+// If followers are hidden, the page only has the total count. Returns nil if the user doesn't exist.
+func (udir *userDirectory) GetFollowersPage(user string, page int) (*dto.OrderedCollectionPage, error) {
+
+	var err error
+	var exists bool
+	user = strings.ToLower(user)
+	if exists, err = udir.repo.DoesAccountExist(user); err != nil || !exists {
+		return nil, err
+	}
+
+	var followers []*dal.FollowerInfo
+	if followers, err = udir.repo.GetFollowersByUser(user, true); err != nil {
+		return nil, err
+	}
+
+	resp := dto.OrderedCollectionPage{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		Id:           udir.idb.UserFollowersPage(user, page),
+		Type:         "OrderedCollectionPage",
+		TotalItems:   uint(len(followers)),
+		PartOf:       udir.idb.UserFollowers(user),
+		OrderedItems: []any{},
+	}
+	if udir.cfg.HideFollowers {
+		return &resp, nil
+	}
+
+	// Followers come from the DB in no particular order; sort them so pages are stable
+	sort.Slice(followers, func(i, j int) bool { return followers[i].UserUrl < followers[j].UserUrl })
+	start := min((page-1)*followersPageSize, len(followers))
+	end := min(start+followersPageSize, len(followers))
+	for _, fi := range followers[start:end] {
+		resp.OrderedItems = append(resp.OrderedItems, fi.UserUrl)
+	}
+	if page > 1 {
+		prev := udir.idb.UserFollowersPage(user, page-1)
+		resp.Prev = &prev
+	}
+	if end < len(followers) {
+		next := udir.idb.UserFollowersPage(user, page+1)
+		resp.Next = &next
+	}
+	return &resp, nil
+}
+
+// Returns the partial followers collection used for followers synchronization:
+// the approved followers whose actor is on the given host. Returns nil if the user doesn't exist.
+func (udir *userDirectory) GetFollowersOnHost(user, host string) (*dto.OrderedCollection, error) {
+
+	var err error
+	var exists bool
+	user = strings.ToLower(user)
+	if exists, err = udir.repo.DoesAccountExist(user); err != nil || !exists {
+		return nil, err
+	}
+
+	var followers []*dal.FollowerInfo
+	if followers, err = udir.repo.GetFollowersByUser(user, true); err != nil {
+		return nil, err
+	}
+	onHost := getFollowersOnHost(followers, strings.ToLower(host))
+
+	resp := dto.OrderedCollection{
+		Context:      "https://www.w3.org/ns/activitystreams",
+		Id:           udir.idb.UserFollowersSync(user),
+		Type:         "OrderedCollection",
+		TotalItems:   uint(len(onHost)),
+		OrderedItems: []any{},
+	}
+	for _, actorUrl := range onHost {
+		resp.OrderedItems = append(resp.OrderedItems, actorUrl)
+	}
+	return &resp, nil
+}
+
 func (udir *userDirectory) GetFollowingSummary(user string) *dto.OrderedListSummary {
 
 	var err error
@@ -287,19 +458,55 @@ func (udir *userDirectory) AcceptFollower(followActId, followerUserUrl, follower
 
 	udir.logger.Infof("Accepting follow %s", followerInbox)
 
-	privKey, err := udir.keyStore.GetPrivKey(followedUser)
+	err := udir.sendFollowResponse("Accept", followActId, followerUserUrl, followerInbox, followedUser, followedUser)
+	if err != nil {
+		return err
+	}
+
+	if err = udir.repo.SetFollowerApproveStatus(followedUser, followerUserUrl, 1); err != nil {
+		err = fmt.Errorf("failed set follower approve status: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Tells the would-be follower that we don't accept their follow. Caller is responsible for not storing the follow.
+// If followedUser doesn't exist, the birb sends the Reject on the instance's behalf.
+func (udir *userDirectory) RejectFollower(followActId, followerUserUrl, followerInbox, followedUser string) error {
+
+	udir.logger.Infof("Rejecting follow %s", followerInbox)
+
+	sendingUser := followedUser
+	acct, err := udir.repo.GetAccount(followedUser)
+	if err != nil {
+		return fmt.Errorf("failed to get account %s: %v", followedUser, err)
+	}
+	if acct == nil {
+		sendingUser = udir.cfg.Birb.User
+	}
+	return udir.sendFollowResponse("Reject", followActId, followerUserUrl, followerInbox, followedUser, sendingUser)
+}
+
+// Sends an Accept or Reject in response to a Follow activity. The response comes from sendingUser,
+// which is normally the followed user.
+func (udir *userDirectory) sendFollowResponse(
+	actType, followActId, followerUserUrl, followerInbox, followedUser, sendingUser string,
+) error {
+
+	privKey, err := udir.keyStore.GetPrivKey(sendingUser)
 	if err != nil {
-		err = fmt.Errorf("failed to get private key for user %s: %v", followedUser, err)
+		err = fmt.Errorf("failed to get private key for user %s: %v", sendingUser, err)
 		return err
 	}
 
-	acceptId := udir.repo.GetNextId()
+	actId := udir.repo.GetNextId()
 
-	actAccept := dto.ActivityOut{
+	act := dto.ActivityOut{
 		Context: "https://www.w3.org/ns/activitystreams",
-		Id:      udir.idb.ActivityUrl(acceptId),
-		Type:    "Accept",
-		Actor:   udir.idb.UserUrl(followedUser),
+		Id:      udir.idb.ActivityUrl(actId),
+		Type:    actType,
+		Actor:   udir.idb.UserUrl(sendingUser),
 		Object: dto.ActivityOut{
 			Id:     followActId,
 			Type:   "Follow",
@@ -308,15 +515,9 @@ func (udir *userDirectory) AcceptFollower(followActId, followerUserUrl, follower
 		},
 	}
 
-	if err = udir.sender.Send(privKey, followedUser, followerInbox, &actAccept); err != nil {
-		err = fmt.Errorf("failed to send 'Accept' activity: %v", err)
+	if err = udir.sender.Send(privKey, sendingUser, followerInbox, &act); err != nil {
+		err = fmt.Errorf("failed to send '%s' activity: %v", actType, err)
 		return err
 	}
-
-	if err = udir.repo.SetFollowerApproveStatus(followedUser, followerUserUrl, 1); err != nil {
-		err = fmt.Errorf("failed set follower approve status: %v", err)
-		return err
-	}
-
 	return nil
 }
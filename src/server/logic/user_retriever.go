@@ -14,12 +14,17 @@ import (
 	"time"
 )
 
+//go:generate mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_user_retriever.go -package mocks rss_parrot/logic IUserRetriever
+
 type IUserRetriever interface {
 	Retrieve(userUrl string) (info *dto.UserInfo, err error)
 }
 
 const retrieveTimeoutSec = 10
 
+// Returned (wrapped) by Retrieve if the remote server says the user does not exist, or no longer exists.
+var ErrUserGone = errors.New("user is gone")
+
 type userRetriever struct {
 	cfg       *shared.Config
 	userAgent shared.IUserAgent
@@ -80,6 +85,9 @@ func (ur *userRetriever) Retrieve(userUrl string) (info *dto.UserInfo, err error
 	var bodyErr error
 	bodyBytes, bodyErr = io.ReadAll(resp.Body)
 
+	if resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: got status %v", ErrUserGone, resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
 		var msg string
 		if bodyErr != nil {
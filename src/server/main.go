@@ -44,8 +44,10 @@ func main() {
 			shared.NewUserAgent,
 			server.NewHTTPServer,
 			fx.Annotate(server.NewMux, fx.ParamTags(`group:"handler_group"`)),
+			logic.NewMediaCache,
 			logic.NewKeyStore,
 			logic.NewBlockedFeeds,
+			logic.NewDomainBlocks,
 			logic.NewMetrics,
 			logic.NewFeedFollower,
 			logic.NewUserDirectory,
@@ -54,12 +56,16 @@ func main() {
 			logic.NewUserRetriever,
 			logic.NewMessenger,
 			logic.NewInbox,
+			logic.NewDnsResolver,
+			logic.NewPublisherClaims,
 			texts.NewTexts,
 			dal.NewRepo,
 			asHandlerGroupDef(server.NewApubHandlerGroup),
 			asHandlerGroupDef(server.NewApiHandlerGroup),
 			asHandlerGroupDef(server.NewWebHandlerGroup),
 			asHandlerGroupDef(server.NewMetricsHandlerGroup),
+			asHandlerGroupDef(server.NewWebSubHandlerGroup),
+			asHandlerGroupDef(server.NewClaimHandlerGroup),
 		),
 		fx.Invoke(
 			registerHooks,
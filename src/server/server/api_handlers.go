@@ -14,23 +14,38 @@ import (
 // curl -X POST -H "X-API-KEY: 5QLbv8hrifgdXCEN" 'https://rss-parrot.zydeo.net/api/actions/vacuum'
 
 type apiHandlerGroup struct {
-	cfg    *shared.Config
-	logger shared.ILogger
-	fdfol  logic.IFeedFollower
-	repo   dal.IRepo
+	cfg          *shared.Config
+	logger       shared.ILogger
+	fdfol        logic.IFeedFollower
+	domainBlocks logic.IDomainBlocks
+	blockedFeeds logic.IBlockedFeeds
+	udir         logic.IUserDirectory
+	claims       logic.IPublisherClaims
+	media        logic.IMediaCache
+	repo         dal.IRepo
 }
 
 func NewApiHandlerGroup(
 	cfg *shared.Config,
 	logger shared.ILogger,
 	fdfol logic.IFeedFollower,
+	domainBlocks logic.IDomainBlocks,
+	blockedFeeds logic.IBlockedFeeds,
+	udir logic.IUserDirectory,
+	claims logic.IPublisherClaims,
+	media logic.IMediaCache,
 	repo dal.IRepo,
 ) IHandlerGroup {
 	res := apiHandlerGroup{
-		cfg:    cfg,
-		logger: logger,
-		fdfol:  fdfol,
-		repo:   repo,
+		cfg:          cfg,
+		logger:       logger,
+		fdfol:        fdfol,
+		domainBlocks: domainBlocks,
+		blockedFeeds: blockedFeeds,
+		udir:         udir,
+		claims:       claims,
+		media:        media,
+		repo:         repo,
 	}
 	return &res
 }
@@ -42,7 +57,21 @@ func (hg *apiHandlerGroup) Prefix() string {
 func (hg *apiHandlerGroup) GroupDefs() []handlerDef {
 	return []handlerDef{
 		{"POST", "/feeds", func(w http.ResponseWriter, r *http.Request) { hg.postFeeds(w, r) }},
+		{"GET", "/feeds/failing", func(w http.ResponseWriter, r *http.Request) { hg.getFailingFeeds(w, r) }},
 		{"DELETE", "/accounts/{account}", func(w http.ResponseWriter, r *http.Request) { hg.deleteAccount(w, r) }},
+		{"POST", "/accounts/{account}/resume", func(w http.ResponseWriter, r *http.Request) { hg.postResumeAccount(w, r) }},
+		{"PUT", "/accounts/{account}/hashtags", func(w http.ResponseWriter, r *http.Request) { hg.putAccountHashtags(w, r) }},
+		{"PUT", "/accounts/{account}/flood-limit", func(w http.ResponseWriter, r *http.Request) { hg.putAccountFloodLimit(w, r) }},
+		{"GET", "/domain-blocks", func(w http.ResponseWriter, r *http.Request) { hg.getDomainBlocks(w, r) }},
+		{"POST", "/domain-blocks", func(w http.ResponseWriter, r *http.Request) { hg.postDomainBlocks(w, r) }},
+		{"DELETE", "/domain-blocks/{domain}", func(w http.ResponseWriter, r *http.Request) { hg.deleteDomainBlock(w, r) }},
+		{"GET", "/blocked-feeds", func(w http.ResponseWriter, r *http.Request) { hg.getBlockedFeeds(w, r) }},
+		{"POST", "/blocked-feeds", func(w http.ResponseWriter, r *http.Request) { hg.postBlockedFeeds(w, r) }},
+		{"DELETE", "/blocked-feeds", func(w http.ResponseWriter, r *http.Request) { hg.deleteBlockedFeed(w, r) }},
+		{"GET", "/birb/followers/pending", func(w http.ResponseWriter, r *http.Request) { hg.getPendingBirbFollowers(w, r) }},
+		{"POST", "/birb/followers/approve", func(w http.ResponseWriter, r *http.Request) { hg.postBirbFollowerDecision(w, r, true) }},
+		{"POST", "/birb/followers/reject", func(w http.ResponseWriter, r *http.Request) { hg.postBirbFollowerDecision(w, r, false) }},
+		{"GET", "/claims", func(w http.ResponseWriter, r *http.Request) { hg.getClaims(w, r) }},
 		{"POST", "/actions/vacuum", func(w http.ResponseWriter, r *http.Request) { hg.postActionsVacuum(w, r) }},
 	}
 }
@@ -115,6 +144,417 @@ func (hg *apiHandlerGroup) deleteAccount(w http.ResponseWriter, r *http.Request)
 		writeErrorResponse(w, msg, http.StatusInternalServerError)
 		return
 	}
+	if err = hg.media.PurgeAccount(acct.Handle); err != nil {
+		// Media GC will get to it later
+		hg.logger.Warnf("Failed to remove media of deleted account %s: %v", acct.Handle, err)
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+func (hg *apiHandlerGroup) postResumeAccount(w http.ResponseWriter, r *http.Request) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	accountName := mux.Vars(r)["account"]
+	var acct *dal.Account
+	acct, err = hg.repo.GetAccount(accountName)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get account: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if acct == nil {
+		msg := fmt.Sprintf("Account not found: %s", accountName)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+	// A blocked feed must be unblocked before it's checked again
+	for _, urlStr := range []string{acct.FeedUrl, acct.SiteUrl} {
+		if blocked, _ := hg.blockedFeeds.IsBlocked(urlStr); urlStr != "" && blocked {
+			msg := fmt.Sprintf("Feed is blocked: %s", urlStr)
+			writeErrorResponse(w, msg, http.StatusConflict)
+			return
+		}
+	}
+
+	if err = hg.repo.ResumeAccountPolling(acct.Id); err != nil {
+		msg := fmt.Sprintf("Failed to resume checking account's feed: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+// Turns hashtags from post categories on or off for the account's future toots
+func (hg *apiHandlerGroup) putAccountHashtags(w http.ResponseWriter, r *http.Request) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		hg.logger.Info("Empty request body")
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var setting dto.AccountHashtags
+	if err = json.Unmarshal(bodyBytes, &setting); err != nil {
+		msg := fmt.Sprintf("Invalid JSON in request body: %v", err)
+		hg.logger.Info(msg)
+		writeErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	accountName := mux.Vars(r)["account"]
+	var acct *dal.Account
+	acct, err = hg.repo.GetAccount(accountName)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get account: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if acct == nil {
+		msg := fmt.Sprintf("Account not found: %s", accountName)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if err = hg.repo.SetAccountNoHashtags(acct.Id, !setting.Enabled); err != nil {
+		msg := fmt.Sprintf("Failed to set account's hashtags: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+// Sets how many new posts a single check of the account's feed may toot before they go into a digest
+func (hg *apiHandlerGroup) putAccountFloodLimit(w http.ResponseWriter, r *http.Request) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		hg.logger.Info("Empty request body")
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var setting dto.AccountFloodLimit
+	if err = json.Unmarshal(bodyBytes, &setting); err != nil {
+		msg := fmt.Sprintf("Invalid JSON in request body: %v", err)
+		hg.logger.Info(msg)
+		writeErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	if setting.Limit < -1 {
+		writeErrorResponse(w, "Limit must be -1, 0 or positive", http.StatusBadRequest)
+		return
+	}
+
+	accountName := mux.Vars(r)["account"]
+	var acct *dal.Account
+	acct, err = hg.repo.GetAccount(accountName)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get account: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if acct == nil {
+		msg := fmt.Sprintf("Account not found: %s", accountName)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if err = hg.repo.SetAccountFloodLimit(acct.Id, setting.Limit); err != nil {
+		msg := fmt.Sprintf("Failed to set account's flood limit: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+func (hg *apiHandlerGroup) getFailingFeeds(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	accts, err := hg.repo.GetFailingAccounts(1)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get failing accounts: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]dto.Feed, 0, len(accts))
+	for _, acct := range accts {
+		res = append(res, feedFromAccount(acct))
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+func (hg *apiHandlerGroup) getDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	blocks, err := hg.domainBlocks.GetAll()
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get blocked domains: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]dto.DomainBlock, 0, len(blocks))
+	for _, bd := range blocks {
+		res = append(res, dto.DomainBlock{Domain: bd.Domain, CreatedAt: bd.CreatedAt, Comment: bd.Comment})
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+func (hg *apiHandlerGroup) postDomainBlocks(w http.ResponseWriter, r *http.Request) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		hg.logger.Info("Empty request body")
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var block dto.DomainBlock
+	if err = json.Unmarshal(bodyBytes, &block); err != nil {
+		msg := fmt.Sprintf("Invalid JSON in request body: %v", err)
+		hg.logger.Info(msg)
+		writeErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	if block.Domain == "" {
+		writeErrorResponse(w, "Missing domain", http.StatusBadRequest)
+		return
+	}
+
+	isNew, removedFollowers, err := hg.domainBlocks.Block(block.Domain, block.Comment)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to block domain: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+	}
+	writeJsonResponseWithStatus(hg.logger, w, rtPlainJson, status, map[string]int{"removed_followers": removedFollowers})
+}
+
+func (hg *apiHandlerGroup) deleteDomainBlock(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	domain := mux.Vars(r)["domain"]
+	removed, err := hg.domainBlocks.Unblock(domain)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to unblock domain: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		msg := fmt.Sprintf("Domain is not blocked: %s", domain)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+func (hg *apiHandlerGroup) getBlockedFeeds(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	entries := hg.blockedFeeds.GetAll()
+	res := make([]dto.BlockedFeed, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, dto.BlockedFeed{Pattern: entry.Pattern, Reason: entry.Reason})
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+func (hg *apiHandlerGroup) postBlockedFeeds(w http.ResponseWriter, r *http.Request) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		hg.logger.Info("Empty request body")
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var entry dto.BlockedFeed
+	if err = json.Unmarshal(bodyBytes, &entry); err != nil {
+		msg := fmt.Sprintf("Invalid JSON in request body: %v", err)
+		hg.logger.Info(msg)
+		writeErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+	if entry.Pattern == "" {
+		writeErrorResponse(w, "Missing pattern", http.StatusBadRequest)
+		return
+	}
+
+	isNew, stoppedAccounts, err := hg.blockedFeeds.Block(entry.Pattern, entry.Reason, entry.Purge)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to block feeds: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if isNew {
+		status = http.StatusCreated
+	}
+	writeJsonResponseWithStatus(hg.logger, w, rtPlainJson, status, map[string][]string{"stopped_accounts": stoppedAccounts})
+}
+
+// Patterns contain slashes, so this one is in the query string: /blocked-feeds?pattern=example.com/blog/
+func (hg *apiHandlerGroup) deleteBlockedFeed(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		writeErrorResponse(w, "Missing pattern", http.StatusBadRequest)
+		return
+	}
+	removed, err := hg.blockedFeeds.Unblock(pattern)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to unblock feeds: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		msg := fmt.Sprintf("Pattern is not blocked: %s", pattern)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+
+	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
+}
+
+// Accounts whose publishers verified their claim, including those that asked for their account to be deleted
+func (hg *apiHandlerGroup) getClaims(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	claims, err := hg.claims.GetVerifiedClaims()
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get claims: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]dto.AccountClaim, 0, len(claims))
+	for _, claim := range claims {
+		item := dto.AccountClaim{Handle: claim.Handle, VerifiedAt: claim.VerifiedAt, VerifiedBy: claim.VerifiedBy}
+		// Deletion can only be requested through a verified claim
+		if claim.DeletionRequestedAt.After(claim.VerifiedAt) {
+			requestedAt := claim.DeletionRequestedAt
+			item.DeletionRequestedAt = &requestedAt
+		}
+		res = append(res, item)
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+// Followers waiting for approval, if the birb manually approves follows
+func (hg *apiHandlerGroup) getPendingBirbFollowers(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	pending, err := hg.getPendingFollowers(hg.cfg.Birb.User)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to get followers: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]dto.Follower, 0, len(pending))
+	for _, flwr := range pending {
+		res = append(res, dto.Follower{UserUrl: flwr.UserUrl, Handle: flwr.Handle, Host: flwr.Host})
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+func (hg *apiHandlerGroup) getPendingFollowers(user string) ([]*dal.FollowerInfo, error) {
+	followers, err := hg.repo.GetFollowersByUser(user, false)
+	if err != nil {
+		return nil, err
+	}
+	var res []*dal.FollowerInfo
+	for _, flwr := range followers {
+		if flwr.ApproveStatus == 0 {
+			res = append(res, flwr)
+		}
+	}
+	return res, nil
+}
+
+// Accepts or rejects a pending follower of the birb. Rejected followers are removed.
+func (hg *apiHandlerGroup) postBirbFollowerDecision(w http.ResponseWriter, r *http.Request, approve bool) {
+	var err error
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		hg.logger.Info("Empty request body")
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var follower dto.Follower
+	if err = json.Unmarshal(bodyBytes, &follower); err != nil {
+		msg := fmt.Sprintf("Invalid JSON in request body: %v", err)
+		hg.logger.Info(msg)
+		writeErrorResponse(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	birb := hg.cfg.Birb.User
+	var pending []*dal.FollowerInfo
+	if pending, err = hg.getPendingFollowers(birb); err != nil {
+		msg := fmt.Sprintf("Failed to get followers: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
+	var flwr *dal.FollowerInfo
+	for _, pf := range pending {
+		if pf.UserUrl == follower.UserUrl {
+			flwr = pf
+		}
+	}
+	if flwr == nil {
+		msg := fmt.Sprintf("No pending follower: %s", follower.UserUrl)
+		writeErrorResponse(w, msg, http.StatusNotFound)
+		return
+	}
+
+	if approve {
+		err = hg.udir.AcceptFollower(flwr.RequestId, flwr.UserUrl, flwr.UserInbox, birb)
+	} else if err = hg.udir.RejectFollower(flwr.RequestId, flwr.UserUrl, flwr.UserInbox, birb); err == nil {
+		err = hg.repo.RemoveFollower(birb, flwr.UserUrl)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to respond to follower: %v", err)
+		hg.logger.Error(msg)
+		writeErrorResponse(w, msg, http.StatusInternalServerError)
+		return
+	}
 
 	writeJsonResponse(hg.logger, w, rtPlainJson, "OK")
 }
@@ -165,23 +605,33 @@ func (hg *apiHandlerGroup) postFeeds(w http.ResponseWriter, r *http.Request) {
 		writeErrorResponse(w, msg, http.StatusInternalServerError)
 		return
 	}
-	res := dto.Feed{
+	res := feedFromAccount(acct)
+
+	if status == logic.FsNew {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, res)
+}
+
+func feedFromAccount(acct *dal.Account) dto.Feed {
+	return dto.Feed{
 		CreatedAt:       acct.CreatedAt,
 		UserUrl:         acct.UserUrl,
 		Handle:          acct.Handle,
 		FeedName:        acct.FeedName,
 		FeedSummary:     acct.FeedSummary,
 		ProfileImageUrl: acct.ProfileImageUrl,
+		HeaderImageUrl:  acct.HeaderImageUrl,
 		SiteUrl:         acct.SiteUrl,
 		FeedUrl:         acct.FeedUrl,
 		FeedLastUpdated: acct.FeedLastUpdated,
 		NextCheckDue:    acct.NextCheckDue,
+		PollStatus:      acct.PollStatus.String(),
+		CheckFailures:   acct.CheckFailures,
+		LastCheckError:  acct.LastCheckError,
+		Hashtags:        !acct.NoHashtags,
+		FloodLimit:      acct.FloodLimit,
 	}
-
-	if status == logic.FsNew {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusOK)
-	}
-	writeJsonResponse(hg.logger, w, rtPlainJson, res)
 }
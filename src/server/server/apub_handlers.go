@@ -5,22 +5,28 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
+	"net/url"
 	"regexp"
 	"rss_parrot/dto"
 	"rss_parrot/logic"
 	"rss_parrot/shared"
+	"strconv"
 )
 
+// Remote servers may cache the outbox this long. New toots show up in it with this much delay at most.
+const outboxCacheMaxAgeSec = 300
+
 // Groups together the handlers needed to implement an ActivityPub server.
 type apubHandlerGroup struct {
-	cfg        *shared.Config
-	logger     shared.ILogger
-	metrics    logic.IMetrics
-	sender     logic.IActivitySender
-	sigChecker logic.IHttpSigChecker
-	udir       logic.IUserDirectory
-	inbox      logic.IInbox
-	reResource *regexp.Regexp
+	cfg          *shared.Config
+	logger       shared.ILogger
+	metrics      logic.IMetrics
+	sender       logic.IActivitySender
+	sigChecker   logic.IHttpSigChecker
+	udir         logic.IUserDirectory
+	inbox        logic.IInbox
+	domainBlocks logic.IDomainBlocks
+	reResource   *regexp.Regexp
 }
 
 func NewApubHandlerGroup(
@@ -31,15 +37,17 @@ func NewApubHandlerGroup(
 	sigChecker logic.IHttpSigChecker,
 	udir logic.IUserDirectory,
 	ibox logic.IInbox,
+	domainBlocks logic.IDomainBlocks,
 ) IHandlerGroup {
 	res := apubHandlerGroup{
-		cfg:        cfg,
-		logger:     logger,
-		metrics:    metrics,
-		sender:     sender,
-		sigChecker: sigChecker,
-		udir:       udir,
-		inbox:      ibox,
+		cfg:          cfg,
+		logger:       logger,
+		metrics:      metrics,
+		sender:       sender,
+		sigChecker:   sigChecker,
+		udir:         udir,
+		inbox:        ibox,
+		domainBlocks: domainBlocks,
 	}
 	res.reResource = regexp.MustCompile("^acct:([^@]+)@([^@]+)$")
 	return &res
@@ -55,6 +63,7 @@ func (hg *apubHandlerGroup) GroupDefs() []handlerDef {
 		{"GET", "/u/{user}", func(w http.ResponseWriter, r *http.Request) { hg.getUser(w, r) }},
 		{"GET", "/u/{user}/outbox", func(w http.ResponseWriter, r *http.Request) { hg.getUserOutbox(w, r) }},
 		{"GET", "/u/{user}/followers", func(w http.ResponseWriter, r *http.Request) { hg.getUserFollowers(w, r) }},
+		{"GET", "/u/{user}/followers_synchronization", func(w http.ResponseWriter, r *http.Request) { hg.getUserFollowersSync(w, r) }},
 		{"GET", "/u/{user}/following", func(w http.ResponseWriter, r *http.Request) { hg.getUserFollowing(w, r) }},
 		{"GET", "/u/{user}/status/{id}", func(w http.ResponseWriter, r *http.Request) { hg.getUserStatus(w, r) }},
 		{"POST", "/u/{user}/inbox", func(w http.ResponseWriter, r *http.Request) { hg.postInbox(w, r) }},
@@ -138,12 +147,19 @@ func (hg *apubHandlerGroup) getUserStatus(w http.ResponseWriter, r *http.Request
 
 	var err error
 	var note *dto.Note
-	if note, err = hg.udir.GetUserStatus(userName, statusId); err != nil {
+	var tombstone *dto.Tombstone
+	if note, tombstone, err = hg.udir.GetUserStatus(userName, statusId); err != nil {
 		hg.logger.Infof("Error retrieving status %s/%s: %v", userName, statusId, err)
 		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
 		return
 	}
 
+	if tombstone != nil {
+		hg.logger.Infof("User status was deleted: %s/%s", userName, statusId)
+		writeJsonResponseWithStatus(hg.logger, w, rtActivityJson, http.StatusGone, tombstone)
+		return
+	}
+
 	if note == nil {
 		hg.logger.Infof("User status not found: %s/%s", userName, statusId)
 		writeErrorResponse(w, "User or status not found", http.StatusNotFound)
@@ -160,15 +176,51 @@ func (hg *apubHandlerGroup) getUserOutbox(w http.ResponseWriter, r *http.Request
 	defer obs.Finish()
 
 	userName := mux.Vars(r)["user"]
-	summary := hg.udir.GetOutboxSummary(userName)
+	pageParam := r.URL.Query().Get("page")
+	if pageParam != "" {
+		hg.getUserOutboxPage(w, userName, pageParam)
+		return
+	}
+
+	summary, err := hg.udir.GetOutboxSummary(userName)
+	if err != nil {
+		hg.logger.Errorf("Error retrieving outbox for '%s': %v", userName, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
 	if summary == nil {
 		hg.logger.Infof("Outbox requested for unknown user: '%s'", userName)
 		writeErrorResponse(w, "No such user", http.StatusNotFound)
 		return
 	}
+	setCacheMaxAge(w, outboxCacheMaxAgeSec)
 	writeJsonResponse(hg.logger, w, rtActivityJson, summary)
 }
 
+func (hg *apubHandlerGroup) getUserOutboxPage(w http.ResponseWriter, userName, pageParam string) {
+
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		hg.logger.Infof("Outbox: Invalid 'page' param: '%s'", pageParam)
+		writeErrorResponse(w, "Invalid 'page' param", http.StatusBadRequest)
+		return
+	}
+
+	var resp *dto.OrderedCollectionPage
+	if resp, err = hg.udir.GetOutboxPage(userName, page); err != nil {
+		hg.logger.Errorf("Error retrieving outbox page %d for '%s': %v", page, userName, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
+	if resp == nil {
+		hg.logger.Infof("Outbox page requested for unknown user: '%s'", userName)
+		writeErrorResponse(w, "No such user", http.StatusNotFound)
+		return
+	}
+	setCacheMaxAge(w, outboxCacheMaxAgeSec)
+	writeJsonResponse(hg.logger, w, rtActivityJson, resp)
+}
+
 func (hg *apubHandlerGroup) getUserFollowers(w http.ResponseWriter, r *http.Request) {
 
 	hg.logger.Infof("Handling user followers GET: %s", r.URL.Path)
@@ -176,6 +228,12 @@ func (hg *apubHandlerGroup) getUserFollowers(w http.ResponseWriter, r *http.Requ
 	defer obs.Finish()
 
 	userName := mux.Vars(r)["user"]
+	pageParam := r.URL.Query().Get("page")
+	if pageParam != "" {
+		hg.getUserFollowersPage(w, userName, pageParam)
+		return
+	}
+
 	summary := hg.udir.GetFollowersSummary(userName)
 	if summary == nil {
 		hg.logger.Infof("Followers requested for unknown user: '%s'", userName)
@@ -185,6 +243,67 @@ func (hg *apubHandlerGroup) getUserFollowers(w http.ResponseWriter, r *http.Requ
 	writeJsonResponse(hg.logger, w, rtActivityJson, summary)
 }
 
+func (hg *apubHandlerGroup) getUserFollowersPage(w http.ResponseWriter, userName, pageParam string) {
+
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		hg.logger.Infof("Followers: Invalid 'page' param: '%s'", pageParam)
+		writeErrorResponse(w, "Invalid 'page' param", http.StatusBadRequest)
+		return
+	}
+
+	var resp *dto.OrderedCollectionPage
+	if resp, err = hg.udir.GetFollowersPage(userName, page); err != nil {
+		hg.logger.Errorf("Error retrieving followers page %d for '%s': %v", page, userName, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
+	if resp == nil {
+		hg.logger.Infof("Followers page requested for unknown user: '%s'", userName)
+		writeErrorResponse(w, "No such user", http.StatusNotFound)
+		return
+	}
+	writeJsonResponse(hg.logger, w, rtActivityJson, resp)
+}
+
+func (hg *apubHandlerGroup) getUserFollowersSync(w http.ResponseWriter, r *http.Request) {
+
+	hg.logger.Infof("Handling user followers synchronization GET: %s", r.URL.Path)
+	obs := hg.metrics.StartApubRequestIn("user/followers_synchronization")
+	defer obs.Finish()
+
+	// Only a server itself gets to see which of its users follow us
+	senderInfo, sigProblem, err := hg.sigChecker.CheckByKeyId(w, r)
+	if err != nil {
+		hg.logger.Errorf("Unexpected error trying to verify signature: %v", err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
+	if sigProblem != "" {
+		hg.logger.Infof("Followers synchronization: %s", sigProblem)
+		writeErrorResponse(w, sigProblem, http.StatusUnauthorized)
+		return
+	}
+
+	userName := mux.Vars(r)["user"]
+	senderHost := ""
+	if senderUrl, parseErr := url.Parse(senderInfo.Id); parseErr == nil {
+		senderHost = senderUrl.Host
+	}
+	var resp *dto.OrderedCollection
+	if resp, err = hg.udir.GetFollowersOnHost(userName, senderHost); err != nil {
+		hg.logger.Errorf("Error retrieving followers on host %s for '%s': %v", senderHost, userName, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
+	if resp == nil {
+		hg.logger.Infof("Followers synchronization requested for unknown user: '%s'", userName)
+		writeErrorResponse(w, "No such user", http.StatusNotFound)
+		return
+	}
+	writeJsonResponse(hg.logger, w, rtActivityJson, resp)
+}
+
 func (hg *apubHandlerGroup) getUserFollowing(w http.ResponseWriter, r *http.Request) {
 
 	hg.logger.Infof("Handling user following GET: %s", r.URL.Path)
@@ -233,6 +352,13 @@ func (hg *apubHandlerGroup) postInbox(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Don't even fetch keys of users on blocked instances. Follows go on, so inbox can send them a Reject.
+	if act.Type != "Follow" && hg.domainBlocks.IsBlocked(act.Actor) {
+		hg.logger.Infof("Dropping '%s' activity from blocked domain: %s", act.Type, act.Actor)
+		writeErrorResponse(w, "Domain is blocked", http.StatusForbidden)
+		return
+	}
+
 	// Verify signature
 	var senderInfo *dto.UserInfo
 	var sigProblem string
@@ -246,8 +372,9 @@ func (hg *apubHandlerGroup) postInbox(w http.ResponseWriter, r *http.Request) {
 
 	if sigProblem != "" {
 		if act.Type == "Delete" {
-			hg.logger.Infof("Ignoring Delete request with unverified actor signature")
-			writeJsonResponse(hg.logger, w, rtActivityJson, "OK")
+			// Deleted actors' keys cannot be retrieved anymore; inbox checks with the actor's server instead
+			hg.logger.Infof("Delete request with unverified actor signature: %s", sigProblem)
+			hg.processActivity(userName, bodyBytes, nil, act, w)
 		} else {
 			hg.logger.Warnf("Incorrectly signed inbox POST request: %s", sigProblem)
 			msg := fmt.Sprintf("Invalid HTTP signature: %s", sigProblem)
@@ -288,6 +415,10 @@ func (hg *apubHandlerGroup) processActivity(
 		if objectType == "Note" {
 			reqProblem, err = hg.inbox.HandleCreateNote(act, senderInfo, bodyBytes)
 		}
+	} else if act.Type == "Delete" {
+		reqProblem, err = hg.inbox.HandleDelete(senderInfo, bodyBytes)
+	} else if act.Type == "Move" {
+		reqProblem, err = hg.inbox.HandleMove(senderInfo, bodyBytes)
 	}
 
 	if err != nil {
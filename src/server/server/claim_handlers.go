@@ -0,0 +1,212 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"rss_parrot/dto"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Publishers claim their feed's account without an API key. They get a secret when they start a claim,
+// and send it as a bearer token to verify the claim and to make changes afterwards:
+// curl -X POST -H "Authorization: Bearer SECRET" 'https://rss-parrot.net/claim/example.com/verify'
+
+const claimAuthScheme = "Bearer "
+
+// Starting a claim needs no secret, so an account only gets a few new claims in a while
+const (
+	claimStartsPerWindow = 5
+	claimStartWindow     = 10 * time.Minute
+)
+
+type claimHandlerGroup struct {
+	cfg          *shared.Config
+	logger       shared.ILogger
+	claims       logic.IPublisherClaims
+	muStarts     sync.Mutex
+	recentStarts map[string][]time.Time // Times of claims started in the current window, by account
+}
+
+func NewClaimHandlerGroup(
+	cfg *shared.Config,
+	logger shared.ILogger,
+	claims logic.IPublisherClaims,
+) IHandlerGroup {
+	res := claimHandlerGroup{
+		cfg:          cfg,
+		logger:       logger,
+		claims:       claims,
+		recentStarts: make(map[string][]time.Time),
+	}
+	return &res
+}
+
+func (hg *claimHandlerGroup) Prefix() string {
+	return "/claim"
+}
+
+func (hg *claimHandlerGroup) GroupDefs() []handlerDef {
+	return []handlerDef{
+		{"POST", "/{account}", func(w http.ResponseWriter, r *http.Request) { hg.postClaim(w, r) }},
+		{"POST", "/{account}/verify", func(w http.ResponseWriter, r *http.Request) { hg.postVerify(w, r) }},
+		{"PUT", "/{account}/profile", func(w http.ResponseWriter, r *http.Request) { hg.putProfile(w, r) }},
+		{"DELETE", "/{account}", func(w http.ResponseWriter, r *http.Request) { hg.deleteAccount(w, r) }},
+	}
+}
+
+func (hg *claimHandlerGroup) AuthMW() func(next http.Handler) http.Handler {
+	// Requests are authorized by the claim's secret, which each handler checks for its own account
+	return emptyMW
+}
+
+// Returns the secret from the Authorization header, or writes an error response and returns an empty string
+func getClaimSecret(w http.ResponseWriter, r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, claimAuthScheme) || len(auth) == len(claimAuthScheme) {
+		writeErrorResponse(w, badAuthorization, http.StatusUnauthorized)
+		return ""
+	}
+	return strings.TrimPrefix(auth, claimAuthScheme)
+}
+
+func (hg *claimHandlerGroup) writeClaimError(w http.ResponseWriter, account string, err error) {
+	if errors.Is(err, logic.ErrClaimNotFound) {
+		writeErrorResponse(w, notFoundStr, http.StatusNotFound)
+	} else if errors.Is(err, logic.ErrClaimNotVerified) {
+		writeErrorResponse(w, err.Error(), http.StatusForbidden)
+	} else if errors.Is(err, logic.ErrClaimTokenMissing) {
+		writeErrorResponse(w, err.Error(), http.StatusConflict)
+	} else {
+		hg.logger.Errorf("Failed to handle claim for %s: %v", account, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+	}
+}
+
+// Records a claim start for the account, unless it has had too many of them recently
+func (hg *claimHandlerGroup) allowClaimStart(account string) bool {
+
+	hg.muStarts.Lock()
+	defer hg.muStarts.Unlock()
+
+	windowStart := time.Now().Add(-claimStartWindow)
+	for key, starts := range hg.recentStarts {
+		kept := starts[:0]
+		for _, t := range starts {
+			if t.After(windowStart) {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) == 0 {
+			delete(hg.recentStarts, key)
+		} else {
+			hg.recentStarts[key] = kept
+		}
+	}
+	account = strings.ToLower(account)
+	if len(hg.recentStarts[account]) >= claimStartsPerWindow {
+		return false
+	}
+	hg.recentStarts[account] = append(hg.recentStarts[account], time.Now())
+	return true
+}
+
+func (hg *claimHandlerGroup) postClaim(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	if !hg.allowClaimStart(account) {
+		hg.logger.Infof("Too many claims started for %s", account)
+		writeErrorResponse(w, "Too many claims started for this account; try again later", http.StatusTooManyRequests)
+		return
+	}
+	token, secret, err := hg.claims.Start(account)
+	if err != nil {
+		hg.writeClaimError(w, account, err)
+		return
+	}
+
+	res := dto.ClaimStarted{
+		Token:  token,
+		Secret: secret,
+		Instructions: map[string]string{
+			"meta": fmt.Sprintf(`Add <meta name="%s" content="%s"> to your site's page`, logic.ClaimMetaName, token),
+			"file": fmt.Sprintf("Put the token on a line of its own in %s on your site's host", logic.ClaimWellKnownPath),
+			"dns":  fmt.Sprintf(`Add a TXT record "%s%s" to %s<your site's host>`, logic.ClaimDnsValuePrefix, token, logic.ClaimDnsPrefix),
+		},
+	}
+	writeJsonResponseWithStatus(hg.logger, w, rtPlainJson, http.StatusCreated, res)
+}
+
+func (hg *claimHandlerGroup) postVerify(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	secret := getClaimSecret(w, r)
+	if secret == "" {
+		return
+	}
+	verifiedBy, err := hg.claims.Verify(account, secret)
+	if err != nil {
+		hg.writeClaimError(w, account, err)
+		return
+	}
+	writeJsonResponse(hg.logger, w, rtPlainJson, dto.ClaimVerified{VerifiedBy: verifiedBy})
+}
+
+func (hg *claimHandlerGroup) putProfile(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	secret := getClaimSecret(w, r)
+	if secret == "" {
+		return
+	}
+	bodyBytes := readBody(hg.logger, w, r)
+	if bodyBytes == nil {
+		writeErrorResponse(w, "Request body must not be empty", http.StatusBadRequest)
+		return
+	}
+	var profile dto.ClaimedProfile
+	if err := json.Unmarshal(bodyBytes, &profile); err != nil {
+		writeErrorResponse(w, fmt.Sprintf("Invalid JSON in request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err := hg.claims.SetProfile(account, secret, &logic.ClaimedProfile{
+		Name:             profile.Name,
+		Bio:              profile.Bio,
+		PublisherAccount: profile.PublisherAccount,
+	})
+	var validationErr *logic.ClaimValidationError
+	if errors.As(err, &validationErr) {
+		writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		hg.writeClaimError(w, account, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (hg *claimHandlerGroup) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling %s %s", r.Method, r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	secret := getClaimSecret(w, r)
+	if secret == "" {
+		return
+	}
+	if err := hg.claims.RequestDeletion(account, secret); err != nil {
+		hg.writeClaimError(w, account, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -13,6 +13,7 @@ const (
 	wwwPathPrefx       = "www/"
 	apiKeyHeader       = "X-API-KEY"
 	metricsAuthHeader  = "Authorization"
+	webSubSigHeader    = "X-Hub-Signature"
 	rootPlacholder     = "*root*"
 	notFoundPlacholder = "*404*"
 	internalErrorStr   = "500 Internal Server Error"
@@ -58,8 +59,20 @@ func acceptsJson(r *http.Request) bool {
 	return false
 }
 
+// Lets clients and proxies cache the response for the given time, overriding noCacheMW.
+func setCacheMaxAge(w http.ResponseWriter, seconds int) {
+	w.Header().Set(strCacheControlHdr, fmt.Sprintf("public, max-age=%d", seconds))
+	w.Header().Del("Pragma")
+	w.Header().Del("Expires")
+}
+
 // Returns the JSON serialized object as the response body; handles errors.
 func writeJsonResponse(logger shared.ILogger, w http.ResponseWriter, rt responseType, resp interface{}) {
+	writeJsonResponseWithStatus(logger, w, rt, http.StatusOK, resp)
+}
+
+// Like writeJsonResponse, but with a status code other than 200 OK.
+func writeJsonResponseWithStatus(logger shared.ILogger, w http.ResponseWriter, rt responseType, code int, resp interface{}) {
 	if rt == rtActivityJson {
 		w.Header().Set("Content-Type", "application/activity+json; charset=utf-8")
 	} else if rt == rtJrdJson {
@@ -74,6 +87,9 @@ func writeJsonResponse(logger shared.ILogger, w http.ResponseWriter, rt response
 		http.Error(w, internalErrorStr, http.StatusInternalServerError)
 		return
 	}
+	if code != http.StatusOK {
+		w.WriteHeader(code)
+	}
 	if _, err = fmt.Fprintln(w, string(respJson)); err != nil {
 		logger.Warnf("Failed to write response: %v\n", err)
 		http.Error(w, internalErrorStr, http.StatusInternalServerError)
@@ -14,6 +14,7 @@ import (
 )
 
 const assetsDir = "/assets"
+const mediaDir = "/media"
 const faviconName = "/favicon.ico"
 const chunkSize = 65536
 const strCacheControlHdr = "Cache-Control"
@@ -48,7 +49,7 @@ func trimSlashHandler(next http.Handler) http.Handler {
 	})
 }
 
-func NewMux(groups []IHandlerGroup, logger shared.ILogger) *mux.Router {
+func NewMux(groups []IHandlerGroup, cfg *shared.Config, logger shared.ILogger) *mux.Router {
 
 	var notFoundHandler func(w http.ResponseWriter, r *http.Request) = nil
 
@@ -72,7 +73,7 @@ func NewMux(groups []IHandlerGroup, logger shared.ILogger) *mux.Router {
 	// HEAD requests: 405
 	router.PathPrefix("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
-			handleStatic(logger, notFoundHandler, w, r)
+			handleStatic(cfg, logger, notFoundHandler, w, r)
 		} else if r.Method == "HEAD" {
 			logger.Infof("Rejecting HEAD: %s", r.URL.Path)
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -90,7 +91,7 @@ func noCacheMW(next http.Handler) http.Handler {
 	})
 }
 
-func handleStatic(logger shared.ILogger,
+func handleStatic(cfg *shared.Config, logger shared.ILogger,
 	notFoundHandler func(w http.ResponseWriter, r *http.Request),
 	w http.ResponseWriter, r *http.Request,
 ) {
@@ -106,8 +107,14 @@ func handleStatic(logger shared.ILogger,
 
 	w.Header().Set(strCacheControlHdr, "max-age=31536000, immutable")
 
-	// We serve everything from /assets folder, EXCEPT favicon.ico, which gets special treatment
-	if r.URL.Path != faviconName && !strings.HasPrefix(r.URL.Path, assetsDir) {
+	// We serve everything from /assets folder, EXCEPT favicon.ico, which gets special treatment,
+	// and cached images of feeds from /media, which are in the configured media folder
+	isMedia := strings.HasPrefix(r.URL.Path, mediaDir+"/") && cfg.MediaDir != ""
+	if r.URL.Path != faviconName && !strings.HasPrefix(r.URL.Path, assetsDir) && !isMedia {
+		return404()
+		return
+	}
+	if strings.Contains(r.URL.Path, "..") {
 		return404()
 		return
 	}
@@ -115,6 +122,8 @@ func handleStatic(logger shared.ILogger,
 	fn := filepath.Join(wwwPathPrefx, r.URL.Path)
 	if r.URL.Path == faviconName {
 		fn = filepath.Join(wwwPathPrefx, assetsDir, r.URL.Path)
+	} else if isMedia {
+		fn = filepath.Join(cfg.MediaDir, strings.TrimPrefix(r.URL.Path, mediaDir))
 	}
 	file, err := os.Open(fn)
 	if err != nil {
@@ -138,6 +147,8 @@ func handleStatic(logger shared.ILogger,
 			w.Header().Set("Content-Type", "image/svg+xml")
 		} else if strings.HasSuffix(r.URL.Path, ".css") {
 			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+		} else if strings.HasSuffix(r.URL.Path, ".webp") {
+			w.Header().Set("Content-Type", "image/webp")
 		}
 	}
 
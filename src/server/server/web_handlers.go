@@ -319,18 +319,20 @@ type oneFeedModel struct {
 	FeedUrlNoSchema string
 	FollowerCount   uint
 	PostCount       uint
+	CheckStatus     string
+	ShowHashtags    bool
+	Hashtag         string // Only posts with this hashtag are shown
 	Posts           []*dal.FeedPost
 	NotShownPosts   uint
 }
 
-func (hg *webHandlerGroup) loadFeedData(acct *dal.Account) *oneFeedModel {
+func (hg *webHandlerGroup) loadFeedData(acct *dal.Account, hashtag string) *oneFeedModel {
 
 	var err error
 
 	bio := hg.txt.WithVals("acct_bio.html", map[string]string{
-		"siteUrl":     hg.idb.SiteUrl(),
-		"description": acct.FeedSummary,
-	})
+		"siteUrl": hg.idb.SiteUrl(),
+	}) + logic.GetAccountDescription(acct)
 	var followerCount, postCount uint
 	if followerCount, err = hg.repo.GetFollowerCount(acct.Handle, true); err != nil {
 		hg.logger.Errorf("Error retrieving follower count for %s: %v", acct.Handle, err)
@@ -343,19 +345,34 @@ func (hg *webHandlerGroup) loadFeedData(acct *dal.Account) *oneFeedModel {
 
 	data := oneFeedModel{
 		Handle:        shared.MakeFullMoniker(hg.cfg.Host, acct.Handle),
-		Name:          shared.GetNameWithParrot(acct.FeedName),
+		Name:          shared.GetNameWithParrot(logic.GetAccountName(acct)),
 		Bio:           template.HTML(bio),
 		SiteUrl:       acct.SiteUrl,
 		FeedUrl:       acct.FeedUrl,
 		FollowerCount: followerCount,
 		PostCount:     postCount,
+		ShowHashtags:  !acct.NoHashtags,
+	}
+	if data.ShowHashtags {
+		data.Hashtag = hashtag
+	}
+	if acct.PollStatus == dal.PollGone {
+		data.CheckStatus = "Feed is gone; no longer checked"
+	} else if acct.PollStatus == dal.PollSuspended {
+		data.CheckStatus = fmt.Sprintf("Suspended after %d failed checks", acct.CheckFailures)
+	} else if acct.PollStatus == dal.PollBlocked {
+		data.CheckStatus = "Feed is blocked; no longer checked"
+	} else if acct.PollStatus == dal.PollOptedOut {
+		data.CheckStatus = "Publisher opted out; no longer checked"
+	} else if acct.CheckFailures > 0 {
+		data.CheckStatus = fmt.Sprintf("%d failed checks in a row", acct.CheckFailures)
 	}
 	data.FeedUrlNoSchema = strings.TrimPrefix(data.FeedUrl, "https://")
 	data.FeedUrlNoSchema = strings.TrimPrefix(data.FeedUrlNoSchema, "http://")
 	data.SiteUrlNoSchema = strings.TrimPrefix(data.SiteUrl, "https://")
 	data.SiteUrlNoSchema = strings.TrimPrefix(data.SiteUrlNoSchema, "http://")
 
-	data.Posts, err = hg.repo.GetPostsPage(acct.Id, 0, postsPerPage)
+	data.Posts, err = hg.repo.GetPostsPage(acct.Id, data.Hashtag, 0, postsPerPage)
 	if err != nil {
 		hg.logger.Errorf("Error retrieving posts for %s: %v", acct.Handle, err)
 		return nil
@@ -365,7 +382,7 @@ func (hg *webHandlerGroup) loadFeedData(acct *dal.Account) *oneFeedModel {
 		p.Description = shared.TruncateWithEllipsis(p.Description, shared.MaxDescriptionLen)
 	}
 
-	if data.PostCount > uint(len(data.Posts)) {
+	if data.Hashtag == "" && data.PostCount > uint(len(data.Posts)) {
 		data.NotShownPosts = data.PostCount - uint(len(data.Posts))
 	}
 
@@ -400,7 +417,7 @@ func (hg *webHandlerGroup) getOneFeed(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	data := hg.loadFeedData(acct)
+	data := hg.loadFeedData(acct, strings.TrimPrefix(r.URL.Query().Get("tag"), "#"))
 	if data == nil {
 		hg.send500(w, r)
 		return
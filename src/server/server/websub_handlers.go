@@ -0,0 +1,89 @@
+package server
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"strconv"
+)
+
+// Pushed feeds are about as big as the ones we poll; anything much larger isn't a feed
+const maxWebSubBodyBytes = 4 * 1024 * 1024
+
+type webSubHandlerGroup struct {
+	cfg    *shared.Config
+	logger shared.ILogger
+	fdfol  logic.IFeedFollower
+}
+
+func NewWebSubHandlerGroup(
+	cfg *shared.Config,
+	logger shared.ILogger,
+	fdfol logic.IFeedFollower,
+) IHandlerGroup {
+	res := webSubHandlerGroup{
+		cfg:    cfg,
+		logger: logger,
+		fdfol:  fdfol,
+	}
+	return &res
+}
+
+func (hg *webSubHandlerGroup) Prefix() string {
+	return "/websub"
+}
+
+func (hg *webSubHandlerGroup) GroupDefs() []handlerDef {
+	return []handlerDef{
+		{"GET", "/{account}", func(w http.ResponseWriter, r *http.Request) { hg.getIntent(w, r) }},
+		{"POST", "/{account}", func(w http.ResponseWriter, r *http.Request) { hg.postContent(w, r) }},
+	}
+}
+
+func (hg *webSubHandlerGroup) AuthMW() func(next http.Handler) http.Handler {
+	// Content deliveries are authenticated by their HMAC signature
+	return emptyMW
+}
+
+func (hg *webSubHandlerGroup) getIntent(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling WebSub verification: %s", r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	query := r.URL.Query()
+	mode := query.Get("hub.mode")
+	leaseSeconds, _ := strconv.Atoi(query.Get("hub.lease_seconds"))
+
+	confirmed, err := hg.fdfol.VerifyWebSubIntent(account, mode, query.Get("hub.topic"), leaseSeconds)
+	if err != nil {
+		hg.logger.Errorf("Failed to verify WebSub intent for %s: %v", account, err)
+		writeErrorResponse(w, internalErrorStr, http.StatusInternalServerError)
+		return
+	}
+	if !confirmed {
+		writeErrorResponse(w, notFoundStr, http.StatusNotFound)
+		return
+	}
+
+	// Hub confirms its own request by getting its challenge back verbatim
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(query.Get("hub.challenge")))
+}
+
+func (hg *webSubHandlerGroup) postContent(w http.ResponseWriter, r *http.Request) {
+	hg.logger.Infof("Handling WebSub content: %s", r.URL.Path)
+
+	account := mux.Vars(r)["account"]
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebSubBodyBytes)
+	body := readBody(hg.logger, w, r)
+	if body == nil {
+		return
+	}
+
+	// Hub only needs to know we received the content; problems processing it are ours
+	if err := hg.fdfol.HandleWebSubContent(account, body, r.Header.Get(webSubSigHeader)); err != nil {
+		hg.logger.Errorf("Failed to process WebSub content for %s: %v", account, err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -28,6 +28,20 @@ type Config struct {
 	PostsMinCountKept  int            `json:"posts_min_count_kept"`
 	PostsMinDaysKept   int            `json:"posts_min_days_kept"`
 	PurgeWaitSec       int            `json:"purge_wait_sec"`
+	FeedMaxFailures    int            `json:"feed_max_failures"`    // Suspend feed after this many failed checks in a row; 0 to never suspend
+	FeedCheckWorkers   int            `json:"feed_check_workers"`   // Number of feeds checked in parallel; defaults to 1
+	FeedChecksPerHost  int            `json:"feed_checks_per_host"` // Parallel checks of feeds on the same host; defaults to 1
+	FloodLimit         int            `json:"flood_limit"`          // Toot a single digest when a feed check finds more new posts than this; 0 for no limit; accounts may override it
+	PropagateDeletes   bool           `json:"propagate_deletes"`    // Delete posts removed from feeds, and send Delete to followers for deleted toots
+	DeliveryWorkers    int            `json:"delivery_workers"`     // Number of toot deliveries in parallel; defaults to 5
+	DeliveriesPerHost  int            `json:"deliveries_per_host"`  // Parallel deliveries to the same host; defaults to 2
+	InboxSuspendDays   int            `json:"inbox_suspend_days"`   // Stop delivering to inboxes that have been failing this long; 0 to never stop
+	HideFollowers      bool           `json:"hide_followers"`       // Only publish the number of followers, not who they are
+	TombstoneDays      int            `json:"tombstone_days"`       // Answer requests for deleted toots with a Tombstone this long, then with 404; defaults to 90
+	OptOutCheckHours   int            `json:"optout_check_hours"`   // Re-check robots.txt and sites of existing feeds for opt-out this often; defaults to 24; negative to never re-check
+	ImageRefreshHours  int            `json:"image_refresh_hours"`  // Look for feeds' profile and header images again this often; 0 to never refresh
+	MediaDir           string         `json:"media_dir"`            // Serve local copies of feeds' images from here; empty to link to the originals
+	MediaCacheMaxMB    int            `json:"media_cache_max_mb"`   // Stop storing new thumbnails while media takes up more than this; 0 for no limit
 	FallbackProfilePic string         `json:"fallback_profile_pic"`
 	Birb               *UserInfo      `json:"birb"`
 }
@@ -21,6 +21,15 @@ func GetHostName(userUrl string) (string, error) {
 	return parsedUrl.Hostname(), nil
 }
 
+// Host part of feed URL, used to limit parallel requests to the same server
+func GetFeedHost(feedUrl string) string {
+	u, err := url.Parse(feedUrl)
+	if err != nil {
+		return feedUrl
+	}
+	return strings.ToLower(u.Host)
+}
+
 func MakeFullMoniker(hostName, handle string) string {
 	return "@" + handle + "@" + hostName
 }
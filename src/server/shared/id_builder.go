@@ -2,6 +2,7 @@ package shared
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 )
 
@@ -27,6 +28,11 @@ func (idb *IdBuilder) UserProfile(user string) string {
 	return fmt.Sprintf("https://%s/web/feeds/%s", idb.Host, user)
 }
 
+// Feed's web page, showing only the posts with the hashtag
+func (idb *IdBuilder) UserHashtag(user, hashtag string) string {
+	return fmt.Sprintf("https://%s/web/feeds/%s?tag=%s", idb.Host, user, url.QueryEscape(hashtag))
+}
+
 func (idb *IdBuilder) UserUrl(user string) string {
 	return fmt.Sprintf("https://%s/u/%s", idb.Host, user)
 }
@@ -43,6 +49,10 @@ func (idb *IdBuilder) UserOutbox(user string) string {
 	return fmt.Sprintf("https://%s/u/%s/outbox", idb.Host, user)
 }
 
+func (idb *IdBuilder) UserOutboxPage(user string, page int) string {
+	return fmt.Sprintf("https://%s/u/%s/outbox?page=%d", idb.Host, user, page)
+}
+
 func (idb *IdBuilder) UserFollowing(user string) string {
 	return fmt.Sprintf("https://%s/u/%s/following", idb.Host, user)
 }
@@ -51,6 +61,14 @@ func (idb *IdBuilder) UserFollowers(user string) string {
 	return fmt.Sprintf("https://%s/u/%s/followers", idb.Host, user)
 }
 
+func (idb *IdBuilder) UserFollowersPage(user string, page int) string {
+	return fmt.Sprintf("https://%s/u/%s/followers?page=%d", idb.Host, user, page)
+}
+
+func (idb *IdBuilder) UserFollowersSync(user string) string {
+	return fmt.Sprintf("https://%s/u/%s/followers_synchronization", idb.Host, user)
+}
+
 func (idb *IdBuilder) UserStatus(user string, id uint64) string {
 	idStr := strconv.FormatUint(id, 10)
 	return fmt.Sprintf("https://%s/u/%s/status/%s", idb.Host, user, idStr)
@@ -60,3 +78,11 @@ func (idb *IdBuilder) UserStatusActivity(user string, id uint64) string {
 	idStr := strconv.FormatUint(id, 10)
 	return fmt.Sprintf("https://%s/u/%s/status/%s/activity", idb.Host, user, idStr)
 }
+
+func (idb *IdBuilder) MediaUrl(user, fileName string) string {
+	return fmt.Sprintf("https://%s/media/%s/%s", idb.Host, user, fileName)
+}
+
+func (idb *IdBuilder) WebSubCallback(user string) string {
+	return fmt.Sprintf("https://%s/websub/%s", idb.Host, user)
+}
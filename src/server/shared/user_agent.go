@@ -11,9 +11,12 @@ import (
 
 const (
 	versionFileName   = "www/version.txt"
-	userAgentTemplate = "RSS-Parrot-Bot/%s (+https://%s)"
+	userAgentTemplate = UserAgentProduct + "/%s (+https://%s)"
 )
 
+// Product token in our User-Agent header; this is what publishers name in robots.txt
+const UserAgentProduct = "RSS-Parrot-Bot"
+
 type IUserAgent interface {
 	AddUserAgent(req *http.Request)
 }
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/server"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"strings"
+	"testing"
+)
+
+const testApiKey = "test-api-key"
+
+type apiHandlersHarness struct {
+	mockRepo *mocks.MockIRepo
+	mockUdir *mocks.MockIUserDirectory
+	router   *mux.Router
+}
+
+func newApiHandlersHarness(ctrl *gomock.Controller) *apiHandlersHarness {
+
+	cfg := &shared.Config{Host: "parrot.com", Birb: &shared.UserInfo{User: "birb"}}
+	cfg.Secrets.ApiKeys = []string{testApiKey}
+	mockLogger := mocks.NewMockILogger(ctrl)
+	setupDummyLogger(mockLogger)
+	h := &apiHandlersHarness{
+		mockRepo: mocks.NewMockIRepo(ctrl),
+		mockUdir: mocks.NewMockIUserDirectory(ctrl),
+	}
+	hg := server.NewApiHandlerGroup(cfg, mockLogger, mocks.NewMockIFeedFollower(ctrl),
+		mocks.NewMockIDomainBlocks(ctrl), mocks.NewMockIBlockedFeeds(ctrl), h.mockUdir,
+		mocks.NewMockIPublisherClaims(ctrl), mocks.NewMockIMediaCache(ctrl), h.mockRepo)
+	h.router = server.NewMux([]server.IHandlerGroup{hg}, cfg, mockLogger)
+	return h
+}
+
+func (h *apiHandlersHarness) call(method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-API-KEY", testApiKey)
+	rr := httptest.NewRecorder()
+	h.router.ServeHTTP(rr, req)
+	return rr
+}
+
+// One follower the birb has approved, and one waiting for approval
+func setupBirbFollowers(h *apiHandlersHarness) {
+	followers := []*dal.FollowerInfo{
+		{
+			RequestId:     "https://approved.social/follows/1",
+			ApproveStatus: 1,
+			UserUrl:       "https://approved.social/users/friend",
+			Handle:        "friend",
+			Host:          "approved.social",
+			UserInbox:     "https://approved.social/users/friend/inbox",
+		},
+		{
+			RequestId:     "https://pending.social/follows/2",
+			ApproveStatus: 0,
+			UserUrl:       "https://pending.social/users/stranger",
+			Handle:        "stranger",
+			Host:          "pending.social",
+			UserInbox:     "https://pending.social/users/stranger/inbox",
+		},
+	}
+	h.mockRepo.EXPECT().GetFollowersByUser(gomock.Eq("birb"), gomock.Eq(false)).Return(followers, nil).AnyTimes()
+}
+
+func Test_Api_Pending_Birb_Followers_Leave_Out_Approved(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newApiHandlersHarness(ctrl)
+	setupBirbFollowers(h)
+
+	rr := h.call("GET", "/api/birb/followers/pending", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var res []dto.Follower
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	assert.Equal(t, []dto.Follower{
+		{UserUrl: "https://pending.social/users/stranger", Handle: "stranger", Host: "pending.social"},
+	}, res)
+}
+
+func Test_Api_Reject_Birb_Follower_Only_If_Pending(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newApiHandlersHarness(ctrl)
+	setupBirbFollowers(h)
+
+	// Approved follower is not up for a decision
+	h.mockUdir.EXPECT().RejectFollower(gomock.Any(), gomock.Eq("https://approved.social/users/friend"),
+		gomock.Any(), gomock.Any()).Times(0)
+	h.mockRepo.EXPECT().RemoveFollower(gomock.Any(), gomock.Eq("https://approved.social/users/friend")).Times(0)
+	rr := h.call("POST", "/api/birb/followers/reject", `{"user_url": "https://approved.social/users/friend"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	h.mockUdir.EXPECT().RejectFollower(gomock.Eq("https://pending.social/follows/2"),
+		gomock.Eq("https://pending.social/users/stranger"), gomock.Eq("https://pending.social/users/stranger/inbox"),
+		gomock.Eq("birb")).Return(nil).Times(1)
+	h.mockRepo.EXPECT().RemoveFollower(gomock.Eq("birb"), gomock.Eq("https://pending.social/users/stranger")).
+		Return(nil).Times(1)
+	rr = h.call("POST", "/api/birb/followers/reject", `{"user_url": "https://pending.social/users/stranger"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func Test_Api_Approve_Birb_Follower_Only_If_Pending(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newApiHandlersHarness(ctrl)
+	setupBirbFollowers(h)
+
+	rr := h.call("POST", "/api/birb/followers/approve", `{"user_url": "https://approved.social/users/friend"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	h.mockUdir.EXPECT().AcceptFollower(gomock.Eq("https://pending.social/follows/2"),
+		gomock.Eq("https://pending.social/users/stranger"), gomock.Eq("https://pending.social/users/stranger/inbox"),
+		gomock.Eq("birb")).Return(nil).Times(1)
+	rr = h.call("POST", "/api/birb/followers/approve", `{"user_url": "https://pending.social/users/stranger"}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
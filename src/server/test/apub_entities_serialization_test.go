@@ -47,5 +47,26 @@ func Test_Deserialize_Note(t *testing.T) {
 	assert.Equal(t, "Mention", (*note.Tag)[0].Type)
 }
 
+func Test_Deserialize_User_Also_Known_As(t *testing.T) {
+	var ui dto.UserInfo
+
+	// Usually an array
+	err := json.Unmarshal([]byte(`{"id": "https://two.com/users/a", "alsoKnownAs": ["https://one.com/users/a"]}`), &ui)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://one.com/users/a"}, ui.GetAlsoKnownAs())
+
+	// Also valid as a single string
+	ui = dto.UserInfo{}
+	err = json.Unmarshal([]byte(`{"id": "https://two.com/users/a", "alsoKnownAs": "https://one.com/users/a"}`), &ui)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://one.com/users/a"}, ui.GetAlsoKnownAs())
+
+	// Missing
+	ui = dto.UserInfo{}
+	err = json.Unmarshal([]byte(`{"id": "https://two.com/users/a"}`), &ui)
+	assert.Nil(t, err)
+	assert.Empty(t, ui.GetAlsoKnownAs())
+}
+
 //func Test_Foo(t *testing.T) {
 //}
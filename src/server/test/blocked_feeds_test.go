@@ -0,0 +1,142 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"os"
+	"path/filepath"
+	"rss_parrot/dal"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"testing"
+)
+
+type blockedFeedsHarness struct {
+	cfg           *shared.Config
+	mockRepo      *mocks.MockIRepo
+	mockMessenger *mocks.MockIMessenger
+	mockMedia     *mocks.MockIMediaCache
+}
+
+// Existing accounts are checked against the file on startup, so they must be known before we create the block list
+func setupBlockedFeedsTest(t *testing.T, fileContent string, accts []*dal.Account) (*gomock.Controller, *blockedFeedsHarness, logic.IBlockedFeeds) {
+
+	ctrl := gomock.NewController(t)
+	mockLogger := mocks.NewMockILogger(ctrl)
+	setupDummyLogger(mockLogger)
+	h := &blockedFeedsHarness{
+		cfg: &shared.Config{
+			BlockedFeedsFile: filepath.Join(t.TempDir(), "blocked-feeds.txt"),
+			Birb:             &shared.UserInfo{User: "birb"},
+		},
+		mockRepo:      mocks.NewMockIRepo(ctrl),
+		mockMessenger: mocks.NewMockIMessenger(ctrl),
+		mockMedia:     mocks.NewMockIMediaCache(ctrl),
+	}
+	if fileContent != "" {
+		assert.Nil(t, os.WriteFile(h.cfg.BlockedFeedsFile, []byte(fileContent), 0644))
+	}
+	h.mockRepo.EXPECT().GetAccountsPage(gomock.Any(), gomock.Any()).Return(accts, len(accts), nil).AnyTimes()
+	return ctrl, h, logic.NewBlockedFeeds(h.cfg, mockLogger, h.mockRepo, h.mockMessenger, h.mockMedia)
+}
+
+func Test_Blocked_Feeds_Patterns(t *testing.T) {
+
+	content := `# Blocked feeds
+spam.com # link farm
+*.splog.net
+Example.com/private/ # asked to be left out
+/^feeds\.feedburner\.com/bad-\d+$/ # feedburner #spam
+/[unclosed/
+`
+	ctrl, _, bf := setupBlockedFeedsTest(t, content, nil)
+	defer ctrl.Finish()
+
+	assertBlocked := func(feedUrl string, expectedBlocked bool, expectedReason string) {
+		blocked, reason := bf.IsBlocked(feedUrl)
+		assert.Equal(t, expectedBlocked, blocked, feedUrl)
+		assert.Equal(t, expectedReason, reason, feedUrl)
+	}
+
+	// Domain-wide, with or without www
+	assertBlocked("https://spam.com/feed.xml", true, "link farm")
+	assertBlocked("http://www.SPAM.com:8080/", true, "link farm")
+	assertBlocked("https://blog.spam.com/feed.xml", false, "")
+	assertBlocked("https://notspam.com/feed.xml", false, "")
+	// Wildcard covers the domain and its subdomains
+	assertBlocked("https://splog.net/rss", true, "")
+	assertBlocked("https://a.b.splog.net/rss", true, "")
+	assertBlocked("https://notsplog.net/rss", false, "")
+	// Path prefix ends on a boundary
+	assertBlocked("https://example.com/private", true, "asked to be left out")
+	assertBlocked("https://www.example.com/private/feed.xml", true, "asked to be left out")
+	assertBlocked("https://example.com/privateer/feed.xml", false, "")
+	assertBlocked("https://example.com/feed.xml", false, "")
+	// Regex is matched against the URL without the scheme
+	assertBlocked("https://feeds.feedburner.com/bad-12", true, "feedburner #spam")
+	assertBlocked("https://feeds.feedburner.com/bad-12/x", false, "")
+
+	// Invalid line is skipped, but the rest is loaded
+	patterns := []string{}
+	for _, entry := range bf.GetAll() {
+		patterns = append(patterns, entry.Pattern)
+	}
+	assert.Equal(t, []string{"spam.com", "*.splog.net", "Example.com/private/", `/^feeds\.feedburner\.com/bad-\d+$/`}, patterns)
+}
+
+func Test_Blocked_Feeds_Block_Stops_And_Purges_Existing_Accounts(t *testing.T) {
+
+	accts := []*dal.Account{
+		{Id: 1, Handle: "blog.example.com", FeedUrl: "https://blog.example.com/feed", SiteUrl: "https://blog.example.com"},
+		{Id: 2, Handle: "other.com", FeedUrl: "https://other.com/feed", SiteUrl: "https://other.com"},
+		{Id: 3, Handle: "feeds.feedburner.com-x", FeedUrl: "https://feeds.feedburner.com/x", SiteUrl: "https://www.example.com/"},
+	}
+	ctrl, h, bf := setupBlockedFeedsTest(t, "# Blocked feeds\nspam.com\n", accts)
+	defer ctrl.Finish()
+	h.cfg.PropagateDeletes = true
+
+	h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(1), gomock.Eq(dal.PollBlocked)).Return(nil).Times(1)
+	h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(3), gomock.Eq(dal.PollBlocked)).Return(nil).Times(1)
+	h.mockRepo.EXPECT().PurgePostsAndToots(gomock.Eq(1), gomock.Any()).Return([]string{"status-1"}, nil).Times(1)
+	h.mockRepo.EXPECT().PurgePostsAndToots(gomock.Eq(3), gomock.Any()).Return([]string{}, nil).Times(1)
+	h.mockMessenger.EXPECT().EnqueueDelete(gomock.Eq("blog.example.com"), gomock.Eq("status-1")).Return(nil).Times(1)
+	h.mockMedia.EXPECT().PruneThumbnails(gomock.Eq("blog.example.com")).Return(nil).Times(1)
+	h.mockMedia.EXPECT().PruneThumbnails(gomock.Eq("feeds.feedburner.com-x")).Return(nil).Times(1)
+
+	// Matches by feed URL and by site URL
+	isNew, stopped, err := bf.Block("*.example.com", "spam", true)
+	assert.Nil(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, []string{"blog.example.com", "feeds.feedburner.com-x"}, stopped)
+	blocked, reason := bf.IsBlocked("https://example.com/feed")
+	assert.True(t, blocked)
+	assert.Equal(t, "spam", reason)
+
+	content, _ := os.ReadFile(h.cfg.BlockedFeedsFile)
+	assert.Equal(t, "# Blocked feeds\nspam.com\n*.example.com # spam\n", string(content))
+
+	// Unblocking keeps everything else in the file
+	removed, err := bf.Unblock("spam.com")
+	assert.Nil(t, err)
+	assert.True(t, removed)
+	removed, err = bf.Unblock("spam.com")
+	assert.Nil(t, err)
+	assert.False(t, removed)
+	blocked, _ = bf.IsBlocked("https://spam.com/feed")
+	assert.False(t, blocked)
+	content, _ = os.ReadFile(h.cfg.BlockedFeedsFile)
+	assert.Equal(t, "# Blocked feeds\n*.example.com # spam\n", string(content))
+}
+
+func Test_Blocked_Feeds_Invalid_Pattern_Rejected(t *testing.T) {
+
+	ctrl, _, bf := setupBlockedFeedsTest(t, "", nil)
+	defer ctrl.Finish()
+
+	_, _, err := bf.Block("/(oops/", "", false)
+	assert.NotNil(t, err)
+	_, _, err = bf.Block("*.example.com/path", "", false)
+	assert.NotNil(t, err)
+	assert.Empty(t, bf.GetAll())
+}
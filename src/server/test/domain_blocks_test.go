@@ -0,0 +1,58 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"rss_parrot/logic"
+	"rss_parrot/test/mocks"
+	"testing"
+)
+
+func setupDomainBlocksTest(t *testing.T, blocked ...string) (*gomock.Controller, *mocks.MockIRepo, logic.IDomainBlocks) {
+
+	ctrl := gomock.NewController(t)
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockRepo := mocks.NewMockIRepo(ctrl)
+	mockMetrics := mocks.NewMockIMetrics(ctrl)
+	setupDummyLogger(mockLogger)
+	setupDummyMetrics(mockMetrics)
+
+	var blocks []*dal.BlockedDomain
+	for _, domain := range blocked {
+		blocks = append(blocks, &dal.BlockedDomain{Domain: domain})
+	}
+	mockRepo.EXPECT().GetBlockedDomains().Return(blocks, nil).Times(1)
+	return ctrl, mockRepo, logic.NewDomainBlocks(mockLogger, mockRepo, mockMetrics)
+}
+
+func Test_Domain_Blocks_Cover_Subdomains(t *testing.T) {
+
+	ctrl, _, db := setupDomainBlocksTest(t, "bad.example")
+	defer ctrl.Finish()
+
+	assert.True(t, db.IsBlocked("https://bad.example/users/troll"))
+	assert.True(t, db.IsBlocked("https://Social.Bad.Example/users/troll"))
+	assert.False(t, db.IsBlocked("https://notbad.example/users/friend"))
+	assert.False(t, db.IsBlocked("https://example/users/friend"))
+	assert.False(t, db.IsBlocked("not a url"))
+}
+
+func Test_Domain_Block_Removes_Followers(t *testing.T) {
+
+	ctrl, mockRepo, db := setupDomainBlocksTest(t)
+	defer ctrl.Finish()
+
+	mockRepo.EXPECT().AddBlockedDomain(gomock.Cond(func(x any) bool {
+		bd := x.(*dal.BlockedDomain)
+		return bd.Domain == "bad.example" && bd.Comment == "spam"
+	})).Return(true, nil).Times(1)
+	mockRepo.EXPECT().RemoveFollowersOnDomain(gomock.Eq("bad.example")).Return(2, nil).Times(1)
+	mockRepo.EXPECT().GetFeedFollowerCount().Return(10, nil).Times(1)
+
+	isNew, removed, err := db.Block("https://Bad.Example/", "spam")
+	assert.Nil(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, 2, removed)
+	assert.True(t, db.IsBlocked("https://bad.example/users/troll"))
+}
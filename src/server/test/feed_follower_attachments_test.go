@@ -0,0 +1,235 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/logic"
+	"rss_parrot/test/mocks"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const attachmentsFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+  <title>Podcast with pictures</title>
+  <link>%SITE%/</link>
+  <item>
+    <title>Episode with cover</title>
+    <link>%SITE%/episode</link>
+    <guid>%SITE%/episode</guid>
+    <pubDate>%DATE%</pubDate>
+    <media:content url="%SITE%/cover.jpg" medium="image" type="image/jpeg">
+      <media:description>Cover &lt;b&gt;art&lt;/b&gt;</media:description>
+    </media:content>
+    <media:thumbnail url="%SITE%/cover-small.jpg"/>
+    <enclosure url="%SITE%/episode.mp3" length="1234" type="audio/mpeg"/>
+  </item>
+  <item>
+    <title>Post with picture</title>
+    <link>%SITE%/post</link>
+    <guid>%SITE%/post</guid>
+    <pubDate>%DATE%</pubDate>
+    <description>&lt;p&gt;Look:&lt;/p&gt;&lt;img src="/img/first.png" alt="First picture"&gt;&lt;img src="/img/second.png"&gt;</description>
+  </item>
+  <item>
+    <title>Plain post</title>
+    <link>%SITE%/article</link>
+    <guid>%SITE%/article</guid>
+    <pubDate>%DATE%</pubDate>
+    <description>Nothing to see</description>
+  </item>
+</channel>
+</rss>`
+
+const attachmentsArticleHtml = `<!DOCTYPE html>
+<html><head><title>Article</title>
+<meta property="og:image" content="/og.png">
+<meta property="og:image:alt" content="Article picture">
+</head><body></body></html>`
+
+func Test_Feed_Follower_Toots_Get_Attachments(t *testing.T) {
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	pubDate := time.Now().Add(-time.Minute).UTC().Format(time.RFC1123)
+	feedXml := strings.ReplaceAll(attachmentsFeedXml, "%SITE%", srv.URL)
+	feedXml = strings.ReplaceAll(feedXml, "%DATE%", pubDate)
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feedXml))
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(attachmentsArticleHtml))
+	})
+
+	lastUpdated := time.Now().Add(-time.Hour).UTC()
+	acct := dal.Account{
+		Id:              81,
+		Handle:          "pictures.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	setupFakeTexts(h.mockTexts)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Any()).Return(nil, nil).Times(1)
+	h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).Return(true, nil).Times(3)
+	h.mockMetrics.EXPECT().NewPostSaved().Times(3)
+	h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).Times(3)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(3)
+	stored := make(map[string][]*dal.TootAttachment)
+	sent := make(map[string][]*dal.TootAttachment)
+	getLink := func(content string) string {
+		for _, path := range []string{"/episode", "/post", "/article"} {
+			// Link is followed by the next value, the summary, or nothing
+			urlVal := "url\t" + srv.URL + path
+			if strings.Contains(content, urlVal+"\n") || strings.Contains(content, urlVal+"<") ||
+				strings.HasSuffix(content, urlVal) {
+				return path
+			}
+		}
+		return ""
+	}
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).DoAndReturn(func(_ int, toot *dal.Toot) error {
+		mu.Lock()
+		defer mu.Unlock()
+		stored[getLink(toot.Content)] = toot.Attachments
+		return nil
+	}).Times(3)
+	h.mockMessenger.EXPECT().
+		EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _ time.Time, content string, attachments []*dal.TootAttachment, _ []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			sent[getLink(content)] = attachments
+			wg.Done()
+			return nil
+		}).Times(3)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, stored, sent)
+
+	// Media RSS image wins over the thumbnail; the audio enclosure comes after the images
+	assert.Equal(t, []*dal.TootAttachment{
+		{Type: "Image", MediaType: "image/jpeg", Url: srv.URL + "/cover.jpg", Name: "Cover art"},
+		{Type: "Audio", MediaType: "audio/mpeg", Url: srv.URL + "/episode.mp3", Name: "Episode with cover"},
+	}, sent["/episode"])
+	// Only the first image in the description, resolved against the item's link
+	assert.Equal(t, []*dal.TootAttachment{
+		{Type: "Image", Url: srv.URL + "/img/first.png", Name: "First picture"},
+	}, sent["/post"])
+	// Nothing in the item: the article's og:image
+	assert.Equal(t, []*dal.TootAttachment{
+		{Type: "Image", Url: srv.URL + "/og.png", Name: "Article picture"},
+	}, sent["/article"])
+}
+
+func Test_Note_Attachments_Serialized(t *testing.T) {
+
+	note := dto.Note{
+		Id:      "https://parrot.com/u/x/status/1",
+		Type:    "Note",
+		Content: "<p>Hello</p>",
+		Attachment: []dto.Document{
+			{Type: "Image", MediaType: "image/png", Url: "https://parrot.com/media/x/a-thumb.png", Name: "Alt"},
+		},
+	}
+	data, err := json.Marshal(&note)
+	assert.Nil(t, err)
+	var obj map[string]any
+	assert.Nil(t, json.Unmarshal(data, &obj))
+	assert.Equal(t, []any{map[string]any{
+		"type":      "Image",
+		"mediaType": "image/png",
+		"url":       "https://parrot.com/media/x/a-thumb.png",
+		"name":      "Alt",
+	}}, obj["attachment"])
+
+	// No attachments: no property at all
+	note.Attachment = nil
+	data, err = json.Marshal(&note)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(data), `"attachment"`), fmt.Sprintf("unexpected attachment: %s", data))
+
+	// Attachments of notes we receive are accepted in any shape
+	var received dto.Note
+	err = json.Unmarshal([]byte(`{"id":"x","type":"Note","to":[],"cc":[],
+		"attachment":{"type":"Document","url":"https://elsewhere.com/a.png"}}`), &received)
+	assert.Nil(t, err)
+}
+
+func Test_Feed_Follower_New_Account_Toots_Link_To_Original_Images(t *testing.T) {
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	pubDate := time.Now().Add(-time.Minute).UTC().Format(time.RFC1123)
+	feedXml := strings.ReplaceAll(attachmentsFeedXml, "%SITE%", srv.URL)
+	feedXml = strings.ReplaceAll(feedXml, "%DATE%", pubDate)
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feedXml))
+	})
+	articleFetched := false
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		articleFetched = true
+		_, _ = w.Write([]byte(attachmentsArticleHtml))
+	})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	// Toots for a new account's existing posts are not sent: we don't store their images
+	h.mockMediaCache = mocks.NewMockIMediaCache(ctrl)
+	h.mockMediaCache.EXPECT().Store(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	h.mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, 0, nil).AnyTimes()
+	h.mockMetrics.EXPECT().FeedRequested(gomock.Any()).AnyTimes()
+	h.mockBlockedFeeds.EXPECT().IsBlocked(gomock.Any()).Return(false, "").AnyTimes()
+	h.mockKeyStore.EXPECT().MakeKeyPair().Return("pub", "priv", nil).Times(1)
+	setupFakeTexts(h.mockTexts)
+
+	acct := dal.Account{Id: 82, Handle: "new-pictures.site.com", FeedUrl: srv.URL + "/feed"}
+	h.mockRepo.EXPECT().AddAccountIfNotExist(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	h.mockRepo.EXPECT().GetAccount(gomock.Any()).Return(&acct, nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(time.Time{}, nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Any()).Return(nil, nil).Times(1)
+	h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).Return(true, nil).Times(3)
+	h.mockMetrics.EXPECT().NewPostSaved().Times(3)
+	h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).Times(3)
+	var stored [][]*dal.TootAttachment
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).DoAndReturn(func(_ int, toot *dal.Toot) error {
+		stored = append(stored, toot.Attachments)
+		return nil
+	}).Times(3)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	ff := startFeedFollower(h)
+
+	_, status, err := ff.GetAccountForFeed(srv.URL + "/feed")
+	assert.Nil(t, err)
+	assert.Equal(t, logic.FsNew, int(status))
+	assert.False(t, articleFetched)
+	assert.Contains(t, stored, []*dal.TootAttachment{
+		{Type: "Image", MediaType: "image/jpeg", Url: srv.URL + "/cover.jpg", Name: "Cover art"},
+		{Type: "Audio", MediaType: "audio/mpeg", Url: srv.URL + "/episode.mp3", Name: "Episode with cover"},
+	})
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Feed_Follower_Edited_Post_Sends_Update(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pollingFeedXml))
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              61,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+	toot := dal.Toot{
+		TootedAt: time.Now().Add(-24 * time.Hour),
+		StatusId: "https://parrot.com/u/polled.site.com/status/1234",
+	}
+	newContent := "<p>Old post, now edited</p>"
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	// Old post is not new, but its content no longer matches what we stored
+	h.mockRepo.EXPECT().
+		UpdateFeedPostIfChanged(gomock.Eq(acct.Id), gomock.Cond(func(x any) bool {
+			post := x.(*dal.FeedPost)
+			return post.Title == "Old post" && post.ContentHash != 0
+		})).
+		Return(true, nil).Times(1)
+	setupPolledAccounts(h, &acct)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		Return(nil).Times(1)
+	h.mockMetrics.EXPECT().PostUpdated().Times(1)
+	h.mockTexts.EXPECT().WithVals(gomock.Eq("toot_new_post.html"), gomock.Any()).Return(newContent).Times(1)
+	h.mockRepo.EXPECT().UpdateTootContent(gomock.Eq(acct.Id), gomock.Any(), gomock.Eq(newContent), gomock.Any()).
+		Return(&toot, nil).Times(1)
+	h.mockMessenger.EXPECT().
+		EnqueueUpdate(gomock.Eq(acct.Handle), gomock.Eq(toot.StatusId), gomock.Eq(toot.TootedAt),
+			gomock.Any(), gomock.Eq(newContent), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _, _ time.Time, _ string, _ []*dal.TootAttachment, _ []string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Removed_Post_Sends_Delete(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pollingFeedXml))
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              62,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+	// We have a post newer than the oldest item in the feed, but the author has since taken it down
+	removed := dal.FeedPost{PostGuidHash: 4242, Link: "https://polled.site.com/taken-down"}
+	removedStatusId := "https://parrot.com/u/polled.site.com/status/4242"
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.PropagateDeletes = true
+	// Post is only deleted once it's been missing from two polls in a row
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return([]*dal.Account{&acct}, 1, nil).Times(1)
+	setupPolledAccounts(h, &acct)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(2)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, _ time.Time) error {
+			wg.Done()
+			return nil
+		}).Times(2)
+	h.mockRepo.EXPECT().
+		GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Eq(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))).
+		Return([]*dal.FeedPost{&removed}, nil).Times(2)
+	h.mockRepo.EXPECT().DeletePostAndToot(gomock.Eq(acct.Id), gomock.Eq(removed.PostGuidHash)).
+		Return(removedStatusId, nil).Times(1)
+	h.mockMessenger.EXPECT().EnqueueDelete(gomock.Eq(acct.Handle), gomock.Eq(removedStatusId)).
+		Return(nil).Times(1)
+	h.mockMediaCache.EXPECT().PruneThumbnails(gomock.Eq(acct.Handle)).Return(nil).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Returns a feed with count items, all published within the last hour
+func makeFloodFeedXml(count int) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel>
+  <title>Flooded feed</title><link>https://flooded.site.com</link>`)
+	for i := 0; i < count; i++ {
+		pubDate := time.Now().Add(-time.Duration(i+1) * time.Minute).UTC().Format(time.RFC1123)
+		sb.WriteString(fmt.Sprintf(`<item><title>Post %d</title><link>https://flooded.site.com/post-%d</link>
+  <guid>https://flooded.site.com/post-%d</guid><pubDate>%s</pubDate></item>`, i, i, i, pubDate))
+	}
+	sb.WriteString(`</channel></rss>`)
+	return sb.String()
+}
+
+func setupFloodTest(t *testing.T, ctrl *gomock.Controller, acctId, floodLimit, postCount int) (*feedFollowerHarness, *dal.Account) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(makeFloodFeedXml(postCount)))
+	}))
+	t.Cleanup(srv.Close)
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := &dal.Account{
+		Id:              acctId,
+		Handle:          "flooded.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FloodLimit = floodLimit
+	setupPolledAccounts(h, acct)
+	setupFakeTexts(h.mockTexts)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Eq(time.Time{})).Return(nil, nil).Times(1)
+	// Every post is stored, flood or not
+	h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).Return(true, nil).Times(postCount)
+	h.mockMetrics.EXPECT().NewPostSaved().Times(postCount)
+	h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).AnyTimes()
+	return h, acct
+}
+
+func Test_Feed_Follower_Flood_Sends_Single_Digest(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h, acct := setupFloodTest(t, ctrl, 71, 2, 3)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var tootContent, sentContent string
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).DoAndReturn(func(_ int, toot *dal.Toot) error {
+		tootContent = toot.Content
+		return nil
+	}).Times(1)
+	h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Nil(),
+		gomock.Nil()).
+		DoAndReturn(func(_, _ string, _ time.Time, content string, _ []*dal.TootAttachment, _ []string) error {
+			sentContent = content
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.Equal(t, tootContent, sentContent)
+	assert.True(t, strings.HasPrefix(sentContent, fakeTextWithVals("toot_digest.html", map[string]string{"count": "3"})))
+	// Newest post comes first
+	first := strings.Index(sentContent, "https://flooded.site.com/post-0")
+	last := strings.Index(sentContent, "https://flooded.site.com/post-2")
+	assert.True(t, first >= 0 && last > first)
+}
+
+func Test_Feed_Follower_No_Digest_Within_Flood_Limit(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h, acct := setupFloodTest(t, ctrl, 72, 2, 2)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(2)
+	h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(),
+		gomock.Cond(checkStartsWith("toot_new_post.html")), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _ time.Time, _ string, _ []*dal.TootAttachment, _ []string) error {
+			wg.Done()
+			return nil
+		}).Times(2)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Account_Flood_Limit_Overrides_Config(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Account with a lower limit gets a digest where the configured limit would toot each post
+	h, acct := setupFloodTest(t, ctrl, 73, 5, 2)
+	acct.FloodLimit = 1
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(1)
+	h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(),
+		gomock.Cond(checkStartsWith("toot_digest.html")), gomock.Nil(), gomock.Nil()).
+		DoAndReturn(func(_, _ string, _ time.Time, _ string, _ []*dal.TootAttachment, _ []string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+
+	// Account without a limit toots each post even above the configured limit
+	h, acct = setupFloodTest(t, ctrl, 74, 2, 3)
+	acct.FloodLimit = -1
+	wg.Add(3)
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(3)
+	h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(),
+		gomock.Cond(checkStartsWith("toot_new_post.html")), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _ time.Time, _ string, _ []*dal.TootAttachment, _ []string) error {
+			wg.Done()
+			return nil
+		}).Times(3)
+	startFeedFollower(h)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const hashtagsFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Tagged feed</title>
+  <link>https://tagged.site.com/</link>
+  <item>
+    <title>Tagged post</title>
+    <link>https://tagged.site.com/post</link>
+    <guid>https://tagged.site.com/post</guid>
+    <pubDate>%DATE%</pubDate>
+    <category>open source</category>
+    <category>Open-Source</category>
+    <category>2024</category>
+    <category>#Go</category>
+    <category>a category that is much too long to be a hashtag</category>
+    <category>Don't panic</category>
+    <category>&lt;b&gt;C++&lt;/b&gt;</category>
+    <category>podcast, tech</category>
+  </item>
+</channel>
+</rss>`
+
+var expectedHashtags = []string{"OpenSource", "Go", "DontPanic", "C", "podcast"}
+
+func setupHashtagsTest(t *testing.T, ctrl *gomock.Controller, acct *dal.Account) (*feedFollowerHarness, *dal.FeedPost) {
+
+	pubDate := time.Now().Add(-time.Minute).UTC().Format(time.RFC1123)
+	feedXml := strings.ReplaceAll(hashtagsFeedXml, "%DATE%", pubDate)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feedXml))
+	}))
+	t.Cleanup(srv.Close)
+	acct.FeedUrl = srv.URL + "/feed"
+	acct.FeedLastUpdated = time.Now().Add(-time.Hour).UTC()
+
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, acct)
+	setupFakeTexts(h.mockTexts)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(acct.FeedLastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Any()).Return(nil, nil).Times(1)
+	h.mockMetrics.EXPECT().NewPostSaved().Times(1)
+	h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).Times(1)
+
+	var post dal.FeedPost
+	h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).
+		DoAndReturn(func(_ int, fp *dal.FeedPost) (bool, error) {
+			post = *fp
+			return true, nil
+		}).Times(1)
+	return h, &post
+}
+
+func Test_Feed_Follower_Toot_Gets_Hashtags(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	acct := &dal.Account{Id: 91, Handle: "tagged.site.com"}
+	h, post := setupHashtagsTest(t, ctrl, acct)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var toot dal.Toot
+	var sentHashtags []string
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).DoAndReturn(func(_ int, t *dal.Toot) error {
+		toot = *t
+		return nil
+	}).Times(1)
+	h.mockMessenger.EXPECT().
+		EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _ time.Time, _ string, _ []*dal.TootAttachment, hashtags []string) error {
+			sentHashtags = hashtags
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.Equal(t, expectedHashtags, post.Hashtags)
+	assert.Equal(t, expectedHashtags, toot.Hashtags)
+	assert.Equal(t, expectedHashtags, sentHashtags)
+	// Hashtag links follow the post in the toot
+	assert.True(t, strings.HasPrefix(toot.Content, "toot_new_post.html"))
+	assert.True(t, strings.HasSuffix(toot.Content, "</p>"))
+	assert.Equal(t, 5, strings.Count(toot.Content, "toot_hashtag.html"))
+	assert.Contains(t, toot.Content, "\nurl\thttps://"+h.cfg.Host+"/web/feeds/tagged.site.com?tag=OpenSource")
+}
+
+func Test_Feed_Follower_Hashtags_Turned_Off(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	acct := &dal.Account{Id: 92, Handle: "tagged.site.com", NoHashtags: true}
+	h, post := setupHashtagsTest(t, ctrl, acct)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var toot dal.Toot
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).DoAndReturn(func(_ int, t *dal.Toot) error {
+		toot = *t
+		return nil
+	}).Times(1)
+	h.mockMessenger.EXPECT().
+		EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Nil()).
+		DoAndReturn(func(_, _ string, _ time.Time, _ string, _ []*dal.TootAttachment, _ []string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	// Post still knows its hashtags; the toot doesn't get them
+	assert.Equal(t, expectedHashtags, post.Hashtags)
+	assert.Nil(t, toot.Hashtags)
+	assert.False(t, strings.Contains(toot.Content, "toot_hashtag.html"))
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const imagesSiteHtml = `<!DOCTYPE html>
+<html><head><title>Site with images</title>
+<link rel="alternate" type="application/rss+xml" href="/feed">
+<link rel="icon" href="/favicon-16.png" sizes="16x16">
+<link rel="icon" href="/favicon-64.png" sizes="64x64">
+<meta property="og:image" content="/og-tall.png">
+<meta name="twitter:image" content="http://%HOST%/twitter.png">
+</head><body></body></html>`
+
+const imagesAtomXml = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom feed</title>
+  <link href="%SITE%"/>
+  <id>urn:uuid:60a76c80-d399-11d9-b93C-0003939e0af6</id>
+  <updated>2024-01-01T10:00:00Z</updated>
+  <icon>/icon.png</icon>
+  <logo>/logo.png</logo>
+</feed>`
+
+func makePng(width, height int) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return buf.Bytes()
+}
+
+// Serves PNG images of the given sizes at their paths
+func serveImages(mux *http.ServeMux, sizes map[string][2]int) {
+	for path, size := range sizes {
+		data := makePng(size[0], size[1])
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(data)
+		})
+	}
+}
+
+func Test_Feed_Follower_Images_Discovered_For_New_Feed(t *testing.T) {
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(imagesSiteHtml, "%HOST%", host, 1)))
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pollingFeedXml))
+	})
+	serveImages(mux, map[string][2]int{
+		"/favicon-16.png": {16, 16},   // Too small
+		"/favicon-64.png": {64, 64},   // Largest icon
+		"/og-tall.png":    {400, 800}, // Not wide
+		"/twitter.png":    {1200, 630},
+	})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, 0, nil).AnyTimes()
+	h.mockMetrics.EXPECT().FeedRequested(gomock.Any()).AnyTimes()
+	h.mockBlockedFeeds.EXPECT().IsBlocked(gomock.Any()).Return(false, "").AnyTimes()
+	h.mockKeyStore.EXPECT().MakeKeyPair().Return("pub", "priv", nil).Times(1)
+
+	var added *dal.Account
+	h.mockRepo.EXPECT().AddAccountIfNotExist(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(acct *dal.Account, _ string) (bool, error) {
+			added = acct
+			// We only care about what would be stored
+			return false, errors.New("stop here")
+		}).Times(1)
+	ff := startFeedFollower(h)
+
+	_, _, err := ff.GetAccountForFeed(srv.URL + "/blog/")
+	assert.NotNil(t, err)
+	assert.NotNil(t, added)
+	assert.Equal(t, srv.URL+"/favicon-64.png", added.ProfileImageUrl)
+	assert.Equal(t, srv.URL+"/twitter.png", added.HeaderImageUrl)
+	assert.False(t, added.ImagesCheckedAt.IsZero())
+}
+
+func Test_Feed_Follower_Images_Refreshed(t *testing.T) {
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	// Site page is down; Atom feed's icon becomes the profile picture; logo is square, so not a header
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(imagesAtomXml, "%SITE%", srv.URL+"/blog/", 1)))
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	serveImages(mux, map[string][2]int{
+		"/icon.png": {128, 128},
+		"/logo.png": {128, 128},
+	})
+
+	lastUpdated := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	acct := dal.Account{
+		Id:              32,
+		Handle:          "polled.site.com",
+		SiteUrl:         srv.URL + "/blog/",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+		ProfileImageUrl: "https://old.site.com/old-icon.png",
+		HeaderImageUrl:  "https://old.site.com/old-header.png",
+		ImagesCheckedAt: time.Now().Add(-25 * time.Hour),
+	}
+
+	var wg sync.WaitGroup
+	// Images are refreshed alongside the rest of the check
+	wg.Add(2)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.ImageRefreshHours = 24
+	setupPolledAccounts(h, &acct)
+	h.mockRepo.EXPECT().SetAccountImages(gomock.Eq(acct.Id), gomock.Eq(srv.URL+"/icon.png"),
+		gomock.Eq("https://old.site.com/old-header.png"), gomock.Any()).
+		DoAndReturn(func(_ int, _, _ string, _ time.Time) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, _ time.Time) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
@@ -0,0 +1,211 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/logic"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const optOutFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:fediverse="https://example.com/ns">
+<channel>
+  <title>Polled feed</title>
+  <link>https://polled.site.com</link>
+  <description>Feed whose publisher opted out</description>
+  <fediverse:parrot>noparrot</fediverse:parrot>
+  <item>
+    <title>Old post</title>
+    <link>https://polled.site.com/old-post</link>
+    <guid>https://polled.site.com/old-post</guid>
+    <pubDate>Mon, 01 Jan 2024 10:00:00 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+const optOutSiteHtml = `<!DOCTYPE html>
+<html><head><title>Site</title>%META%</head><body></body></html>`
+
+type optOutSite struct {
+	robotsTxt     string
+	siteMeta      string
+	feedXml       string
+	feedFetched   bool
+	robotsFetches int
+}
+
+func (oos *optOutSite) serve(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("User-Agent"), "RSS-Parrot-Bot/"))
+		oos.robotsFetches++
+		if oos.robotsTxt == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(oos.robotsTxt))
+	})
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(optOutSiteHtml, "%META%", oos.siteMeta, 1)))
+	})
+	mux.HandleFunc("/blog/feed", func(w http.ResponseWriter, r *http.Request) {
+		oos.feedFetched = true
+		_, _ = w.Write([]byte(strings.Replace(oos.feedXml, "%SITE%", "http://"+r.Host+"/blog/", 1)))
+	})
+	return httptest.NewServer(mux)
+}
+
+// Must come before setupPolledAccounts, whose expectation would otherwise take precedence
+func setupOptOutUserAgent(h *feedFollowerHarness) {
+	h.mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).
+		Do(func(req *http.Request) { req.Header.Set("User-Agent", "RSS-Parrot-Bot/1.0") }).AnyTimes()
+}
+
+// Checks an existing account once, with opt-out re-checks enabled and due
+func test_Feed_Follower_Opt_Out(t *testing.T, oos *optOutSite, expectOptOut bool) {
+
+	srv := oos.serve(t)
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              31,
+		Handle:          "polled.site.com",
+		SiteUrl:         srv.URL + "/blog/",
+		FeedUrl:         srv.URL + "/blog/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.OptOutCheckHours = 24
+	setupOptOutUserAgent(h)
+	setupPolledAccounts(h, &acct)
+	h.mockRepo.EXPECT().SetAccountOptOutChecked(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(1)
+
+	if expectOptOut {
+		h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("opted-out")).Times(1)
+		h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(acct.Id), gomock.Eq(dal.PollOptedOut)).
+			DoAndReturn(func(_ int, _ dal.PollStatus) error {
+				wg.Done()
+				return nil
+			}).Times(1)
+	} else {
+		h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+		h.mockRepo.EXPECT().
+			UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+			DoAndReturn(func(_ int, _, _ time.Time) error {
+				wg.Done()
+				return nil
+			}).Times(1)
+	}
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Opt_Out_None(t *testing.T) {
+	oos := &optOutSite{feedXml: pollingFeedXml}
+	test_Feed_Follower_Opt_Out(t, oos, false)
+	assert.True(t, oos.feedFetched)
+}
+
+func Test_Feed_Follower_Opt_Out_Robots_Disallows_Us(t *testing.T) {
+	oos := &optOutSite{
+		feedXml:   pollingFeedXml,
+		robotsTxt: "User-agent: Googlebot\nDisallow: /\n\nUser-agent: rss-parrot-bot\nDisallow: /blog/feed$\n",
+	}
+	test_Feed_Follower_Opt_Out(t, oos, true)
+	// We don't fetch what robots.txt tells us not to
+	assert.False(t, oos.feedFetched)
+}
+
+func Test_Feed_Follower_Opt_Out_Robots_Disallows_All(t *testing.T) {
+	oos := &optOutSite{
+		feedXml:   pollingFeedXml,
+		robotsTxt: "User-agent: *\nDisallow: /blog\n",
+	}
+	test_Feed_Follower_Opt_Out(t, oos, true)
+}
+
+func Test_Feed_Follower_Opt_Out_Robots_Allows_Us_Specifically(t *testing.T) {
+	oos := &optOutSite{
+		feedXml: pollingFeedXml,
+		// Our own group overrides *; more specific Allow wins over Disallow
+		robotsTxt: "User-agent: *\nDisallow: /\n\nUser-agent: RSS-Parrot-Bot\nDisallow: /blog/\nAllow: /blog/*feed\nAllow: /blog/$\n",
+	}
+	test_Feed_Follower_Opt_Out(t, oos, false)
+}
+
+func Test_Feed_Follower_Opt_Out_Site_Meta(t *testing.T) {
+	oos := &optOutSite{
+		feedXml:  pollingFeedXml,
+		siteMeta: `<meta name="fediverse:parrot" content="noindex, noparrot">`,
+	}
+	test_Feed_Follower_Opt_Out(t, oos, true)
+}
+
+func Test_Feed_Follower_Opt_Out_Feed_Element(t *testing.T) {
+	oos := &optOutSite{feedXml: optOutFeedXml}
+	test_Feed_Follower_Opt_Out(t, oos, true)
+	assert.True(t, oos.feedFetched)
+}
+
+func Test_Feed_Follower_Opt_Out_New_Feed_Refused(t *testing.T) {
+
+	oos := &optOutSite{
+		feedXml:  pollingFeedXml,
+		siteMeta: `<meta name="fediverse:parrot" content="noparrot">`,
+	}
+	srv := oos.serve(t)
+	defer srv.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupOptOutUserAgent(h)
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, 0, nil).AnyTimes()
+	h.mockMetrics.EXPECT().FeedRequested(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().AddAccountIfNotExist(gomock.Any(), gomock.Any()).Times(0)
+	ff := startFeedFollower(h)
+
+	acct, status, _ := ff.GetAccountForFeed(srv.URL + "/blog/")
+	assert.Nil(t, acct)
+	assert.Equal(t, logic.FsOptOut, int(status))
+}
+
+func Test_Feed_Follower_Opt_Out_Robots_Fetched_Once_Per_Host(t *testing.T) {
+
+	oos := &optOutSite{
+		robotsTxt: "User-agent: *\nDisallow: /private/\n",
+		feedXml:   strings.Replace(pollingFeedXml, "https://polled.site.com</link>", "%SITE%</link>", 1),
+		siteMeta:  `<meta name="fediverse:parrot" content="noparrot">`,
+	}
+	srv := oos.serve(t)
+	defer srv.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupOptOutUserAgent(h)
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, 0, nil).AnyTimes()
+	h.mockMetrics.EXPECT().FeedRequested(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().AddAccountIfNotExist(gomock.Any(), gomock.Any()).Times(0)
+	ff := startFeedFollower(h)
+
+	// Feed and the site it links to are on the same host
+	acct, status, _ := ff.GetAccountForFeed(srv.URL + "/blog/feed")
+	assert.Nil(t, acct)
+	assert.Equal(t, logic.FsOptOut, int(status))
+	assert.True(t, oos.feedFetched)
+	assert.Equal(t, 1, oos.robotsFetches)
+}
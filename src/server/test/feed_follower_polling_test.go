@@ -0,0 +1,355 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"sync"
+	"testing"
+	"time"
+)
+
+const pollingFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Polled feed</title>
+  <link>https://polled.site.com</link>
+  <description>Feed used in polling tests</description>
+  <item>
+    <title>Old post</title>
+    <link>https://polled.site.com/old-post</link>
+    <guid>https://polled.site.com/old-post</guid>
+    <pubDate>Mon, 01 Jan 2024 10:00:00 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+// Sets up feed follower so that its check loop claims accts exactly once, in a single batch
+func setupPolledAccounts(h *feedFollowerHarness, accts ...*dal.Account) {
+	h.cfg.UpdateSchedule = shared.UpdateSchedule{Day: 1, Week: 3, Weeks4: 6, Older: 12}
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(accts, len(accts), nil).Times(1)
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, 0, nil).AnyTimes()
+	for _, acct := range accts {
+		h.mockRepo.EXPECT().GetFollowerCount(gomock.Eq(acct.Handle), gomock.Any()).Return(uint(1), nil).AnyTimes()
+	}
+	h.mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	h.mockMetrics.EXPECT().FeedUpdated().AnyTimes()
+	h.mockRepo.EXPECT().UpdateFeedPostIfChanged(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+}
+
+func Test_Feed_Follower_Conditional_Get_Not_Modified(t *testing.T) {
+
+	etag := `"v1-abc"`
+	lastModified := "Mon, 01 Jan 2024 10:00:00 GMT"
+	var gotIfNoneMatch, gotIfModifiedSince string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		gotIfModifiedSince = r.Header.Get("If-Modified-Since")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:               21,
+		Handle:           "polled.site.com",
+		FeedUrl:          srv.URL + "/feed",
+		FeedLastUpdated:  lastUpdated,
+		FeedEtag:         etag,
+		FeedLastModified: lastModified,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedNotModified().Times(1)
+	// 304: feed is rescheduled, last updated time is unchanged, and no posts are touched
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, nextCheckDue time.Time) error {
+			defer wg.Done()
+			assert.True(t, nextCheckDue.After(time.Now()))
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.Equal(t, etag, gotIfNoneMatch)
+	assert.Equal(t, lastModified, gotIfModifiedSince)
+}
+
+func Test_Feed_Follower_Conditional_Get_Stores_Validators(t *testing.T) {
+
+	newEtag := `"v2-def"`
+	newLastModified := "Tue, 02 Jan 2024 10:00:00 GMT"
+	var gotIfNoneMatch string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfNoneMatch = r.Header.Get("If-None-Match")
+		w.Header().Set("ETag", newEtag)
+		w.Header().Set("Last-Modified", newLastModified)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write([]byte(pollingFeedXml))
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              22,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	// Feed's only post is older than last update: nothing is stored
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		Return(nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedValidators(gomock.Eq(acct.Id), gomock.Eq(newEtag), gomock.Eq(newLastModified)).
+		DoAndReturn(func(_ int, _, _ string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.Equal(t, "", gotIfNoneMatch)
+}
+
+func Test_Feed_Follower_Permanent_Redirect_Updates_Feed_Url(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old-feed", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new-feed", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new-feed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pollingFeedXml))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              23,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/old-feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("moved")).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedUrl(gomock.Eq(acct.Id), gomock.Eq(srv.URL+"/new-feed")).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, _ time.Time) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Gone_Stops_Polling(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	acct := dal.Account{
+		Id:              24,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: time.Now().Add(-3 * time.Hour).UTC(),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("gone")).Times(1)
+	h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(acct.Id), gomock.Eq(dal.PollGone)).
+		DoAndReturn(func(_ int, _ dal.PollStatus) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Retry_After_Pushes_Next_Check(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "172800")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              25,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("throttled")).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, nextCheckDue time.Time) error {
+			defer wg.Done()
+			// Retry-After of 2 days is longer than our regular schedule
+			assert.True(t, nextCheckDue.After(time.Now().Add(47*time.Hour)))
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Repeated_Failure_Backs_Off(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              26,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+		CheckFailures:   3,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FeedMaxFailures = 10
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("failed")).Times(1)
+	h.mockRepo.EXPECT().SetAccountCheckFailures(gomock.Eq(acct.Id), gomock.Eq(4), gomock.Any()).
+		DoAndReturn(func(_ int, _ int, lastError string) error {
+			assert.Contains(t, lastError, "500")
+			return nil
+		}).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, nextCheckDue time.Time) error {
+			defer wg.Done()
+			// Regular interval is 1 hour +-20%; fourth failure in a row waits 8 times as long
+			assert.True(t, nextCheckDue.After(time.Now().Add(6*time.Hour)))
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Too_Many_Failures_Suspends(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	acct := dal.Account{
+		Id:              27,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: time.Now().Add(-3 * time.Hour).UTC(),
+		CheckFailures:   4,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FeedMaxFailures = 5
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("failed")).Times(1)
+	h.mockMetrics.EXPECT().FeedCheckProblem(gomock.Eq("suspended")).Times(1)
+	h.mockRepo.EXPECT().SetAccountCheckFailures(gomock.Eq(acct.Id), gomock.Eq(5), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(acct.Id), gomock.Eq(dal.PollSuspended)).
+		DoAndReturn(func(_ int, _ dal.PollStatus) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Success_Resets_Failures(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              28,
+		Handle:          "polled.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+		CheckFailures:   2,
+		LastCheckError:  "timeout",
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	h.mockMetrics.EXPECT().FeedNotModified().Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		Return(nil).Times(1)
+	h.mockRepo.EXPECT().SetAccountCheckFailures(gomock.Eq(acct.Id), gomock.Eq(0), gomock.Eq("")).
+		DoAndReturn(func(_ int, _ int, _ string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
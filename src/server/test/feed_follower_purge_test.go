@@ -26,14 +26,23 @@ type feedFollowerHarness struct {
 	mockTexts        *mocks.MockITexts
 	mockKeyStore     *mocks.MockIKeyStore
 	mockMetrics      *mocks.MockIMetrics
+	mockMediaCache   *mocks.MockIMediaCache
 }
 
 func setupFeedFollowerTest(t *testing.T) (*gomock.Controller, *feedFollowerHarness, logic.IFeedFollower) {
 
 	ctrl := gomock.NewController(t)
+	h := newFeedFollowerHarness(ctrl)
+	ff := startFeedFollower(h)
+	return ctrl, h, ff
+}
+
+// Creates the mocks and shared dummies, but not the feed follower itself.
+// Feed follower starts its check loop right away, so any expectations about the loop must be set up before.
+func newFeedFollowerHarness(ctrl *gomock.Controller) *feedFollowerHarness {
 
 	h := &feedFollowerHarness{
-		cfg:              &shared.Config{},
+		cfg:              &shared.Config{OptOutCheckHours: -1}, // Tests of opt-out re-checks turn them on
 		mockLogger:       mocks.NewMockILogger(ctrl),
 		mockUserAgent:    mocks.NewMockIUserAgent(ctrl),
 		mockRepo:         mocks.NewMockIRepo(ctrl),
@@ -42,16 +51,20 @@ func setupFeedFollowerTest(t *testing.T) (*gomock.Controller, *feedFollowerHarne
 		mockTexts:        mocks.NewMockITexts(ctrl),
 		mockKeyStore:     mocks.NewMockIKeyStore(ctrl),
 		mockMetrics:      mocks.NewMockIMetrics(ctrl),
+		mockMediaCache:   mocks.NewMockIMediaCache(ctrl),
 	}
 	setupDummyLogger(h.mockLogger)
 	setupDummyMetrics(h.mockMetrics)
 
 	h.mockRepo.EXPECT().GetTotalPostCount().Return(uint(0), nil).AnyTimes()
+	h.mockMediaCache.EXPECT().Store(gomock.Any(), gomock.Any(), gomock.Any()).Return("", nil).AnyTimes()
 
-	ff := logic.NewFeedFollower(h.cfg, h.mockLogger, h.mockUserAgent, h.mockRepo,
-		h.mockBlockedFeeds, h.mockMessenger, h.mockTexts, h.mockKeyStore, h.mockMetrics)
+	return h
+}
 
-	return ctrl, h, ff
+func startFeedFollower(h *feedFollowerHarness) logic.IFeedFollower {
+	return logic.NewFeedFollower(h.cfg, h.mockLogger, h.mockUserAgent, h.mockRepo,
+		h.mockBlockedFeeds, h.mockMessenger, h.mockTexts, h.mockKeyStore, h.mockMetrics, h.mockMediaCache)
 }
 
 func extractsToToots(postExtracts []tootExtract) []*dal.Toot {
@@ -74,7 +87,8 @@ func test_Feed_Follower_Purge_Old_Posts(t *testing.T,
 	defer ctrl.Finish()
 
 	// No accounts to check: this will keep feed follower's update check loop quiet
-	h.mockRepo.EXPECT().GetAccountToCheck(gomock.Any()).Return(nil, 0, nil).AnyTimes()
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, 0, nil).AnyTimes()
 
 	acct := dal.Account{
 		Id:     17,
@@ -85,7 +99,8 @@ func test_Feed_Follower_Purge_Old_Posts(t *testing.T,
 	if fromBefore != nil {
 		h.mockRepo.EXPECT().
 			PurgePostsAndToots(gomock.Eq(acct.Id), gomock.Eq(*fromBefore)).
-			Return(nil).Times(1)
+			Return(nil, nil).Times(1)
+		h.mockMediaCache.EXPECT().PruneThumbnails(gomock.Eq(acct.Handle)).Return(nil).Times(1)
 	}
 
 	// Purge items beyond minCount that are older than 2 days
@@ -107,3 +122,40 @@ func Test_Feed_Follower_Purge_Old_Posts_Scenarios(t *testing.T) {
 	test_Feed_Follower_Purge_Old_Posts(t, tootExtracts, &tootExtracts[3].postTime, 3)
 	test_Feed_Follower_Purge_Old_Posts(t, tootExtracts, &tootExtracts[3].postTime, 2)
 }
+
+func Test_Feed_Follower_Purge_Old_Posts_Sends_Deletes(t *testing.T) {
+
+	ctrl, h, ff := setupFeedFollowerTest(t)
+	defer ctrl.Finish()
+	h.cfg.PropagateDeletes = true
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, 0, nil).AnyTimes()
+
+	acct := dal.Account{
+		Id:     18,
+		Handle: "some.site.com.feed",
+	}
+	now := time.Now().UTC()
+	tootExtracts := []tootExtract{
+		{now.Add(-1 * time.Hour), int64(getNextId())},
+		{now.Add(-72 * time.Hour), int64(getNextId())},
+		{now.Add(-96 * time.Hour), int64(getNextId())},
+	}
+	purgedIds := []string{
+		"https://parrot.com/u/some.site.com.feed/status/1",
+		"https://parrot.com/u/some.site.com.feed/status/2",
+	}
+
+	h.mockRepo.EXPECT().GetTootExtracts(gomock.Eq(acct.Id)).Return(extractsToToots(tootExtracts), nil).Times(1)
+	h.mockRepo.EXPECT().
+		PurgePostsAndToots(gomock.Eq(acct.Id), gomock.Eq(tootExtracts[1].postTime)).
+		Return(purgedIds, nil).Times(1)
+	h.mockMediaCache.EXPECT().PruneThumbnails(gomock.Eq(acct.Handle)).Return(nil).Times(1)
+	// Followers learn about each purged toot
+	for _, statusId := range purgedIds {
+		h.mockMessenger.EXPECT().EnqueueDelete(gomock.Eq(acct.Handle), gomock.Eq(statusId)).Return(nil).Times(1)
+	}
+
+	err := ff.PurgeOldPosts(&acct, 1, 2)
+	assert.Nil(t, err)
+}
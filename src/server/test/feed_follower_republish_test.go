@@ -0,0 +1,119 @@
+package test
+
+import (
+	"fmt"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Feed with a single recent item; its date has just been bumped
+func makeRepublishFeedXml(title, link, guid string) string {
+	pubDate := time.Now().Add(-time.Minute).UTC().Format(time.RFC1123)
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel>
+  <title>Republishing feed</title><link>https://rebuilt.site.com</link>
+  <item><title>%s</title><link>%s</link><guid>%s</guid><pubDate>%s</pubDate></item>
+</channel></rss>`, title, link, guid, pubDate)
+}
+
+func test_Feed_Follower_Republished_Post(t *testing.T, acctId int, feedXml string, stored *dal.FeedPost, isRepublished bool) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feedXml))
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              acctId,
+		Handle:          "rebuilt.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	setupFakeTexts(h.mockTexts)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Eq(time.Time{})).
+		Return([]*dal.FeedPost{stored}, nil).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ int, _, _ time.Time) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+
+	if isRepublished {
+		// Stored post and its toot move to the new hash; nothing is tooted
+		h.mockRepo.EXPECT().
+			RekeyFeedPost(gomock.Eq(acct.Id), gomock.Eq(stored.PostGuidHash), gomock.Not(stored.PostGuidHash), gomock.Any()).
+			Return(nil).Times(1)
+		h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Any(), gomock.Any()).Times(0)
+		h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any()).Times(0)
+	} else {
+		h.mockRepo.EXPECT().RekeyFeedPost(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+		h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).Return(true, nil).Times(1)
+		h.mockMetrics.EXPECT().NewPostSaved().Times(1)
+		h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).Times(1)
+		h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(1)
+		h.mockMessenger.EXPECT().EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(),
+			gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	}
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Republished_Post_Scenarios(t *testing.T) {
+
+	stored := &dal.FeedPost{
+		PostGuidHash: 9001,
+		Link:         "http://www.rebuilt.site.com/2023/hiking-in-the-hills/",
+		Title:        "Hiking in the hills above the city",
+		Guid:         "post-17",
+	}
+
+	// Same GUID, link moved to a new domain
+	feedXml := makeRepublishFeedXml("Hiking in the hills above the city",
+		"https://rebuilt.example.com/hiking", "post-17")
+	test_Feed_Follower_Republished_Post(t, 81, feedXml, stored, true)
+
+	// New GUID, same link apart from scheme, www, trailing slash and tracking parameters
+	feedXml = makeRepublishFeedXml("Hiking in the hills above the city",
+		"https://rebuilt.site.com/2023/hiking-in-the-hills?utm_source=rss&amp;utm_medium=feed", "new-guid-17")
+	test_Feed_Follower_Republished_Post(t, 82, feedXml, stored, true)
+
+	// Same GUID and same title, new link
+	feedXml = makeRepublishFeedXml("Hiking in the Hills Above the City!",
+		"https://rebuilt.site.com/posts/17", "post-17")
+	test_Feed_Follower_Republished_Post(t, 83, feedXml, stored, true)
+
+	// New GUID and new link: the same title alone is not enough
+	feedXml = makeRepublishFeedXml("Hiking in the Hills Above the City!",
+		"https://rebuilt.site.com/posts/17", "new-guid-17")
+	test_Feed_Follower_Republished_Post(t, 86, feedXml, stored, false)
+
+	// Genuinely new post
+	feedXml = makeRepublishFeedXml("Cycling along the river",
+		"https://rebuilt.site.com/2024/cycling-along-the-river/", "post-18")
+	test_Feed_Follower_Republished_Post(t, 84, feedXml, stored, false)
+
+	// Short titles are too generic to match on their own
+	shortStored := &dal.FeedPost{PostGuidHash: 9002, Link: "https://rebuilt.site.com/weekly/1", Title: "Weekly links"}
+	feedXml = makeRepublishFeedXml("Weekly links", "https://rebuilt.site.com/weekly/2", "weekly-2")
+	test_Feed_Follower_Republished_Post(t, 85, feedXml, shortStored, false)
+
+	// Every episode links to the show's page: the same link alone is not enough
+	episodeStored := &dal.FeedPost{PostGuidHash: 9003, Link: "https://show.site.com/", Title: "Episode 1", Guid: "ep-1"}
+	feedXml = makeRepublishFeedXml("Episode 2", "https://show.site.com/", "ep-2")
+	test_Feed_Follower_Republished_Post(t, 87, feedXml, episodeStored, false)
+}
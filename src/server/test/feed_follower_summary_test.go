@@ -0,0 +1,124 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const summaryFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0">
+<channel>
+  <title>Summaries</title>
+  <link>https://summary.site.com/</link>
+  <item>
+    <title>Rich post</title>
+    <link>https://summary.site.com/posts/rich</link>
+    <guid>https://summary.site.com/posts/rich</guid>
+    <pubDate>%DATE%</pubDate>
+    <description><![CDATA[<h2>Intro</h2><p>Some <b>bold</b> and <i>italic</i> text
+      with <a href="../about" class="x">a link</a>, <a href="javascript:alert(1)">a bad link</a>
+      and <a href="/pic"><img src="/pic.png"></a>.</p><script>alert(2)</script>
+      <div><p>Things:</p><ul><li>one</li><li>two</li></ul></div>]]></description>
+  </item>
+  <item>
+    <title>Long post</title>
+    <link>https://summary.site.com/posts/long</link>
+    <guid>https://summary.site.com/posts/long</guid>
+    <pubDate>%DATE%</pubDate>
+    <description><![CDATA[<p>Short first paragraph.</p><ul><li><em>%LONG%</em></li><li>never shown</li></ul><p>Nor this</p>]]></description>
+  </item>
+  <item>
+    <title>Plain post</title>
+    <link>https://summary.site.com/posts/plain</link>
+    <guid>https://summary.site.com/posts/plain</guid>
+    <pubDate>%DATE%</pubDate>
+    <description>Fish &amp; chips &lt;3</description>
+  </item>
+</channel>
+</rss>`
+
+func Test_Feed_Follower_Toots_Get_Html_Summaries(t *testing.T) {
+
+	pubDate := time.Now().Add(-time.Minute).UTC().Format(time.RFC1123)
+	feedXml := strings.ReplaceAll(summaryFeedXml, "%DATE%", pubDate)
+	feedXml = strings.ReplaceAll(feedXml, "%LONG%", strings.Repeat("blah ", 100))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(feedXml))
+	}))
+	defer srv.Close()
+
+	lastUpdated := time.Now().Add(-time.Hour).UTC()
+	acct := dal.Account{
+		Id:              95,
+		Handle:          "summary.site.com",
+		FeedUrl:         srv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	setupPolledAccounts(h, &acct)
+	setupFakeTexts(h.mockTexts)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetFeedPostsSince(gomock.Eq(acct.Id), gomock.Any()).Return(nil, nil).Times(1)
+	h.mockMetrics.EXPECT().NewPostSaved().Times(3)
+	h.mockRepo.EXPECT().GetNextId().DoAndReturn(getNextId).Times(3)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(3)
+	descriptions := make(map[string]string)
+	h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Eq(acct.Id), gomock.Any()).
+		DoAndReturn(func(_ int, fp *dal.FeedPost) (bool, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			descriptions[fp.Title] = fp.Description
+			return true, nil
+		}).Times(3)
+	contents := make(map[string]string)
+	h.mockRepo.EXPECT().AddToot(gomock.Eq(acct.Id), gomock.Any()).Return(nil).Times(3)
+	h.mockMessenger.EXPECT().
+		EnqueueBroadcast(gomock.Eq(acct.Handle), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ string, _ time.Time, content string, _ []*dal.TootAttachment, _ []string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			// Summary follows the snippet with the title and the link
+			ix := strings.Index(content, "<p>")
+			for _, title := range []string{"Rich post", "Long post", "Plain post"} {
+				if strings.Contains(content[:ix], "\ntitle\t"+title) {
+					contents[title] = content[ix:]
+				}
+			}
+			wg.Done()
+			return nil
+		}).Times(3)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Markup Mastodon keeps survives; headings become paragraphs; relative links are resolved; unsafe bits are gone
+	assert.Equal(t, `<p>Intro</p><p>Some <strong>bold</strong> and <em>italic</em> text `+
+		`with <a href="https://summary.site.com/about">a link</a>, a bad link and .</p>`+
+		`<p>Things:</p><ul><li>one</li><li>two</li></ul>`, contents["Rich post"])
+	// Cut inside the list item, at a word, with every element closed
+	long := contents["Long post"]
+	assert.True(t, strings.HasPrefix(long, "<p>Short first paragraph.</p><ul><li><em>blah blah "))
+	assert.True(t, strings.HasSuffix(long, " blah…</em></li></ul>"))
+	assert.False(t, strings.Contains(long, "never shown"))
+	assert.LessOrEqual(t, len(long)-len("<p></p><ul><li><em></em></li></ul>"), 256+len("…"))
+	// Plain text is escaped and goes in a single paragraph
+	assert.Equal(t, "<p>Fish &amp; chips &lt;3</p>", contents["Plain post"])
+	// Stored posts keep their plain text descriptions
+	assert.Equal(t, "Fish & chips <3", descriptions["Plain post"])
+}
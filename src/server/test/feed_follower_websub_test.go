@@ -0,0 +1,216 @@
+package test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"sync"
+	"testing"
+	"time"
+)
+
+const webSubFeedXml = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title>Pushed feed</title>
+  <link>https://pushed.site.com</link>
+  <description>Feed used in WebSub tests</description>
+  <atom:link rel="hub" href="%s"/>
+  <atom:link rel="self" href="https://pushed.site.com/feed"/>
+  <item>
+    <title>Old post</title>
+    <link>https://pushed.site.com/old-post</link>
+    <guid>https://pushed.site.com/old-post</guid>
+    <pubDate>Mon, 01 Jan 2024 10:00:00 GMT</pubDate>
+  </item>
+</channel>
+</rss>`
+
+func signWebSubBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Feed follower whose check loop never finds anything to check
+func setupIdleFeedFollowerHarness(ctrl *gomock.Controller) *feedFollowerHarness {
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.UpdateSchedule = shared.UpdateSchedule{Day: 1, Week: 3, Weeks4: 6, Older: 12}
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, 0, nil).AnyTimes()
+	h.mockRepo.EXPECT().UpdateFeedPostIfChanged(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	return h
+}
+
+func Test_Feed_Follower_WebSub_Subscribes_To_Hub(t *testing.T) {
+
+	var gotForm url.Values
+	var wg sync.WaitGroup
+	wg.Add(1)
+	hubSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		gotForm = r.PostForm
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer hubSrv.Close()
+	feedSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(fmt.Sprintf(webSubFeedXml, hubSrv.URL)))
+	}))
+	defer feedSrv.Close()
+
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              51,
+		Handle:          "pushed.site.com",
+		FeedUrl:         feedSrv.URL + "/feed",
+		FeedLastUpdated: lastUpdated,
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.Host = "parrot.com"
+	setupPolledAccounts(h, &acct)
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		Return(nil).Times(1)
+	var storedSecret string
+	h.mockRepo.EXPECT().
+		SetAccountWebSub(gomock.Eq(acct.Id), gomock.Eq(hubSrv.URL), gomock.Eq("https://pushed.site.com/feed"),
+			gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ int, _, _, secret string, _ time.Time) error {
+			storedSecret = secret
+			return nil
+		}).Times(1)
+	h.mockMetrics.EXPECT().WebSubEvent(gomock.Eq("requested")).Do(func(_ string) { wg.Done() }).Times(1)
+	startFeedFollower(h)
+
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.Equal(t, "subscribe", gotForm.Get("hub.mode"))
+	assert.Equal(t, "https://pushed.site.com/feed", gotForm.Get("hub.topic"))
+	assert.Equal(t, "https://parrot.com/websub/pushed.site.com", gotForm.Get("hub.callback"))
+	assert.NotEmpty(t, gotForm.Get("hub.secret"))
+	assert.Equal(t, storedSecret, gotForm.Get("hub.secret"))
+}
+
+func Test_Feed_Follower_WebSub_Verify_Intent(t *testing.T) {
+
+	acct := dal.Account{
+		Id:          52,
+		Handle:      "pushed.site.com",
+		WebSubHub:   "https://hub.com",
+		WebSubTopic: "https://pushed.site.com/feed",
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := setupIdleFeedFollowerHarness(ctrl)
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(acct.Handle)).Return(&acct, nil).AnyTimes()
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq("other.com")).Return(nil, nil).AnyTimes()
+	h.mockMetrics.EXPECT().WebSubEvent(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().SetAccountWebSubExpires(gomock.Eq(acct.Id), gomock.Any()).
+		DoAndReturn(func(_ int, expires time.Time) error {
+			assert.True(t, expires.After(time.Now().Add(time.Hour-time.Minute)))
+			return nil
+		}).Times(1)
+	ff := startFeedFollower(h)
+
+	confirmed, err := ff.VerifyWebSubIntent(acct.Handle, "subscribe", acct.WebSubTopic, 3600)
+	assert.Nil(t, err)
+	assert.True(t, confirmed)
+
+	confirmed, err = ff.VerifyWebSubIntent(acct.Handle, "subscribe", "https://pushed.site.com/other", 3600)
+	assert.Nil(t, err)
+	assert.False(t, confirmed)
+
+	confirmed, err = ff.VerifyWebSubIntent(acct.Handle, "unsubscribe", acct.WebSubTopic, 0)
+	assert.Nil(t, err)
+	assert.False(t, confirmed)
+
+	confirmed, err = ff.VerifyWebSubIntent("other.com", "subscribe", acct.WebSubTopic, 3600)
+	assert.Nil(t, err)
+	assert.False(t, confirmed)
+}
+
+func Test_Feed_Follower_WebSub_Content_Signature(t *testing.T) {
+
+	secret := "s3cr3t"
+	lastUpdated := time.Now().Add(-3 * time.Hour).UTC()
+	acct := dal.Account{
+		Id:              53,
+		Handle:          "pushed.site.com",
+		FeedLastUpdated: lastUpdated,
+		WebSubHub:       "https://hub.com",
+		WebSubTopic:     "https://pushed.site.com/feed",
+		WebSubExpires:   time.Now().Add(5 * 24 * time.Hour),
+	}
+	body := []byte(fmt.Sprintf(webSubFeedXml, acct.WebSubHub))
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := setupIdleFeedFollowerHarness(ctrl)
+	// Pushes may only hold the items that changed, so stored posts missing from them are never deleted
+	h.cfg.PropagateDeletes = true
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(acct.Handle)).Return(&acct, nil).AnyTimes()
+	h.mockRepo.EXPECT().GetWebSubSecret(gomock.Eq(acct.Id)).Return(secret, nil).AnyTimes()
+	h.mockMetrics.EXPECT().WebSubEvent(gomock.Eq("bad_signature")).Times(2)
+	h.mockMetrics.EXPECT().WebSubEvent(gomock.Eq("delivered")).Times(1)
+	// Only the properly signed delivery is processed
+	h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Eq(acct.Id)).Return(lastUpdated, nil).Times(1)
+	h.mockRepo.EXPECT().
+		UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Eq(lastUpdated), gomock.Any()).
+		DoAndReturn(func(_ int, _, nextCheckDue time.Time) error {
+			// Subscription is active: safety-net polling is a day away, not a few hours
+			assert.True(t, nextCheckDue.After(time.Now().Add(23*time.Hour)))
+			return nil
+		}).Times(1)
+	ff := startFeedFollower(h)
+
+	assert.Nil(t, ff.HandleWebSubContent(acct.Handle, body, signWebSubBody("wrong", body)))
+	assert.Nil(t, ff.HandleWebSubContent(acct.Handle, body, ""))
+	assert.Nil(t, ff.HandleWebSubContent(acct.Handle, body, signWebSubBody(secret, body)))
+}
+
+func Test_Feed_Follower_WebSub_Content_For_Stopped_Feed_Ignored(t *testing.T) {
+
+	secret := "s3cr3t"
+	active := dal.Account{
+		Id:            54,
+		Handle:        "stopped.site.com",
+		WebSubHub:     "https://hub.com",
+		WebSubTopic:   "https://stopped.site.com/feed",
+		WebSubExpires: time.Now().Add(5 * 24 * time.Hour),
+	}
+	body := []byte(fmt.Sprintf(webSubFeedXml, active.WebSubHub))
+
+	for _, status := range []dal.PollStatus{dal.PollGone, dal.PollSuspended, dal.PollBlocked, dal.PollOptedOut} {
+		ctrl := gomock.NewController(t)
+		h := setupIdleFeedFollowerHarness(ctrl)
+		stopped := active
+		stopped.PollStatus = status
+		// Stopped before the push arrived: not even the signature is checked
+		h.mockRepo.EXPECT().GetAccount(gomock.Eq(active.Handle)).Return(&stopped, nil).Times(1)
+		h.mockMetrics.EXPECT().WebSubEvent(gomock.Eq("unexpected")).Times(1)
+		// Stopped by a poll while the push waited for the account's lock: no posts are stored
+		h.mockRepo.EXPECT().GetAccount(gomock.Eq(active.Handle)).Return(&active, nil).Times(1)
+		h.mockRepo.EXPECT().GetWebSubSecret(gomock.Eq(active.Id)).Return(secret, nil).Times(1)
+		h.mockMetrics.EXPECT().WebSubEvent(gomock.Eq("delivered")).Times(1)
+		h.mockRepo.EXPECT().GetAccount(gomock.Eq(active.Handle)).Return(&stopped, nil).Times(1)
+		h.mockRepo.EXPECT().GetFeedLastUpdated(gomock.Any()).Times(0)
+		h.mockRepo.EXPECT().AddFeedPostIfNew(gomock.Any(), gomock.Any()).Times(0)
+		ff := startFeedFollower(h)
+
+		assert.Nil(t, ff.HandleWebSubContent(active.Handle, body, signWebSubBody(secret, body)))
+		assert.Nil(t, ff.HandleWebSubContent(active.Handle, body, signWebSubBody(secret, body)))
+		ctrl.Finish()
+	}
+}
@@ -0,0 +1,204 @@
+package test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/shared"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Feed server that holds each request until the test releases it, then answers 304.
+// Records how many requests it was holding at the same time.
+type heldFeedServer struct {
+	srv         *httptest.Server
+	arrived     chan struct{}
+	release     chan struct{}
+	mu          sync.Mutex
+	current     int
+	maxParallel int
+}
+
+func newHeldFeedServer(release chan struct{}) *heldFeedServer {
+	hfs := &heldFeedServer{
+		arrived: make(chan struct{}, 16),
+		release: release,
+	}
+	hfs.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hfs.mu.Lock()
+		hfs.current++
+		hfs.maxParallel = max(hfs.maxParallel, hfs.current)
+		hfs.mu.Unlock()
+		hfs.arrived <- struct{}{}
+		<-hfs.release
+		hfs.mu.Lock()
+		hfs.current--
+		hfs.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	return hfs
+}
+
+func waitForArrival(t *testing.T, arrived chan struct{}) {
+	select {
+	case <-arrived:
+	case <-time.After(2 * time.Second):
+		t.Fatal("feed server got no request")
+	}
+}
+
+func expectNotModifiedChecks(h *feedFollowerHarness, wg *sync.WaitGroup, accts []*dal.Account) {
+	h.mockMetrics.EXPECT().FeedNotModified().Times(len(accts))
+	for _, acct := range accts {
+		h.mockRepo.EXPECT().
+			UpdateAccountFeedTimes(gomock.Eq(acct.Id), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ int, _, _ time.Time) error {
+				wg.Done()
+				return nil
+			}).Times(1)
+	}
+}
+
+func Test_Feed_Follower_Workers_Check_Hosts_In_Parallel(t *testing.T) {
+
+	release := make(chan struct{})
+	servers := []*heldFeedServer{newHeldFeedServer(release), newHeldFeedServer(release)}
+	var accts []*dal.Account
+	for i, hfs := range servers {
+		defer hfs.srv.Close()
+		accts = append(accts, &dal.Account{
+			Id:              31 + i,
+			Handle:          fmt.Sprintf("parallel-%d.site.com", i),
+			FeedUrl:         hfs.srv.URL + "/feed",
+			FeedLastUpdated: time.Now().Add(-3 * time.Hour).UTC(),
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(accts))
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FeedCheckWorkers = 2
+	setupPolledAccounts(h, accts...)
+	expectNotModifiedChecks(h, &wg, accts)
+	startFeedFollower(h)
+
+	// Both hosts get their request while neither has been answered
+	for _, hfs := range servers {
+		waitForArrival(t, hfs.arrived)
+	}
+	close(release)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Feed_Follower_Workers_One_Check_Per_Host(t *testing.T) {
+
+	release := make(chan struct{})
+	hfs := newHeldFeedServer(release)
+	defer hfs.srv.Close()
+	var accts []*dal.Account
+	for i := 0; i < 3; i++ {
+		accts = append(accts, &dal.Account{
+			Id:              41 + i,
+			Handle:          fmt.Sprintf("same-host-%d.site.com", i),
+			FeedUrl:         fmt.Sprintf("%s/feed-%d", hfs.srv.URL, i),
+			FeedLastUpdated: time.Now().Add(-3 * time.Hour).UTC(),
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(accts))
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FeedCheckWorkers = 3
+	setupPolledAccounts(h, accts...)
+	expectNotModifiedChecks(h, &wg, accts)
+	startFeedFollower(h)
+
+	// Requests come one after the other
+	for range accts {
+		waitForArrival(t, hfs.arrived)
+		release <- struct{}{}
+	}
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	hfs.mu.Lock()
+	defer hfs.mu.Unlock()
+	assert.Equal(t, 1, hfs.maxParallel)
+}
+
+func Test_Feed_Follower_Workers_Busy_Host_Does_Not_Hold_Others(t *testing.T) {
+
+	releaseBusy := make(chan struct{})
+	busy := newHeldFeedServer(releaseBusy)
+	defer busy.srv.Close()
+	releaseOther := make(chan struct{})
+	other := newHeldFeedServer(releaseOther)
+	defer other.srv.Close()
+	busyHost := strings.TrimPrefix(busy.srv.URL, "http://")
+	newAcct := func(id int, srv *httptest.Server) *dal.Account {
+		return &dal.Account{
+			Id:              id,
+			Handle:          fmt.Sprintf("acct-%d.site.com", id),
+			FeedUrl:         fmt.Sprintf("%s/feed-%d", srv.URL, id),
+			FeedLastUpdated: time.Now().Add(-3 * time.Hour).UTC(),
+		}
+	}
+	accts := []*dal.Account{newAcct(51, busy.srv), newAcct(52, busy.srv), newAcct(53, busy.srv), newAcct(54, other.srv)}
+
+	var wg sync.WaitGroup
+	wg.Add(len(accts))
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newFeedFollowerHarness(ctrl)
+	h.cfg.FeedCheckWorkers = 2
+	h.cfg.UpdateSchedule = shared.UpdateSchedule{Day: 1, Week: 3, Weeks4: 6, Older: 12}
+	h.mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	h.mockMetrics.EXPECT().FeedUpdated().AnyTimes()
+	for _, acct := range accts {
+		h.mockRepo.EXPECT().GetFollowerCount(gomock.Eq(acct.Handle), gomock.Any()).Return(uint(1), nil).AnyTimes()
+	}
+	// First claim gets the accounts on the busy host: one is checked, the others wait, more of them than workers.
+	// Next claim leaves out the busy host, and gets the account on the other host.
+	var mu sync.Mutex
+	claims := 0
+	otherClaimed := false
+	h.mockRepo.EXPECT().ClaimAccountsToCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ time.Time, _ int, _ time.Time, excludeHosts map[string]bool) ([]*dal.Account, int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			claims++
+			if claims == 1 {
+				return accts[:3], 4, nil
+			}
+			if !otherClaimed && excludeHosts[busyHost] {
+				otherClaimed = true
+				return accts[3:], 1, nil
+			}
+			return nil, 0, nil
+		}).AnyTimes()
+	expectNotModifiedChecks(h, &wg, accts)
+	startFeedFollower(h)
+
+	// Other host is checked while the busy host still holds our first request
+	waitForArrival(t, busy.arrived)
+	waitForArrival(t, other.arrived)
+	close(releaseOther)
+	for i := 0; i < 3; i++ {
+		if i != 0 {
+			waitForArrival(t, busy.arrived)
+		}
+		releaseBusy <- struct{}{}
+	}
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	busy.mu.Lock()
+	defer busy.mu.Unlock()
+	assert.Equal(t, 1, busy.maxParallel)
+}
@@ -0,0 +1,126 @@
+package test
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"rss_parrot/logic"
+	"testing"
+)
+
+const callerMovedHost = "new.stardust.community"
+
+func Test_Inbox_Delete_Removes_Gone_Follower(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	// Signature could not be verified, but the actor's server confirms the actor is gone
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(true, nil).Times(1)
+	h.mockRetriever.EXPECT().Retrieve(gomock.Eq(h.sender.Id)).
+		Return(nil, fmt.Errorf("%w: got status 410", logic.ErrUserGone)).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	h.mockRepo.EXPECT().RemoveFollowerEverywhere(gomock.Eq(h.sender.Id)).Return(3, nil).Times(1)
+
+	reqProblem, err := inbox.HandleDelete(nil, makeDeleteActor(callerHost, callerName))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+}
+
+func Test_Inbox_Unverified_Delete_For_Existing_Actor_Rejected(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(true, nil).Times(1)
+	h.mockRetriever.EXPECT().Retrieve(gomock.Eq(h.sender.Id)).Return(h.sender, nil).Times(1)
+
+	reqProblem, err := inbox.HandleDelete(nil, makeDeleteActor(callerHost, callerName))
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", reqProblem)
+}
+
+func Test_Inbox_Delete_From_Non_Follower_Ignored(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	// No retrieval, no removal: we never heard of this actor
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(false, nil).Times(1)
+
+	reqProblem, err := inbox.HandleDelete(nil, makeDeleteActor(callerHost, callerName))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+}
+
+func Test_Inbox_Move_Transfers_Follows(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	target := makeCallerUserInfo(callerMovedHost, callerName, callerPubKey2)
+	target.RawAlsoKnownAs = []any{h.sender.Id}
+
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(true, nil).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	h.mockRetriever.EXPECT().Retrieve(gomock.Eq(target.Id)).Return(target, nil).Times(1)
+	h.mockRepo.EXPECT().MoveFollower(gomock.Eq(h.sender.Id), gomock.Any()).
+		DoAndReturn(func(_ string, fi *dal.FollowerInfo) (int, error) {
+			assert.Equal(t, target.Id, fi.UserUrl)
+			assert.Equal(t, callerName, fi.Handle)
+			assert.Equal(t, callerMovedHost, fi.Host)
+			assert.Equal(t, target.Inbox, fi.UserInbox)
+			assert.Equal(t, target.Endpoints.SharedInbox, fi.SharedInbox)
+			return 2, nil
+		}).Times(1)
+
+	reqProblem, err := inbox.HandleMove(h.sender, makeMoveActor(callerHost, callerName, target.Id))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+}
+
+func Test_Inbox_Move_To_Unconfirmed_Target_Rejected(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	// Target doesn't claim to be the old account
+	target := makeCallerUserInfo(callerMovedHost, callerName, callerPubKey2)
+
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(true, nil).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Times(0)
+	h.mockRetriever.EXPECT().Retrieve(gomock.Eq(target.Id)).Return(target, nil).Times(1)
+
+	reqProblem, err := inbox.HandleMove(h.sender, makeMoveActor(callerHost, callerName, target.Id))
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", reqProblem)
+}
+
+func Test_Inbox_Move_Retried_After_Target_Unreachable(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	target := makeCallerUserInfo(callerMovedHost, callerName, callerPubKey2)
+	target.RawAlsoKnownAs = []any{h.sender.Id}
+	move := makeMoveActor(callerHost, callerName, target.Id)
+
+	// First delivery: target's server is down, so we cannot confirm the move yet
+	gomock.InOrder(
+		h.mockRetriever.EXPECT().Retrieve(gomock.Eq(target.Id)).Return(nil, fmt.Errorf("connection refused")).Times(1),
+		h.mockRetriever.EXPECT().Retrieve(gomock.Eq(target.Id)).Return(target, nil).Times(1),
+	)
+	h.mockRepo.EXPECT().DoesFollowerExist(gomock.Eq(h.sender.Id)).Return(true, nil).Times(2)
+	h.mockRepo.EXPECT().MoveFollower(gomock.Eq(h.sender.Id), gomock.Any()).Return(1, nil).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+
+	reqProblem, err := inbox.HandleMove(h.sender, move)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", reqProblem)
+
+	// Sender tries again
+	reqProblem, err = inbox.HandleMove(h.sender, move)
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+}
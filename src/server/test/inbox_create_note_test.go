@@ -62,6 +62,8 @@ type inboxHarness struct {
 	mockSender    *mocks.MockIActivitySender
 	mockMessenger *mocks.MockIMessenger
 	mockFF        *mocks.MockIFeedFollower
+	mockRetriever *mocks.MockIUserRetriever
+	mockBlocks    *mocks.MockIDomainBlocks
 	sender        *dto.UserInfo
 	birbUrl       string
 	birbMoniker   string
@@ -90,6 +92,8 @@ func setupInboxTest(t *testing.T) (*gomock.Controller, *inboxHarness, logic.IInb
 		mockSender:    mocks.NewMockIActivitySender(ctrl),
 		mockMessenger: mocks.NewMockIMessenger(ctrl),
 		mockFF:        mocks.NewMockIFeedFollower(ctrl),
+		mockRetriever: mocks.NewMockIUserRetriever(ctrl),
+		mockBlocks:    mocks.NewMockIDomainBlocks(ctrl),
 		sender:        makeCallerUserInfo(callerHost, callerName, callerPubKey1),
 	}
 	h.birbUrl = fmt.Sprintf("https://%s/u/%s", h.cfg.Host, h.cfg.Birb.User)
@@ -99,9 +103,10 @@ func setupInboxTest(t *testing.T) (*gomock.Controller, *inboxHarness, logic.IInb
 	setupDummyMetrics(h.mockMetrics)
 	h.mockRepo.EXPECT().GetFeedFollowerCount().Return(0, nil).AnyTimes()
 	h.mockRepo.EXPECT().DeleteHandledActivities(gomock.Any()).AnyTimes()
+	h.mockRepo.EXPECT().DeleteDeletedToots(gomock.Any()).AnyTimes()
 
 	inbox := logic.NewInbox(h.cfg, h.mockLogger, h.mockRepo, h.mockTexts, h.mockMetrics, h.mockUDir,
-		h.mockKeyStore, h.mockSender, h.mockMessenger, h.mockFF)
+		h.mockKeyStore, h.mockSender, h.mockMessenger, h.mockFF, h.mockRetriever, h.mockBlocks)
 
 	return ctrl, h, inbox
 }
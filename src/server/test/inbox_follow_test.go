@@ -0,0 +1,87 @@
+package test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Inbox_Follow_From_Blocked_Domain_Rejected(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(birbName)).Return(&dal.Account{Handle: birbName}, nil).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	h.mockBlocks.EXPECT().IsBlocked(gomock.Eq(h.sender.Id)).Return(true).Times(1)
+	// Follower is not stored, and gets a Reject instead of an Accept
+	h.mockRepo.EXPECT().AddFollower(gomock.Any(), gomock.Any()).Times(0)
+	h.mockUDir.EXPECT().AcceptFollower(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockUDir.EXPECT().
+		RejectFollower(gomock.Any(), gomock.Eq(h.sender.Id), gomock.Eq(h.sender.Inbox), gomock.Eq(birbName)).
+		DoAndReturn(func(_, _, _, _ string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+
+	reqProblem, err := inbox.HandleFollow(birbName, h.sender, makeFollow(callerHost, callerName, h.birbUrl))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Inbox_Follow_From_Other_Domain_Accepted(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(birbName)).Return(&dal.Account{Handle: birbName}, nil).Times(1)
+	h.mockRepo.EXPECT().MarkActivityHandled(gomock.Any(), gomock.Any()).Return(false, nil).Times(1)
+	h.mockBlocks.EXPECT().IsBlocked(gomock.Eq(h.sender.Id)).Return(false).Times(1)
+	h.mockRepo.EXPECT().AddFollower(gomock.Eq(birbName), gomock.Cond(func(x any) bool {
+		return x.(*dal.FollowerInfo).UserUrl == h.sender.Id
+	})).Return(nil).Times(1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockUDir.EXPECT().
+		AcceptFollower(gomock.Any(), gomock.Eq(h.sender.Id), gomock.Eq(h.sender.Inbox), gomock.Eq(birbName)).
+		DoAndReturn(func(_, _, _, _ string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+
+	reqProblem, err := inbox.HandleFollow(birbName, h.sender, makeFollow(callerHost, callerName, h.birbUrl))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Inbox_Follow_Of_Unknown_Account_Rejected(t *testing.T) {
+
+	ctrl, h, inbox := setupInboxTest(t)
+	defer ctrl.Finish()
+
+	unknownUser := "gone.site.com"
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(unknownUser)).Return(nil, nil).Times(1)
+	h.mockRepo.EXPECT().AddFollower(gomock.Any(), gomock.Any()).Times(0)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockUDir.EXPECT().
+		RejectFollower(gomock.Any(), gomock.Eq(h.sender.Id), gomock.Eq(h.sender.Inbox), gomock.Eq(unknownUser)).
+		DoAndReturn(func(_, _, _, _ string) error {
+			wg.Done()
+			return nil
+		}).Times(1)
+
+	// Sent to shared inbox: followed user comes from the object
+	object := "https://" + birbHost + "/u/" + unknownUser
+	reqProblem, err := inbox.HandleFollow("", h.sender, makeFollow(callerHost, callerName, object))
+	assert.Nil(t, err)
+	assert.Equal(t, "", reqProblem)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
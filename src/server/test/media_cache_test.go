@@ -0,0 +1,240 @@
+package test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Toots of every existing account show the attachments in attachmentUrls
+func setupMediaCacheTest(t *testing.T, mediaDir string, attachmentUrls []string, existingAccounts ...string) (*gomock.Controller, logic.IMediaCache) {
+
+	ctrl := gomock.NewController(t)
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockUserAgent := mocks.NewMockIUserAgent(ctrl)
+	mockRepo := mocks.NewMockIRepo(ctrl)
+	setupDummyLogger(mockLogger)
+	mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	// Garbage collection runs in the background, right from the start
+	mockRepo.EXPECT().DoesAccountExist(gomock.Any()).
+		DoAndReturn(func(user string) (bool, error) {
+			for _, existing := range existingAccounts {
+				if user == existing {
+					return true, nil
+				}
+			}
+			return false, nil
+		}).AnyTimes()
+	mockRepo.EXPECT().GetTootAttachmentUrls(gomock.Any()).Return(attachmentUrls, nil).AnyTimes()
+	cfg := &shared.Config{Host: "parrot.com", MediaDir: mediaDir}
+	return ctrl, logic.NewMediaCache(cfg, mockLogger, mockUserAgent, mockRepo)
+}
+
+func decodeMediaFile(t *testing.T, mediaDir, localUrl string) (image.Config, string) {
+	fn := filepath.Join(mediaDir, strings.TrimPrefix(localUrl, "https://parrot.com/media/"))
+	data, err := os.ReadFile(fn)
+	assert.Nil(t, err)
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	assert.Nil(t, err)
+	return cfg, format
+}
+
+func Test_Media_Cache_Stores_Scaled_Images(t *testing.T) {
+
+	var requestCount atomic.Int32
+	mux := http.NewServeMux()
+	serveImages(mux, map[string][2]int{
+		"/wide-avatar.png": {1000, 500},
+		"/avatar-2.png":    {64, 64},
+	})
+	var jpegBuf bytes.Buffer
+	_ = jpeg.Encode(&jpegBuf, image.NewRGBA(image.Rect(0, 0, 3000, 1500)), nil)
+	mux.HandleFunc("/header.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(jpegBuf.Bytes())
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html></html>"))
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount.Add(1)
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	mediaDir := t.TempDir()
+	ctrl, mc := setupMediaCacheTest(t, mediaDir, nil, "site.com")
+	defer ctrl.Finish()
+
+	// Nothing stored yet: link to the original
+	assert.Equal(t, srv.URL+"/wide-avatar.png", mc.GetUrl("site.com", srv.URL+"/wide-avatar.png", logic.MediaAvatar))
+
+	// Avatar is cropped to a square, and scaled down
+	avatarUrl, err := mc.Store("site.com", srv.URL+"/wide-avatar.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(avatarUrl, "https://parrot.com/media/site.com/"))
+	assert.Equal(t, avatarUrl, mc.GetUrl("site.com", srv.URL+"/wide-avatar.png", logic.MediaAvatar))
+	cfg, format := decodeMediaFile(t, mediaDir, avatarUrl)
+	assert.Equal(t, 400, cfg.Width)
+	assert.Equal(t, 400, cfg.Height)
+	assert.Equal(t, "png", format)
+
+	// Same avatar again keeps its URL
+	again, err := mc.Store("site.com", srv.URL+"/wide-avatar.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	assert.Equal(t, avatarUrl, again)
+
+	// Stored thumbnail is not downloaded again
+	thumbUrl, err := mc.Store("site.com", srv.URL+"/avatar-2.png", logic.MediaThumbnail)
+	assert.Nil(t, err)
+	count := requestCount.Load()
+	again, err = mc.Store("site.com", srv.URL+"/avatar-2.png", logic.MediaThumbnail)
+	assert.Nil(t, err)
+	assert.Equal(t, thumbUrl, again)
+	assert.Equal(t, count, requestCount.Load())
+
+	// Header is cropped to 3:1; photos stay JPEG
+	headerUrl, err := mc.Store("site.com", srv.URL+"/header.jpg", logic.MediaHeader)
+	assert.Nil(t, err)
+	cfg, format = decodeMediaFile(t, mediaDir, headerUrl)
+	assert.Equal(t, 1500, cfg.Width)
+	assert.Equal(t, 500, cfg.Height)
+	assert.Equal(t, "jpeg", format)
+
+	// Small images are not scaled up; new avatar replaces the old one
+	newAvatarUrl, err := mc.Store("site.com", srv.URL+"/avatar-2.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	cfg, _ = decodeMediaFile(t, mediaDir, newAvatarUrl)
+	assert.Equal(t, 64, cfg.Width)
+	assert.Equal(t, srv.URL+"/wide-avatar.png", mc.GetUrl("site.com", srv.URL+"/wide-avatar.png", logic.MediaAvatar))
+	assert.Equal(t, headerUrl, mc.GetUrl("site.com", srv.URL+"/header.jpg", logic.MediaHeader))
+
+	// Not an image
+	_, err = mc.Store("site.com", srv.URL+"/page.html", logic.MediaThumbnail)
+	assert.NotNil(t, err)
+
+	// Deleted account's images are gone
+	assert.Nil(t, mc.PurgeAccount("site.com"))
+	assert.Equal(t, srv.URL+"/header.jpg", mc.GetUrl("site.com", srv.URL+"/header.jpg", logic.MediaHeader))
+	_, err = os.Stat(filepath.Join(mediaDir, "site.com"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Media_Cache_Removes_Images_Of_Deleted_Accounts(t *testing.T) {
+
+	mediaDir := t.TempDir()
+	for _, user := range []string{"kept.com", "deleted.com"} {
+		assert.Nil(t, os.MkdirAll(filepath.Join(mediaDir, user), 0755))
+		assert.Nil(t, os.WriteFile(filepath.Join(mediaDir, user, "x-avatar.png"), []byte("x"), 0644))
+	}
+
+	ctrl, _ := setupMediaCacheTest(t, mediaDir, nil, "kept.com")
+	defer ctrl.Finish()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(mediaDir, "deleted.com"))
+		return os.IsNotExist(err)
+	}, 2*time.Second, 10*time.Millisecond)
+	_, err := os.Stat(filepath.Join(mediaDir, "kept.com", "x-avatar.png"))
+	assert.Nil(t, err)
+}
+
+func Test_Media_Cache_Disabled_Links_To_Originals(t *testing.T) {
+
+	ctrl, mc := setupMediaCacheTest(t, "", nil)
+	defer ctrl.Finish()
+
+	localUrl, err := mc.Store("site.com", "https://site.com/avatar.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://site.com/avatar.png", localUrl)
+	assert.Equal(t, "https://site.com/avatar.png", mc.GetUrl("site.com", "https://site.com/avatar.png", logic.MediaAvatar))
+}
+
+func Test_Media_Cache_Changed_Image_Gets_New_Url(t *testing.T) {
+
+	// Publisher replaces the image behind the same URL
+	var size atomic.Int32
+	size.Store(64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(makePng(int(size.Load()), int(size.Load())))
+	}))
+	defer srv.Close()
+
+	mediaDir := t.TempDir()
+	ctrl, mc := setupMediaCacheTest(t, mediaDir, nil, "site.com")
+	defer ctrl.Finish()
+
+	oldUrl, err := mc.Store("site.com", srv.URL+"/logo.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	size.Store(128)
+	newUrl, err := mc.Store("site.com", srv.URL+"/logo.png", logic.MediaAvatar)
+	assert.Nil(t, err)
+	assert.NotEqual(t, oldUrl, newUrl)
+	assert.Equal(t, newUrl, mc.GetUrl("site.com", srv.URL+"/logo.png", logic.MediaAvatar))
+	cfg, _ := decodeMediaFile(t, mediaDir, newUrl)
+	assert.Equal(t, 128, cfg.Width)
+	_, err = os.Stat(filepath.Join(mediaDir, strings.TrimPrefix(oldUrl, "https://parrot.com/media/")))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Media_Cache_Refuses_Huge_Images(t *testing.T) {
+
+	// A few bytes of GIF that claim to be 30000x30000 pixels
+	gifHeader := []byte{'G', 'I', 'F', '8', '9', 'a', 0x30, 0x75, 0x30, 0x75, 0, 0, 0, ';'}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write(gifHeader)
+	}))
+	defer srv.Close()
+
+	mediaDir := t.TempDir()
+	ctrl, mc := setupMediaCacheTest(t, mediaDir, nil, "site.com")
+	defer ctrl.Finish()
+
+	_, err := mc.Store("site.com", srv.URL+"/huge.gif", logic.MediaThumbnail)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "30000x30000")
+}
+
+func Test_Media_Cache_Prunes_Thumbnails_No_Toot_Shows(t *testing.T) {
+
+	mediaDir := t.TempDir()
+	userDir := filepath.Join(mediaDir, "site.com")
+	assert.Nil(t, os.MkdirAll(userDir, 0755))
+	old := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"a-1-thumb.jpg", "b-2-thumb.jpg", "c-3-thumb.jpg", "d-4-avatar.png"} {
+		assert.Nil(t, os.WriteFile(filepath.Join(userDir, name), []byte("x"), 0644))
+		if name != "c-3-thumb.jpg" {
+			assert.Nil(t, os.Chtimes(filepath.Join(userDir, name), old, old))
+		}
+	}
+	// Toots show a-1; c-3 is new, and may be for a toot we're about to save
+	attachmentUrls := []string{"https://parrot.com/media/site.com/a-1-thumb.jpg", "https://site.com/image.png"}
+
+	ctrl, mc := setupMediaCacheTest(t, mediaDir, attachmentUrls, "site.com")
+	defer ctrl.Finish()
+
+	assert.Nil(t, mc.PruneThumbnails("site.com"))
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(userDir, name))
+		return err == nil
+	}
+	assert.True(t, exists("a-1-thumb.jpg"))
+	assert.False(t, exists("b-2-thumb.jpg"))
+	assert.True(t, exists("c-3-thumb.jpg"))
+	assert.True(t, exists("d-4-avatar.png"))
+}
@@ -0,0 +1,480 @@
+package test
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const queueTestUser = "polled.site.com"
+const queueTestStatusId = "https://parrot.com/u/polled.site.com/status/1234"
+
+var queueTestFollowers = []*dal.FollowerInfo{
+	{UserUrl: "https://instance.com/users/one", SharedInbox: "https://instance.com/inbox"},
+	{UserUrl: "https://instance.com/users/two", SharedInbox: "https://instance.com/inbox"},
+}
+
+type messengerQueueHarness struct {
+	cfg         *shared.Config
+	mockLogger  *mocks.MockILogger
+	mockRepo    *mocks.MockIRepo
+	mockSender  *mocks.MockIActivitySender
+	mockMetrics *mocks.MockIMetrics
+	keyStore    *mocks.MockIKeyStore
+}
+
+// Creates mocks with dummies for logging, metrics and keys, but nothing about the queue itself.
+func newMessengerQueueMocks(ctrl *gomock.Controller) *messengerQueueHarness {
+
+	h := &messengerQueueHarness{
+		cfg:         &shared.Config{Host: "parrot.com"},
+		mockLogger:  mocks.NewMockILogger(ctrl),
+		mockRepo:    mocks.NewMockIRepo(ctrl),
+		mockSender:  mocks.NewMockIActivitySender(ctrl),
+		mockMetrics: mocks.NewMockIMetrics(ctrl),
+		keyStore:    mocks.NewMockIKeyStore(ctrl),
+	}
+	setupDummyLogger(h.mockLogger)
+	h.mockMetrics.EXPECT().TootQueueLength(gomock.Any()).AnyTimes()
+	h.mockMetrics.EXPECT().TootQueueHostDepths(gomock.Any()).AnyTimes()
+	h.mockMetrics.EXPECT().FeedTootSent().AnyTimes()
+	h.keyStore.EXPECT().GetPrivKey(gomock.Eq(queueTestUser)).Return(&rsa.PrivateKey{}, nil).AnyTimes()
+	return h
+}
+
+// Creates mocks for a user whose followers share a single inbox. Enqueue should queue exactly one item,
+// which the toot queue picks up once. Expectations about delivery must be set up before the messenger is created.
+func newMessengerQueueHarness(ctrl *gomock.Controller) *messengerQueueHarness {
+
+	h := newMessengerQueueMocks(ctrl)
+	var mu sync.Mutex
+	var queued *dal.TootQueueItem
+	h.mockRepo.EXPECT().GetFollowersByUser(gomock.Eq(queueTestUser), gomock.Eq(true)).
+		Return(queueTestFollowers, nil).AnyTimes()
+	h.mockRepo.EXPECT().AddTootQueueItem(gomock.Any()).DoAndReturn(func(tqi *dal.TootQueueItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		queued = tqi
+		queued.Id = 1
+		return nil
+	}).Times(1)
+	h.mockRepo.EXPECT().GetTootQueueItems(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ int, _ time.Time) ([]*dal.TootQueueItem, map[string]int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if queued == nil {
+				return nil, nil, nil
+			}
+			res := []*dal.TootQueueItem{queued}
+			queued = nil
+			return res, map[string]int{"instance.com": 1}, nil
+		}).AnyTimes()
+
+	return h
+}
+
+func (h *messengerQueueHarness) start() logic.IMessenger {
+	return logic.NewMessenger(h.cfg, h.mockLogger, h.mockRepo, h.keyStore, h.mockSender, h.mockMetrics)
+}
+
+// Sets up a messenger whose single queued item is delivered successfully. Once that item has gone through
+// the toot queue, the returned function gives back the activity that was sent, and the extra headers it was sent with.
+func setupMessengerQueueTest(t *testing.T, ctrl *gomock.Controller) (logic.IMessenger, func() (*dto.ActivityOut, map[string]string)) {
+
+	h := newMessengerQueueHarness(ctrl)
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockRepo.EXPECT().RecordInboxSuccess(gomock.Eq("https://instance.com/inbox"), gomock.Any()).
+		Return(false, nil).Times(1)
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Eq("delivered")).Times(1)
+
+	// Queue item is removed once it's sent
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Eq(1)).DoAndReturn(func(_ int) error {
+		wg.Done()
+		return nil
+	}).Times(1)
+	var sent *dto.ActivityOut
+	var sentHeaders map[string]string
+	h.mockSender.EXPECT().
+		SendWithHeaders(gomock.Any(), gomock.Eq(queueTestUser), gomock.Eq("https://instance.com/inbox"), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ *rsa.PrivateKey, _, _ string, act *dto.ActivityOut, headers map[string]string) error {
+			sent = act
+			sentHeaders = headers
+			return nil
+		}).Times(1)
+
+	m := h.start()
+	waitSent := func() (*dto.ActivityOut, map[string]string) {
+		waitOnWG(t, &wg, time.Millisecond*2000)
+		return sent, sentHeaders
+	}
+	return m, waitSent
+}
+
+func Test_Messenger_Update_Goes_Out_As_Update_Activity(t *testing.T) {
+
+	tootedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m, waitSent := setupMessengerQueueTest(t, ctrl)
+
+	err := m.EnqueueUpdate(queueTestUser, queueTestStatusId, tootedAt, updatedAt, "<p>Edited</p>", nil, nil)
+	assert.Nil(t, err)
+
+	sent, _ := waitSent()
+	assert.Equal(t, "Update", sent.Type)
+	assert.True(t, strings.HasPrefix(sent.Id, queueTestStatusId+"/activity#updates/"))
+	note := sent.Object.(*dto.Note)
+	assert.Equal(t, queueTestStatusId, note.Id)
+	assert.Equal(t, "2024-01-01T10:00:00Z", note.Published)
+	assert.Equal(t, "2024-01-02T10:00:00Z", *note.Updated)
+	assert.Equal(t, "<p>Edited</p>", note.Content)
+}
+
+func Test_Messenger_Delete_Goes_Out_With_Tombstone(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m, waitSent := setupMessengerQueueTest(t, ctrl)
+
+	err := m.EnqueueDelete(queueTestUser, queueTestStatusId)
+	assert.Nil(t, err)
+
+	sent, _ := waitSent()
+	assert.Equal(t, "Delete", sent.Type)
+	assert.Equal(t, "https://parrot.com/u/polled.site.com", sent.Actor)
+	tombstone := sent.Object.(*dto.Tombstone)
+	assert.Equal(t, queueTestStatusId, tombstone.Id)
+	assert.Equal(t, "Tombstone", tombstone.Type)
+}
+
+func Test_Messenger_Broadcast_Carries_Followers_Digest(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	m, waitSent := setupMessengerQueueTest(t, ctrl)
+
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, time.Now(), "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+
+	// Digest is the XOR of the SHA256 hashes of the followers' actor URLs on the receiving host
+	hashOne := sha256.Sum256([]byte("https://instance.com/users/one"))
+	hashTwo := sha256.Sum256([]byte("https://instance.com/users/two"))
+	var digest [sha256.Size]byte
+	for i := range digest {
+		digest[i] = hashOne[i] ^ hashTwo[i]
+	}
+	expected := `collectionId="https://parrot.com/u/polled.site.com/followers", ` +
+		`url="https://parrot.com/u/polled.site.com/followers_synchronization", ` +
+		`digest="` + hex.EncodeToString(digest[:]) + `"`
+
+	sent, headers := waitSent()
+	assert.Equal(t, "Create", sent.Type)
+	assert.Equal(t, expected, headers["Collection-Synchronization"])
+}
+
+func Test_Messenger_Server_Error_Is_Retried_Later(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueHarness(ctrl)
+	h.cfg.InboxSuspendDays = 7
+
+	now := time.Now().UTC()
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&logic.DeliveryStatusError{StatusCode: 503}).Times(1)
+	h.mockRepo.EXPECT().RecordInboxFailure(gomock.Eq("https://instance.com/inbox"), gomock.Any(), gomock.Any()).
+		Return(&dal.InboxHealth{Inbox: "https://instance.com/inbox", Failures: 1, FailingSince: now}, nil).Times(1)
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Eq("retried")).Times(1)
+
+	// Item stays in the queue, scheduled for later; it is not deleted
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var nextAttemptAt time.Time
+	h.mockRepo.EXPECT().RetryTootQueueItem(gomock.Eq(1), gomock.Eq(1), gomock.Any()).
+		DoAndReturn(func(_, _ int, when time.Time) error {
+			nextAttemptAt = when
+			wg.Done()
+			return nil
+		}).Times(1)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Any()).Times(0)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, now, "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+	assert.True(t, nextAttemptAt.After(now.Add(time.Minute)))
+}
+
+func Test_Messenger_Client_Error_Is_Not_Retried(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueHarness(ctrl)
+
+	now := time.Now().UTC()
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&logic.DeliveryStatusError{StatusCode: 404}).Times(1)
+	h.mockRepo.EXPECT().RecordInboxFailure(gomock.Eq("https://instance.com/inbox"), gomock.Any(), gomock.Any()).
+		Return(&dal.InboxHealth{Inbox: "https://instance.com/inbox", Failures: 1, FailingSince: now}, nil).Times(1)
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Eq("failed")).Times(1)
+	h.mockRepo.EXPECT().RetryTootQueueItem(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Eq(1)).DoAndReturn(func(_ int) error {
+		wg.Done()
+		return nil
+	}).Times(1)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, now, "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Messenger_Long_Failing_Inbox_Gets_Suspended(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueHarness(ctrl)
+	h.cfg.InboxSuspendDays = 7
+
+	now := time.Now().UTC()
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&logic.DeliveryStatusError{StatusCode: 502}).Times(1)
+	h.mockRepo.EXPECT().RecordInboxFailure(gomock.Eq("https://instance.com/inbox"), gomock.Any(), gomock.Any()).
+		Return(&dal.InboxHealth{
+			Inbox:        "https://instance.com/inbox",
+			Failures:     40,
+			FailingSince: now.Add(-8 * 24 * time.Hour),
+		}, nil).Times(1)
+	h.mockRepo.EXPECT().SuspendInbox(gomock.Eq("https://instance.com/inbox")).Return(nil).Times(1)
+	h.mockRepo.EXPECT().GetSuspendedInboxCount().Return(1, nil).Times(1)
+	h.mockMetrics.EXPECT().SuspendedInboxCount(gomock.Eq(1)).Times(1)
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Eq("suspended")).Times(1)
+	h.mockRepo.EXPECT().RetryTootQueueItem(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Eq(1)).DoAndReturn(func(_ int) error {
+		wg.Done()
+		return nil
+	}).Times(1)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, now, "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Messenger_Suspended_Inbox_Is_Skipped(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueHarness(ctrl)
+	h.cfg.InboxSuspendDays = 7
+
+	now := time.Now().UTC()
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Eq("https://instance.com/inbox")).
+		Return(&dal.InboxHealth{
+			Inbox:       "https://instance.com/inbox",
+			Failures:    60,
+			LastFailure: now.Add(-time.Hour),
+			Suspended:   true,
+		}, nil).AnyTimes()
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Eq("skipped")).Times(1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Eq(1)).DoAndReturn(func(_ int) error {
+		wg.Done()
+		return nil
+	}).Times(1)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, now, "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+}
+
+func Test_Messenger_Slow_Host_Does_Not_Hold_Up_Others(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueMocks(ctrl)
+	h.cfg.DeliveriesPerHost = 1
+
+	// Two followers on a slow host with their own inboxes, one on a fast host
+	followers := []*dal.FollowerInfo{
+		{UserUrl: "https://slow.com/users/one", UserInbox: "https://slow.com/users/one/inbox"},
+		{UserUrl: "https://slow.com/users/two", UserInbox: "https://slow.com/users/two/inbox"},
+		{UserUrl: "https://fast.com/users/three", UserInbox: "https://fast.com/users/three/inbox"},
+	}
+	h.mockRepo.EXPECT().GetFollowersByUser(gomock.Eq(queueTestUser), gomock.Eq(true)).Return(followers, nil).AnyTimes()
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockRepo.EXPECT().RecordInboxSuccess(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Any()).AnyTimes()
+
+	// Queue keeps items until they are deleted
+	var muQueue sync.Mutex
+	var queue []*dal.TootQueueItem
+	var wgFast, wgSlow sync.WaitGroup
+	wgFast.Add(1)
+	wgSlow.Add(2)
+	h.mockRepo.EXPECT().AddTootQueueItem(gomock.Any()).DoAndReturn(func(tqi *dal.TootQueueItem) error {
+		muQueue.Lock()
+		defer muQueue.Unlock()
+		tqi.Id = len(queue) + 1
+		queue = append(queue, tqi)
+		return nil
+	}).Times(3)
+	h.mockRepo.EXPECT().GetTootQueueItems(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ int, _ time.Time) ([]*dal.TootQueueItem, map[string]int, error) {
+			muQueue.Lock()
+			defer muQueue.Unlock()
+			var res []*dal.TootQueueItem
+			depths := make(map[string]int)
+			for _, tqi := range queue {
+				if tqi != nil {
+					res = append(res, tqi)
+					depths[tqi.ToHost]++
+				}
+			}
+			return res, depths, nil
+		}).AnyTimes()
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Any()).DoAndReturn(func(id int) error {
+		muQueue.Lock()
+		defer muQueue.Unlock()
+		if queue[id-1].ToHost == "fast.com" {
+			wgFast.Done()
+		} else {
+			wgSlow.Done()
+		}
+		queue[id-1] = nil
+		return nil
+	}).Times(3)
+
+	// Deliveries to the slow host hang until released
+	release := make(chan struct{})
+	var muSlow sync.Mutex
+	slowSends, maxSlowSends := 0, 0
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ *rsa.PrivateKey, _, inbox string, _ *dto.ActivityOut, _ map[string]string) error {
+			if !strings.HasPrefix(inbox, "https://slow.com/") {
+				return nil
+			}
+			muSlow.Lock()
+			slowSends++
+			maxSlowSends = max(maxSlowSends, slowSends)
+			muSlow.Unlock()
+			<-release
+			muSlow.Lock()
+			slowSends--
+			muSlow.Unlock()
+			return nil
+		}).Times(3)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, time.Now(), "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+
+	// Fast host gets its toot while the slow host is still busy with its first one
+	waitOnWG(t, &wgFast, time.Millisecond*2000)
+	close(release)
+	waitOnWG(t, &wgSlow, time.Millisecond*2000)
+	muSlow.Lock()
+	assert.Equal(t, 1, maxSlowSends)
+	muSlow.Unlock()
+}
+
+func Test_Messenger_Broadcast_Loads_Followers_Once(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	h := newMessengerQueueMocks(ctrl)
+
+	// Followers on three hosts: one broadcast, three deliveries, each with its own digest
+	followers := []*dal.FollowerInfo{
+		{UserUrl: "https://one.com/users/a", UserInbox: "https://one.com/users/a/inbox"},
+		{UserUrl: "https://two.com/users/b", UserInbox: "https://two.com/users/b/inbox"},
+		{UserUrl: "https://three.com/users/c", UserInbox: "https://three.com/users/c/inbox"},
+	}
+	h.mockRepo.EXPECT().GetFollowersByUser(gomock.Eq(queueTestUser), gomock.Eq(true)).Return(followers, nil).Times(1)
+	h.mockRepo.EXPECT().GetInboxHealth(gomock.Any()).Return(nil, nil).AnyTimes()
+	h.mockRepo.EXPECT().RecordInboxSuccess(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	h.mockMetrics.EXPECT().DeliveryOutcome(gomock.Any()).AnyTimes()
+
+	var muQueue sync.Mutex
+	var queue []*dal.TootQueueItem
+	h.mockRepo.EXPECT().AddTootQueueItem(gomock.Any()).DoAndReturn(func(tqi *dal.TootQueueItem) error {
+		muQueue.Lock()
+		defer muQueue.Unlock()
+		tqi.Id = len(queue) + 1
+		queue = append(queue, tqi)
+		return nil
+	}).Times(3)
+	h.mockRepo.EXPECT().GetTootQueueItems(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, _ int, _ time.Time) ([]*dal.TootQueueItem, map[string]int, error) {
+			muQueue.Lock()
+			defer muQueue.Unlock()
+			var res []*dal.TootQueueItem
+			depths := make(map[string]int)
+			for _, tqi := range queue {
+				if tqi != nil {
+					res = append(res, tqi)
+					depths[tqi.ToHost]++
+				}
+			}
+			return res, depths, nil
+		}).AnyTimes()
+	var wg sync.WaitGroup
+	wg.Add(3)
+	h.mockRepo.EXPECT().DeleteTootQueueItem(gomock.Any()).DoAndReturn(func(id int) error {
+		muQueue.Lock()
+		defer muQueue.Unlock()
+		queue[id-1] = nil
+		wg.Done()
+		return nil
+	}).Times(3)
+
+	var muSent sync.Mutex
+	syncHeaders := make(map[string]string)
+	h.mockSender.EXPECT().SendWithHeaders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ *rsa.PrivateKey, _, inbox string, _ *dto.ActivityOut, headers map[string]string) error {
+			muSent.Lock()
+			defer muSent.Unlock()
+			syncHeaders[inbox] = headers["Collection-Synchronization"]
+			return nil
+		}).Times(3)
+
+	m := h.start()
+	err := m.EnqueueBroadcast(queueTestUser, queueTestStatusId, time.Now(), "<p>Hello</p>", nil, nil)
+	assert.Nil(t, err)
+	waitOnWG(t, &wg, time.Millisecond*2000)
+
+	muSent.Lock()
+	defer muSent.Unlock()
+	for _, f := range followers {
+		hash := sha256.Sum256([]byte(f.UserUrl))
+		assert.Contains(t, syncHeaders[f.UserInbox], `digest="`+hex.EncodeToString(hash[:])+`"`)
+	}
+}
@@ -53,3 +53,17 @@ func (mr *MockIActivitySenderMockRecorder) Send(arg0, arg1, arg2, arg3 any) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockIActivitySender)(nil).Send), arg0, arg1, arg2, arg3)
 }
+
+// SendWithHeaders mocks base method.
+func (m *MockIActivitySender) SendWithHeaders(arg0 *rsa.PrivateKey, arg1, arg2 string, arg3 *dto.ActivityOut, arg4 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendWithHeaders", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendWithHeaders indicates an expected call of SendWithHeaders.
+func (mr *MockIActivitySenderMockRecorder) SendWithHeaders(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWithHeaders", reflect.TypeOf((*MockIActivitySender)(nil).SendWithHeaders), arg0, arg1, arg2, arg3, arg4)
+}
@@ -11,6 +11,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	logic "rss_parrot/logic"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -38,12 +39,42 @@ func (m *MockIBlockedFeeds) EXPECT() *MockIBlockedFeedsMockRecorder {
 	return m.recorder
 }
 
+// Block mocks base method.
+func (m *MockIBlockedFeeds) Block(arg0, arg1 string, arg2 bool) (bool, []string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", arg0, arg1, arg2)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockIBlockedFeedsMockRecorder) Block(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockIBlockedFeeds)(nil).Block), arg0, arg1, arg2)
+}
+
+// GetAll mocks base method.
+func (m *MockIBlockedFeeds) GetAll() []*logic.BlockedFeed {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAll")
+	ret0, _ := ret[0].([]*logic.BlockedFeed)
+	return ret0
+}
+
+// GetAll indicates an expected call of GetAll.
+func (mr *MockIBlockedFeedsMockRecorder) GetAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockIBlockedFeeds)(nil).GetAll))
+}
+
 // IsBlocked mocks base method.
-func (m *MockIBlockedFeeds) IsBlocked(arg0 string) (bool, error) {
+func (m *MockIBlockedFeeds) IsBlocked(arg0 string) (bool, string) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "IsBlocked", arg0)
 	ret0, _ := ret[0].(bool)
-	ret1, _ := ret[1].(error)
+	ret1, _ := ret[1].(string)
 	return ret0, ret1
 }
 
@@ -52,3 +83,18 @@ func (mr *MockIBlockedFeedsMockRecorder) IsBlocked(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlocked", reflect.TypeOf((*MockIBlockedFeeds)(nil).IsBlocked), arg0)
 }
+
+// Unblock mocks base method.
+func (m *MockIBlockedFeeds) Unblock(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unblock", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unblock indicates an expected call of Unblock.
+func (mr *MockIBlockedFeedsMockRecorder) Unblock(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*MockIBlockedFeeds)(nil).Unblock), arg0)
+}
@@ -0,0 +1,54 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rss_parrot/logic (interfaces: IDnsResolver)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_dns_resolver.go -package mocks rss_parrot/logic IDnsResolver
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIDnsResolver is a mock of IDnsResolver interface.
+type MockIDnsResolver struct {
+	ctrl     *gomock.Controller
+	recorder *MockIDnsResolverMockRecorder
+}
+
+// MockIDnsResolverMockRecorder is the mock recorder for MockIDnsResolver.
+type MockIDnsResolverMockRecorder struct {
+	mock *MockIDnsResolver
+}
+
+// NewMockIDnsResolver creates a new mock instance.
+func NewMockIDnsResolver(ctrl *gomock.Controller) *MockIDnsResolver {
+	mock := &MockIDnsResolver{ctrl: ctrl}
+	mock.recorder = &MockIDnsResolverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIDnsResolver) EXPECT() *MockIDnsResolverMockRecorder {
+	return m.recorder
+}
+
+// LookupTXT mocks base method.
+func (m *MockIDnsResolver) LookupTXT(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupTXT", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupTXT indicates an expected call of LookupTXT.
+func (mr *MockIDnsResolverMockRecorder) LookupTXT(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupTXT", reflect.TypeOf((*MockIDnsResolver)(nil).LookupTXT), arg0)
+}
@@ -0,0 +1,100 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rss_parrot/logic (interfaces: IDomainBlocks)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_domain_blocks.go -package mocks rss_parrot/logic IDomainBlocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	dal "rss_parrot/dal"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIDomainBlocks is a mock of IDomainBlocks interface.
+type MockIDomainBlocks struct {
+	ctrl     *gomock.Controller
+	recorder *MockIDomainBlocksMockRecorder
+}
+
+// MockIDomainBlocksMockRecorder is the mock recorder for MockIDomainBlocks.
+type MockIDomainBlocksMockRecorder struct {
+	mock *MockIDomainBlocks
+}
+
+// NewMockIDomainBlocks creates a new mock instance.
+func NewMockIDomainBlocks(ctrl *gomock.Controller) *MockIDomainBlocks {
+	mock := &MockIDomainBlocks{ctrl: ctrl}
+	mock.recorder = &MockIDomainBlocksMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIDomainBlocks) EXPECT() *MockIDomainBlocksMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockIDomainBlocks) Block(arg0, arg1 string) (bool, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockIDomainBlocksMockRecorder) Block(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockIDomainBlocks)(nil).Block), arg0, arg1)
+}
+
+// GetAll mocks base method.
+func (m *MockIDomainBlocks) GetAll() ([]*dal.BlockedDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAll")
+	ret0, _ := ret[0].([]*dal.BlockedDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAll indicates an expected call of GetAll.
+func (mr *MockIDomainBlocksMockRecorder) GetAll() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockIDomainBlocks)(nil).GetAll))
+}
+
+// IsBlocked mocks base method.
+func (m *MockIDomainBlocks) IsBlocked(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBlocked", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsBlocked indicates an expected call of IsBlocked.
+func (mr *MockIDomainBlocksMockRecorder) IsBlocked(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlocked", reflect.TypeOf((*MockIDomainBlocks)(nil).IsBlocked), arg0)
+}
+
+// Unblock mocks base method.
+func (m *MockIDomainBlocks) Unblock(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unblock", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unblock indicates an expected call of Unblock.
+func (mr *MockIDomainBlocksMockRecorder) Unblock(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unblock", reflect.TypeOf((*MockIDomainBlocks)(nil).Unblock), arg0)
+}
@@ -56,6 +56,20 @@ func (mr *MockIFeedFollowerMockRecorder) GetAccountForFeed(arg0 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForFeed", reflect.TypeOf((*MockIFeedFollower)(nil).GetAccountForFeed), arg0)
 }
 
+// HandleWebSubContent mocks base method.
+func (m *MockIFeedFollower) HandleWebSubContent(arg0 string, arg1 []byte, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HandleWebSubContent", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HandleWebSubContent indicates an expected call of HandleWebSubContent.
+func (mr *MockIFeedFollowerMockRecorder) HandleWebSubContent(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleWebSubContent", reflect.TypeOf((*MockIFeedFollower)(nil).HandleWebSubContent), arg0, arg1, arg2)
+}
+
 // PurgeOldPosts mocks base method.
 func (m *MockIFeedFollower) PurgeOldPosts(arg0 *dal.Account, arg1, arg2 int) error {
 	m.ctrl.T.Helper()
@@ -69,3 +83,18 @@ func (mr *MockIFeedFollowerMockRecorder) PurgeOldPosts(arg0, arg1, arg2 any) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeOldPosts", reflect.TypeOf((*MockIFeedFollower)(nil).PurgeOldPosts), arg0, arg1, arg2)
 }
+
+// VerifyWebSubIntent mocks base method.
+func (m *MockIFeedFollower) VerifyWebSubIntent(arg0, arg1, arg2 string, arg3 int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyWebSubIntent", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyWebSubIntent indicates an expected call of VerifyWebSubIntent.
+func (mr *MockIFeedFollowerMockRecorder) VerifyWebSubIntent(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyWebSubIntent", reflect.TypeOf((*MockIFeedFollower)(nil).VerifyWebSubIntent), arg0, arg1, arg2, arg3)
+}
@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rss_parrot/logic (interfaces: IMediaCache)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_media_cache.go -package mocks rss_parrot/logic IMediaCache
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	logic "rss_parrot/logic"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIMediaCache is a mock of IMediaCache interface.
+type MockIMediaCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockIMediaCacheMockRecorder
+}
+
+// MockIMediaCacheMockRecorder is the mock recorder for MockIMediaCache.
+type MockIMediaCacheMockRecorder struct {
+	mock *MockIMediaCache
+}
+
+// NewMockIMediaCache creates a new mock instance.
+func NewMockIMediaCache(ctrl *gomock.Controller) *MockIMediaCache {
+	mock := &MockIMediaCache{ctrl: ctrl}
+	mock.recorder = &MockIMediaCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIMediaCache) EXPECT() *MockIMediaCacheMockRecorder {
+	return m.recorder
+}
+
+// GetUrl mocks base method.
+func (m *MockIMediaCache) GetUrl(arg0, arg1 string, arg2 logic.MediaKind) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUrl", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GetUrl indicates an expected call of GetUrl.
+func (mr *MockIMediaCacheMockRecorder) GetUrl(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUrl", reflect.TypeOf((*MockIMediaCache)(nil).GetUrl), arg0, arg1, arg2)
+}
+
+// PruneThumbnails mocks base method.
+func (m *MockIMediaCache) PruneThumbnails(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneThumbnails", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneThumbnails indicates an expected call of PruneThumbnails.
+func (mr *MockIMediaCacheMockRecorder) PruneThumbnails(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneThumbnails", reflect.TypeOf((*MockIMediaCache)(nil).PruneThumbnails), arg0)
+}
+
+// PurgeAccount mocks base method.
+func (m *MockIMediaCache) PurgeAccount(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeAccount", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeAccount indicates an expected call of PurgeAccount.
+func (mr *MockIMediaCacheMockRecorder) PurgeAccount(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeAccount", reflect.TypeOf((*MockIMediaCache)(nil).PurgeAccount), arg0)
+}
+
+// Store mocks base method.
+func (m *MockIMediaCache) Store(arg0, arg1 string, arg2 logic.MediaKind) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Store", arg0, arg1, arg2)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Store indicates an expected call of Store.
+func (mr *MockIMediaCacheMockRecorder) Store(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Store", reflect.TypeOf((*MockIMediaCache)(nil).Store), arg0, arg1, arg2)
+}
@@ -11,6 +11,7 @@ package mocks
 
 import (
 	reflect "reflect"
+	dal "rss_parrot/dal"
 	logic "rss_parrot/logic"
 	time "time"
 
@@ -41,17 +42,45 @@ func (m *MockIMessenger) EXPECT() *MockIMessengerMockRecorder {
 }
 
 // EnqueueBroadcast mocks base method.
-func (m *MockIMessenger) EnqueueBroadcast(arg0, arg1 string, arg2 time.Time, arg3 string) error {
+func (m *MockIMessenger) EnqueueBroadcast(arg0, arg1 string, arg2 time.Time, arg3 string, arg4 []*dal.TootAttachment, arg5 []string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "EnqueueBroadcast", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "EnqueueBroadcast", arg0, arg1, arg2, arg3, arg4, arg5)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // EnqueueBroadcast indicates an expected call of EnqueueBroadcast.
-func (mr *MockIMessengerMockRecorder) EnqueueBroadcast(arg0, arg1, arg2, arg3 any) *gomock.Call {
+func (mr *MockIMessengerMockRecorder) EnqueueBroadcast(arg0, arg1, arg2, arg3, arg4, arg5 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueBroadcast", reflect.TypeOf((*MockIMessenger)(nil).EnqueueBroadcast), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueBroadcast", reflect.TypeOf((*MockIMessenger)(nil).EnqueueBroadcast), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// EnqueueDelete mocks base method.
+func (m *MockIMessenger) EnqueueDelete(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueDelete", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueDelete indicates an expected call of EnqueueDelete.
+func (mr *MockIMessengerMockRecorder) EnqueueDelete(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueDelete", reflect.TypeOf((*MockIMessenger)(nil).EnqueueDelete), arg0, arg1)
+}
+
+// EnqueueUpdate mocks base method.
+func (m *MockIMessenger) EnqueueUpdate(arg0, arg1 string, arg2, arg3 time.Time, arg4 string, arg5 []*dal.TootAttachment, arg6 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueUpdate", arg0, arg1, arg2, arg3, arg4, arg5, arg6)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnqueueUpdate indicates an expected call of EnqueueUpdate.
+func (mr *MockIMessengerMockRecorder) EnqueueUpdate(arg0, arg1, arg2, arg3, arg4, arg5, arg6 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueUpdate", reflect.TypeOf((*MockIMessenger)(nil).EnqueueUpdate), arg0, arg1, arg2, arg3, arg4, arg5, arg6)
 }
 
 // SendMessageAsync mocks base method.
@@ -63,6 +63,42 @@ func (mr *MockIMetricsMockRecorder) DbFileSize(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DbFileSize", reflect.TypeOf((*MockIMetrics)(nil).DbFileSize), arg0)
 }
 
+// DeliveryOutcome mocks base method.
+func (m *MockIMetrics) DeliveryOutcome(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "DeliveryOutcome", arg0)
+}
+
+// DeliveryOutcome indicates an expected call of DeliveryOutcome.
+func (mr *MockIMetricsMockRecorder) DeliveryOutcome(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliveryOutcome", reflect.TypeOf((*MockIMetrics)(nil).DeliveryOutcome), arg0)
+}
+
+// FeedCheckProblem mocks base method.
+func (m *MockIMetrics) FeedCheckProblem(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FeedCheckProblem", arg0)
+}
+
+// FeedCheckProblem indicates an expected call of FeedCheckProblem.
+func (mr *MockIMetricsMockRecorder) FeedCheckProblem(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FeedCheckProblem", reflect.TypeOf((*MockIMetrics)(nil).FeedCheckProblem), arg0)
+}
+
+// FeedNotModified mocks base method.
+func (m *MockIMetrics) FeedNotModified() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FeedNotModified")
+}
+
+// FeedNotModified indicates an expected call of FeedNotModified.
+func (mr *MockIMetricsMockRecorder) FeedNotModified() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FeedNotModified", reflect.TypeOf((*MockIMetrics)(nil).FeedNotModified))
+}
+
 // FeedRequested mocks base method.
 func (m *MockIMetrics) FeedRequested(arg0 string) {
 	m.ctrl.T.Helper()
@@ -111,6 +147,30 @@ func (mr *MockIMetricsMockRecorder) NewPostSaved() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewPostSaved", reflect.TypeOf((*MockIMetrics)(nil).NewPostSaved))
 }
 
+// PostRepublished mocks base method.
+func (m *MockIMetrics) PostRepublished() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PostRepublished")
+}
+
+// PostRepublished indicates an expected call of PostRepublished.
+func (mr *MockIMetricsMockRecorder) PostRepublished() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostRepublished", reflect.TypeOf((*MockIMetrics)(nil).PostRepublished))
+}
+
+// PostUpdated mocks base method.
+func (m *MockIMetrics) PostUpdated() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PostUpdated")
+}
+
+// PostUpdated indicates an expected call of PostUpdated.
+func (mr *MockIMetricsMockRecorder) PostUpdated() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostUpdated", reflect.TypeOf((*MockIMetrics)(nil).PostUpdated))
+}
+
 // PostsDeleted mocks base method.
 func (m *MockIMetrics) PostsDeleted(arg0 int) {
 	m.ctrl.T.Helper()
@@ -123,6 +183,18 @@ func (mr *MockIMetricsMockRecorder) PostsDeleted(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostsDeleted", reflect.TypeOf((*MockIMetrics)(nil).PostsDeleted), arg0)
 }
 
+// PostsDigested mocks base method.
+func (m *MockIMetrics) PostsDigested(arg0 int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PostsDigested", arg0)
+}
+
+// PostsDigested indicates an expected call of PostsDigested.
+func (mr *MockIMetricsMockRecorder) PostsDigested(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostsDigested", reflect.TypeOf((*MockIMetrics)(nil).PostsDigested), arg0)
+}
+
 // ServiceStarted mocks base method.
 func (m *MockIMetrics) ServiceStarted() {
 	m.ctrl.T.Helper()
@@ -177,6 +249,30 @@ func (mr *MockIMetricsMockRecorder) StartWebRequestIn(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartWebRequestIn", reflect.TypeOf((*MockIMetrics)(nil).StartWebRequestIn), arg0)
 }
 
+// SuspendedInboxCount mocks base method.
+func (m *MockIMetrics) SuspendedInboxCount(arg0 int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SuspendedInboxCount", arg0)
+}
+
+// SuspendedInboxCount indicates an expected call of SuspendedInboxCount.
+func (mr *MockIMetricsMockRecorder) SuspendedInboxCount(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuspendedInboxCount", reflect.TypeOf((*MockIMetrics)(nil).SuspendedInboxCount), arg0)
+}
+
+// TootQueueHostDepths mocks base method.
+func (m *MockIMetrics) TootQueueHostDepths(arg0 map[string]int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "TootQueueHostDepths", arg0)
+}
+
+// TootQueueHostDepths indicates an expected call of TootQueueHostDepths.
+func (mr *MockIMetricsMockRecorder) TootQueueHostDepths(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TootQueueHostDepths", reflect.TypeOf((*MockIMetrics)(nil).TootQueueHostDepths), arg0)
+}
+
 // TootQueueLength mocks base method.
 func (m *MockIMetrics) TootQueueLength(arg0 int) {
 	m.ctrl.T.Helper()
@@ -212,3 +308,15 @@ func (mr *MockIMetricsMockRecorder) TotalPosts(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TotalPosts", reflect.TypeOf((*MockIMetrics)(nil).TotalPosts), arg0)
 }
+
+// WebSubEvent mocks base method.
+func (m *MockIMetrics) WebSubEvent(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "WebSubEvent", arg0)
+}
+
+// WebSubEvent indicates an expected call of WebSubEvent.
+func (mr *MockIMetricsMockRecorder) WebSubEvent(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebSubEvent", reflect.TypeOf((*MockIMetrics)(nil).WebSubEvent), arg0)
+}
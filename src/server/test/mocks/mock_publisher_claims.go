@@ -0,0 +1,115 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rss_parrot/logic (interfaces: IPublisherClaims)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_publisher_claims.go -package mocks rss_parrot/logic IPublisherClaims
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	dal "rss_parrot/dal"
+	logic "rss_parrot/logic"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIPublisherClaims is a mock of IPublisherClaims interface.
+type MockIPublisherClaims struct {
+	ctrl     *gomock.Controller
+	recorder *MockIPublisherClaimsMockRecorder
+}
+
+// MockIPublisherClaimsMockRecorder is the mock recorder for MockIPublisherClaims.
+type MockIPublisherClaimsMockRecorder struct {
+	mock *MockIPublisherClaims
+}
+
+// NewMockIPublisherClaims creates a new mock instance.
+func NewMockIPublisherClaims(ctrl *gomock.Controller) *MockIPublisherClaims {
+	mock := &MockIPublisherClaims{ctrl: ctrl}
+	mock.recorder = &MockIPublisherClaimsMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIPublisherClaims) EXPECT() *MockIPublisherClaimsMockRecorder {
+	return m.recorder
+}
+
+// GetVerifiedClaims mocks base method.
+func (m *MockIPublisherClaims) GetVerifiedClaims() ([]*dal.AccountClaim, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerifiedClaims")
+	ret0, _ := ret[0].([]*dal.AccountClaim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerifiedClaims indicates an expected call of GetVerifiedClaims.
+func (mr *MockIPublisherClaimsMockRecorder) GetVerifiedClaims() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerifiedClaims", reflect.TypeOf((*MockIPublisherClaims)(nil).GetVerifiedClaims))
+}
+
+// RequestDeletion mocks base method.
+func (m *MockIPublisherClaims) RequestDeletion(arg0, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestDeletion", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RequestDeletion indicates an expected call of RequestDeletion.
+func (mr *MockIPublisherClaimsMockRecorder) RequestDeletion(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestDeletion", reflect.TypeOf((*MockIPublisherClaims)(nil).RequestDeletion), arg0, arg1)
+}
+
+// SetProfile mocks base method.
+func (m *MockIPublisherClaims) SetProfile(arg0, arg1 string, arg2 *logic.ClaimedProfile) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetProfile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetProfile indicates an expected call of SetProfile.
+func (mr *MockIPublisherClaimsMockRecorder) SetProfile(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProfile", reflect.TypeOf((*MockIPublisherClaims)(nil).SetProfile), arg0, arg1, arg2)
+}
+
+// Start mocks base method.
+func (m *MockIPublisherClaims) Start(arg0 string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockIPublisherClaimsMockRecorder) Start(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockIPublisherClaims)(nil).Start), arg0)
+}
+
+// Verify mocks base method.
+func (m *MockIPublisherClaims) Verify(arg0, arg1 string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockIPublisherClaimsMockRecorder) Verify(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockIPublisherClaims)(nil).Verify), arg0, arg1)
+}
@@ -40,6 +40,20 @@ func (m *MockIRepo) EXPECT() *MockIRepoMockRecorder {
 	return m.recorder
 }
 
+// AddAccountClaim mocks base method.
+func (m *MockIRepo) AddAccountClaim(arg0 *dal.AccountClaim) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAccountClaim", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAccountClaim indicates an expected call of AddAccountClaim.
+func (mr *MockIRepoMockRecorder) AddAccountClaim(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountClaim", reflect.TypeOf((*MockIRepo)(nil).AddAccountClaim), arg0)
+}
+
 // AddAccountIfNotExist mocks base method.
 func (m *MockIRepo) AddAccountIfNotExist(arg0 *dal.Account, arg1 string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -55,6 +69,21 @@ func (mr *MockIRepoMockRecorder) AddAccountIfNotExist(arg0, arg1 any) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountIfNotExist", reflect.TypeOf((*MockIRepo)(nil).AddAccountIfNotExist), arg0, arg1)
 }
 
+// AddBlockedDomain mocks base method.
+func (m *MockIRepo) AddBlockedDomain(arg0 *dal.BlockedDomain) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBlockedDomain", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddBlockedDomain indicates an expected call of AddBlockedDomain.
+func (mr *MockIRepoMockRecorder) AddBlockedDomain(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBlockedDomain", reflect.TypeOf((*MockIRepo)(nil).AddBlockedDomain), arg0)
+}
+
 // AddFeedPostIfNew mocks base method.
 func (m *MockIRepo) AddFeedPostIfNew(arg0 int, arg1 *dal.FeedPost) (bool, error) {
 	m.ctrl.T.Helper()
@@ -126,6 +155,36 @@ func (mr *MockIRepoMockRecorder) BruteDeleteAccount(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BruteDeleteAccount", reflect.TypeOf((*MockIRepo)(nil).BruteDeleteAccount), arg0)
 }
 
+// ClaimAccountsToCheck mocks base method.
+func (m *MockIRepo) ClaimAccountsToCheck(arg0 time.Time, arg1 int, arg2 time.Time, arg3 map[string]bool) ([]*dal.Account, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimAccountsToCheck", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]*dal.Account)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ClaimAccountsToCheck indicates an expected call of ClaimAccountsToCheck.
+func (mr *MockIRepoMockRecorder) ClaimAccountsToCheck(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimAccountsToCheck", reflect.TypeOf((*MockIRepo)(nil).ClaimAccountsToCheck), arg0, arg1, arg2, arg3)
+}
+
+// DeleteDeletedToots mocks base method.
+func (m *MockIRepo) DeleteDeletedToots(arg0 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeletedToots", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDeletedToots indicates an expected call of DeleteDeletedToots.
+func (mr *MockIRepoMockRecorder) DeleteDeletedToots(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeletedToots", reflect.TypeOf((*MockIRepo)(nil).DeleteDeletedToots), arg0)
+}
+
 // DeleteHandledActivities mocks base method.
 func (m *MockIRepo) DeleteHandledActivities(arg0 time.Time) error {
 	m.ctrl.T.Helper()
@@ -140,6 +199,35 @@ func (mr *MockIRepoMockRecorder) DeleteHandledActivities(arg0 any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteHandledActivities", reflect.TypeOf((*MockIRepo)(nil).DeleteHandledActivities), arg0)
 }
 
+// DeleteOlderUnverifiedAccountClaims mocks base method.
+func (m *MockIRepo) DeleteOlderUnverifiedAccountClaims(arg0, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOlderUnverifiedAccountClaims", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOlderUnverifiedAccountClaims indicates an expected call of DeleteOlderUnverifiedAccountClaims.
+func (mr *MockIRepoMockRecorder) DeleteOlderUnverifiedAccountClaims(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOlderUnverifiedAccountClaims", reflect.TypeOf((*MockIRepo)(nil).DeleteOlderUnverifiedAccountClaims), arg0, arg1)
+}
+
+// DeletePostAndToot mocks base method.
+func (m *MockIRepo) DeletePostAndToot(arg0 int, arg1 int64) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePostAndToot", arg0, arg1)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePostAndToot indicates an expected call of DeletePostAndToot.
+func (mr *MockIRepoMockRecorder) DeletePostAndToot(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePostAndToot", reflect.TypeOf((*MockIRepo)(nil).DeletePostAndToot), arg0, arg1)
+}
+
 // DeleteTootQueueItem mocks base method.
 func (m *MockIRepo) DeleteTootQueueItem(arg0 int) error {
 	m.ctrl.T.Helper()
@@ -154,6 +242,20 @@ func (mr *MockIRepoMockRecorder) DeleteTootQueueItem(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTootQueueItem", reflect.TypeOf((*MockIRepo)(nil).DeleteTootQueueItem), arg0)
 }
 
+// DeleteUnverifiedAccountClaims mocks base method.
+func (m *MockIRepo) DeleteUnverifiedAccountClaims(arg0 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUnverifiedAccountClaims", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUnverifiedAccountClaims indicates an expected call of DeleteUnverifiedAccountClaims.
+func (mr *MockIRepoMockRecorder) DeleteUnverifiedAccountClaims(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUnverifiedAccountClaims", reflect.TypeOf((*MockIRepo)(nil).DeleteUnverifiedAccountClaims), arg0)
+}
+
 // DoesAccountExist mocks base method.
 func (m *MockIRepo) DoesAccountExist(arg0 string) (bool, error) {
 	m.ctrl.T.Helper()
@@ -169,6 +271,21 @@ func (mr *MockIRepoMockRecorder) DoesAccountExist(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoesAccountExist", reflect.TypeOf((*MockIRepo)(nil).DoesAccountExist), arg0)
 }
 
+// DoesFollowerExist mocks base method.
+func (m *MockIRepo) DoesFollowerExist(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoesFollowerExist", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DoesFollowerExist indicates an expected call of DoesFollowerExist.
+func (mr *MockIRepoMockRecorder) DoesFollowerExist(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoesFollowerExist", reflect.TypeOf((*MockIRepo)(nil).DoesFollowerExist), arg0)
+}
+
 // GetAccount mocks base method.
 func (m *MockIRepo) GetAccount(arg0 string) (*dal.Account, error) {
 	m.ctrl.T.Helper()
@@ -184,20 +301,19 @@ func (mr *MockIRepoMockRecorder) GetAccount(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockIRepo)(nil).GetAccount), arg0)
 }
 
-// GetAccountToCheck mocks base method.
-func (m *MockIRepo) GetAccountToCheck(arg0 time.Time) (*dal.Account, int, error) {
+// GetAccountClaim mocks base method.
+func (m *MockIRepo) GetAccountClaim(arg0 int, arg1 string) (*dal.AccountClaim, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountToCheck", arg0)
-	ret0, _ := ret[0].(*dal.Account)
-	ret1, _ := ret[1].(int)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetAccountClaim", arg0, arg1)
+	ret0, _ := ret[0].(*dal.AccountClaim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// GetAccountToCheck indicates an expected call of GetAccountToCheck.
-func (mr *MockIRepoMockRecorder) GetAccountToCheck(arg0 any) *gomock.Call {
+// GetAccountClaim indicates an expected call of GetAccountClaim.
+func (mr *MockIRepoMockRecorder) GetAccountClaim(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountToCheck", reflect.TypeOf((*MockIRepo)(nil).GetAccountToCheck), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountClaim", reflect.TypeOf((*MockIRepo)(nil).GetAccountClaim), arg0, arg1)
 }
 
 // GetAccountsPage mocks base method.
@@ -216,6 +332,52 @@ func (mr *MockIRepoMockRecorder) GetAccountsPage(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountsPage", reflect.TypeOf((*MockIRepo)(nil).GetAccountsPage), arg0, arg1)
 }
 
+// GetBlockedDomains mocks base method.
+func (m *MockIRepo) GetBlockedDomains() ([]*dal.BlockedDomain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetBlockedDomains")
+	ret0, _ := ret[0].([]*dal.BlockedDomain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBlockedDomains indicates an expected call of GetBlockedDomains.
+func (mr *MockIRepoMockRecorder) GetBlockedDomains() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBlockedDomains", reflect.TypeOf((*MockIRepo)(nil).GetBlockedDomains))
+}
+
+// GetDeletedToot mocks base method.
+func (m *MockIRepo) GetDeletedToot(arg0 string) (time.Time, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeletedToot", arg0)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetDeletedToot indicates an expected call of GetDeletedToot.
+func (mr *MockIRepoMockRecorder) GetDeletedToot(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeletedToot", reflect.TypeOf((*MockIRepo)(nil).GetDeletedToot), arg0)
+}
+
+// GetFailingAccounts mocks base method.
+func (m *MockIRepo) GetFailingAccounts(arg0 int) ([]*dal.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFailingAccounts", arg0)
+	ret0, _ := ret[0].([]*dal.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFailingAccounts indicates an expected call of GetFailingAccounts.
+func (mr *MockIRepoMockRecorder) GetFailingAccounts(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFailingAccounts", reflect.TypeOf((*MockIRepo)(nil).GetFailingAccounts), arg0)
+}
+
 // GetFeedFollowerCount mocks base method.
 func (m *MockIRepo) GetFeedFollowerCount() (int, error) {
 	m.ctrl.T.Helper()
@@ -246,6 +408,21 @@ func (mr *MockIRepoMockRecorder) GetFeedLastUpdated(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeedLastUpdated", reflect.TypeOf((*MockIRepo)(nil).GetFeedLastUpdated), arg0)
 }
 
+// GetFeedPostsSince mocks base method.
+func (m *MockIRepo) GetFeedPostsSince(arg0 int, arg1 time.Time) ([]*dal.FeedPost, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeedPostsSince", arg0, arg1)
+	ret0, _ := ret[0].([]*dal.FeedPost)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeedPostsSince indicates an expected call of GetFeedPostsSince.
+func (mr *MockIRepoMockRecorder) GetFeedPostsSince(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeedPostsSince", reflect.TypeOf((*MockIRepo)(nil).GetFeedPostsSince), arg0, arg1)
+}
+
 // GetFollowerCount mocks base method.
 func (m *MockIRepo) GetFollowerCount(arg0 string, arg1 bool) (uint, error) {
 	m.ctrl.T.Helper()
@@ -291,6 +468,21 @@ func (mr *MockIRepoMockRecorder) GetFollowersByUser(arg0, arg1 any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFollowersByUser", reflect.TypeOf((*MockIRepo)(nil).GetFollowersByUser), arg0, arg1)
 }
 
+// GetInboxHealth mocks base method.
+func (m *MockIRepo) GetInboxHealth(arg0 string) (*dal.InboxHealth, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInboxHealth", arg0)
+	ret0, _ := ret[0].(*dal.InboxHealth)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInboxHealth indicates an expected call of GetInboxHealth.
+func (mr *MockIRepoMockRecorder) GetInboxHealth(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInboxHealth", reflect.TypeOf((*MockIRepo)(nil).GetInboxHealth), arg0)
+}
+
 // GetNextId mocks base method.
 func (m *MockIRepo) GetNextId() uint64 {
 	m.ctrl.T.Helper()
@@ -321,18 +513,18 @@ func (mr *MockIRepoMockRecorder) GetPostCount(arg0 any) *gomock.Call {
 }
 
 // GetPostsPage mocks base method.
-func (m *MockIRepo) GetPostsPage(arg0, arg1, arg2 int) ([]*dal.FeedPost, error) {
+func (m *MockIRepo) GetPostsPage(arg0 int, arg1 string, arg2, arg3 int) ([]*dal.FeedPost, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetPostsPage", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "GetPostsPage", arg0, arg1, arg2, arg3)
 	ret0, _ := ret[0].([]*dal.FeedPost)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetPostsPage indicates an expected call of GetPostsPage.
-func (mr *MockIRepoMockRecorder) GetPostsPage(arg0, arg1, arg2 any) *gomock.Call {
+func (mr *MockIRepoMockRecorder) GetPostsPage(arg0, arg1, arg2, arg3 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsPage", reflect.TypeOf((*MockIRepo)(nil).GetPostsPage), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostsPage", reflect.TypeOf((*MockIRepo)(nil).GetPostsPage), arg0, arg1, arg2, arg3)
 }
 
 // GetPrivKey mocks base method.
@@ -350,6 +542,21 @@ func (mr *MockIRepoMockRecorder) GetPrivKey(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrivKey", reflect.TypeOf((*MockIRepo)(nil).GetPrivKey), arg0)
 }
 
+// GetSuspendedInboxCount mocks base method.
+func (m *MockIRepo) GetSuspendedInboxCount() (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSuspendedInboxCount")
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSuspendedInboxCount indicates an expected call of GetSuspendedInboxCount.
+func (mr *MockIRepoMockRecorder) GetSuspendedInboxCount() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSuspendedInboxCount", reflect.TypeOf((*MockIRepo)(nil).GetSuspendedInboxCount))
+}
+
 // GetToot mocks base method.
 func (m *MockIRepo) GetToot(arg0 string) (*dal.Toot, error) {
 	m.ctrl.T.Helper()
@@ -365,6 +572,36 @@ func (mr *MockIRepoMockRecorder) GetToot(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToot", reflect.TypeOf((*MockIRepo)(nil).GetToot), arg0)
 }
 
+// GetTootAttachmentUrls mocks base method.
+func (m *MockIRepo) GetTootAttachmentUrls(arg0 string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTootAttachmentUrls", arg0)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTootAttachmentUrls indicates an expected call of GetTootAttachmentUrls.
+func (mr *MockIRepoMockRecorder) GetTootAttachmentUrls(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTootAttachmentUrls", reflect.TypeOf((*MockIRepo)(nil).GetTootAttachmentUrls), arg0)
+}
+
+// GetTootCount mocks base method.
+func (m *MockIRepo) GetTootCount(arg0 int) (uint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTootCount", arg0)
+	ret0, _ := ret[0].(uint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTootCount indicates an expected call of GetTootCount.
+func (mr *MockIRepoMockRecorder) GetTootCount(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTootCount", reflect.TypeOf((*MockIRepo)(nil).GetTootCount), arg0)
+}
+
 // GetTootExtracts mocks base method.
 func (m *MockIRepo) GetTootExtracts(arg0 int) ([]*dal.Toot, error) {
 	m.ctrl.T.Helper()
@@ -381,19 +618,35 @@ func (mr *MockIRepoMockRecorder) GetTootExtracts(arg0 any) *gomock.Call {
 }
 
 // GetTootQueueItems mocks base method.
-func (m *MockIRepo) GetTootQueueItems(arg0, arg1 int) ([]*dal.TootQueueItem, int, error) {
+func (m *MockIRepo) GetTootQueueItems(arg0, arg1 int, arg2 time.Time) ([]*dal.TootQueueItem, map[string]int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetTootQueueItems", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetTootQueueItems", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]*dal.TootQueueItem)
-	ret1, _ := ret[1].(int)
+	ret1, _ := ret[1].(map[string]int)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
 
 // GetTootQueueItems indicates an expected call of GetTootQueueItems.
-func (mr *MockIRepoMockRecorder) GetTootQueueItems(arg0, arg1 any) *gomock.Call {
+func (mr *MockIRepoMockRecorder) GetTootQueueItems(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTootQueueItems", reflect.TypeOf((*MockIRepo)(nil).GetTootQueueItems), arg0, arg1, arg2)
+}
+
+// GetTootsPage mocks base method.
+func (m *MockIRepo) GetTootsPage(arg0, arg1, arg2 int) ([]*dal.Toot, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTootsPage", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]*dal.Toot)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetTootsPage indicates an expected call of GetTootsPage.
+func (mr *MockIRepoMockRecorder) GetTootsPage(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTootQueueItems", reflect.TypeOf((*MockIRepo)(nil).GetTootQueueItems), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTootsPage", reflect.TypeOf((*MockIRepo)(nil).GetTootsPage), arg0, arg1, arg2)
 }
 
 // GetTotalPostCount mocks base method.
@@ -411,6 +664,36 @@ func (mr *MockIRepoMockRecorder) GetTotalPostCount() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalPostCount", reflect.TypeOf((*MockIRepo)(nil).GetTotalPostCount))
 }
 
+// GetVerifiedAccountClaims mocks base method.
+func (m *MockIRepo) GetVerifiedAccountClaims() ([]*dal.AccountClaim, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerifiedAccountClaims")
+	ret0, _ := ret[0].([]*dal.AccountClaim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerifiedAccountClaims indicates an expected call of GetVerifiedAccountClaims.
+func (mr *MockIRepoMockRecorder) GetVerifiedAccountClaims() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerifiedAccountClaims", reflect.TypeOf((*MockIRepo)(nil).GetVerifiedAccountClaims))
+}
+
+// GetWebSubSecret mocks base method.
+func (m *MockIRepo) GetWebSubSecret(arg0 int) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebSubSecret", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebSubSecret indicates an expected call of GetWebSubSecret.
+func (mr *MockIRepoMockRecorder) GetWebSubSecret(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebSubSecret", reflect.TypeOf((*MockIRepo)(nil).GetWebSubSecret), arg0)
+}
+
 // InitUpdateDb mocks base method.
 func (m *MockIRepo) InitUpdateDb() {
 	m.ctrl.T.Helper()
@@ -438,12 +721,28 @@ func (mr *MockIRepoMockRecorder) MarkActivityHandled(arg0, arg1 any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkActivityHandled", reflect.TypeOf((*MockIRepo)(nil).MarkActivityHandled), arg0, arg1)
 }
 
+// MoveFollower mocks base method.
+func (m *MockIRepo) MoveFollower(arg0 string, arg1 *dal.FollowerInfo) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveFollower", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MoveFollower indicates an expected call of MoveFollower.
+func (mr *MockIRepoMockRecorder) MoveFollower(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveFollower", reflect.TypeOf((*MockIRepo)(nil).MoveFollower), arg0, arg1)
+}
+
 // PurgePostsAndToots mocks base method.
-func (m *MockIRepo) PurgePostsAndToots(arg0 int, arg1 time.Time) error {
+func (m *MockIRepo) PurgePostsAndToots(arg0 int, arg1 time.Time) ([]string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PurgePostsAndToots", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // PurgePostsAndToots indicates an expected call of PurgePostsAndToots.
@@ -452,6 +751,65 @@ func (mr *MockIRepoMockRecorder) PurgePostsAndToots(arg0, arg1 any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgePostsAndToots", reflect.TypeOf((*MockIRepo)(nil).PurgePostsAndToots), arg0, arg1)
 }
 
+// RecordInboxFailure mocks base method.
+func (m *MockIRepo) RecordInboxFailure(arg0 string, arg1 time.Time, arg2 string) (*dal.InboxHealth, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordInboxFailure", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*dal.InboxHealth)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordInboxFailure indicates an expected call of RecordInboxFailure.
+func (mr *MockIRepoMockRecorder) RecordInboxFailure(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordInboxFailure", reflect.TypeOf((*MockIRepo)(nil).RecordInboxFailure), arg0, arg1, arg2)
+}
+
+// RecordInboxSuccess mocks base method.
+func (m *MockIRepo) RecordInboxSuccess(arg0 string, arg1 time.Time) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordInboxSuccess", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordInboxSuccess indicates an expected call of RecordInboxSuccess.
+func (mr *MockIRepoMockRecorder) RecordInboxSuccess(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordInboxSuccess", reflect.TypeOf((*MockIRepo)(nil).RecordInboxSuccess), arg0, arg1)
+}
+
+// RekeyFeedPost mocks base method.
+func (m *MockIRepo) RekeyFeedPost(arg0 int, arg1, arg2 int64, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RekeyFeedPost", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RekeyFeedPost indicates an expected call of RekeyFeedPost.
+func (mr *MockIRepoMockRecorder) RekeyFeedPost(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RekeyFeedPost", reflect.TypeOf((*MockIRepo)(nil).RekeyFeedPost), arg0, arg1, arg2, arg3)
+}
+
+// RemoveBlockedDomain mocks base method.
+func (m *MockIRepo) RemoveBlockedDomain(arg0 string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveBlockedDomain", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveBlockedDomain indicates an expected call of RemoveBlockedDomain.
+func (mr *MockIRepoMockRecorder) RemoveBlockedDomain(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveBlockedDomain", reflect.TypeOf((*MockIRepo)(nil).RemoveBlockedDomain), arg0)
+}
+
 // RemoveFollower mocks base method.
 func (m *MockIRepo) RemoveFollower(arg0, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -466,6 +824,218 @@ func (mr *MockIRepoMockRecorder) RemoveFollower(arg0, arg1 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFollower", reflect.TypeOf((*MockIRepo)(nil).RemoveFollower), arg0, arg1)
 }
 
+// RemoveFollowerEverywhere mocks base method.
+func (m *MockIRepo) RemoveFollowerEverywhere(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFollowerEverywhere", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveFollowerEverywhere indicates an expected call of RemoveFollowerEverywhere.
+func (mr *MockIRepoMockRecorder) RemoveFollowerEverywhere(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFollowerEverywhere", reflect.TypeOf((*MockIRepo)(nil).RemoveFollowerEverywhere), arg0)
+}
+
+// RemoveFollowersOnDomain mocks base method.
+func (m *MockIRepo) RemoveFollowersOnDomain(arg0 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFollowersOnDomain", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveFollowersOnDomain indicates an expected call of RemoveFollowersOnDomain.
+func (mr *MockIRepoMockRecorder) RemoveFollowersOnDomain(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFollowersOnDomain", reflect.TypeOf((*MockIRepo)(nil).RemoveFollowersOnDomain), arg0)
+}
+
+// ResumeAccountPolling mocks base method.
+func (m *MockIRepo) ResumeAccountPolling(arg0 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeAccountPolling", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeAccountPolling indicates an expected call of ResumeAccountPolling.
+func (mr *MockIRepoMockRecorder) ResumeAccountPolling(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeAccountPolling", reflect.TypeOf((*MockIRepo)(nil).ResumeAccountPolling), arg0)
+}
+
+// RetryTootQueueItem mocks base method.
+func (m *MockIRepo) RetryTootQueueItem(arg0, arg1 int, arg2 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryTootQueueItem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryTootQueueItem indicates an expected call of RetryTootQueueItem.
+func (mr *MockIRepoMockRecorder) RetryTootQueueItem(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryTootQueueItem", reflect.TypeOf((*MockIRepo)(nil).RetryTootQueueItem), arg0, arg1, arg2)
+}
+
+// SetAccountCheckFailures mocks base method.
+func (m *MockIRepo) SetAccountCheckFailures(arg0, arg1 int, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountCheckFailures", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountCheckFailures indicates an expected call of SetAccountCheckFailures.
+func (mr *MockIRepoMockRecorder) SetAccountCheckFailures(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountCheckFailures", reflect.TypeOf((*MockIRepo)(nil).SetAccountCheckFailures), arg0, arg1, arg2)
+}
+
+// SetAccountClaimDeletionRequested mocks base method.
+func (m *MockIRepo) SetAccountClaimDeletionRequested(arg0 string, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountClaimDeletionRequested", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountClaimDeletionRequested indicates an expected call of SetAccountClaimDeletionRequested.
+func (mr *MockIRepoMockRecorder) SetAccountClaimDeletionRequested(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountClaimDeletionRequested", reflect.TypeOf((*MockIRepo)(nil).SetAccountClaimDeletionRequested), arg0, arg1)
+}
+
+// SetAccountClaimVerified mocks base method.
+func (m *MockIRepo) SetAccountClaimVerified(arg0 string, arg1 time.Time, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountClaimVerified", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountClaimVerified indicates an expected call of SetAccountClaimVerified.
+func (mr *MockIRepoMockRecorder) SetAccountClaimVerified(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountClaimVerified", reflect.TypeOf((*MockIRepo)(nil).SetAccountClaimVerified), arg0, arg1, arg2)
+}
+
+// SetAccountClaimedProfile mocks base method.
+func (m *MockIRepo) SetAccountClaimedProfile(arg0 int, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountClaimedProfile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountClaimedProfile indicates an expected call of SetAccountClaimedProfile.
+func (mr *MockIRepoMockRecorder) SetAccountClaimedProfile(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountClaimedProfile", reflect.TypeOf((*MockIRepo)(nil).SetAccountClaimedProfile), arg0, arg1, arg2, arg3)
+}
+
+// SetAccountFloodLimit mocks base method.
+func (m *MockIRepo) SetAccountFloodLimit(arg0, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountFloodLimit", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountFloodLimit indicates an expected call of SetAccountFloodLimit.
+func (mr *MockIRepoMockRecorder) SetAccountFloodLimit(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountFloodLimit", reflect.TypeOf((*MockIRepo)(nil).SetAccountFloodLimit), arg0, arg1)
+}
+
+// SetAccountImages mocks base method.
+func (m *MockIRepo) SetAccountImages(arg0 int, arg1, arg2 string, arg3 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountImages", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountImages indicates an expected call of SetAccountImages.
+func (mr *MockIRepoMockRecorder) SetAccountImages(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountImages", reflect.TypeOf((*MockIRepo)(nil).SetAccountImages), arg0, arg1, arg2, arg3)
+}
+
+// SetAccountNoHashtags mocks base method.
+func (m *MockIRepo) SetAccountNoHashtags(arg0 int, arg1 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountNoHashtags", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountNoHashtags indicates an expected call of SetAccountNoHashtags.
+func (mr *MockIRepoMockRecorder) SetAccountNoHashtags(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountNoHashtags", reflect.TypeOf((*MockIRepo)(nil).SetAccountNoHashtags), arg0, arg1)
+}
+
+// SetAccountOptOutChecked mocks base method.
+func (m *MockIRepo) SetAccountOptOutChecked(arg0 int, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountOptOutChecked", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountOptOutChecked indicates an expected call of SetAccountOptOutChecked.
+func (mr *MockIRepoMockRecorder) SetAccountOptOutChecked(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountOptOutChecked", reflect.TypeOf((*MockIRepo)(nil).SetAccountOptOutChecked), arg0, arg1)
+}
+
+// SetAccountPollStatus mocks base method.
+func (m *MockIRepo) SetAccountPollStatus(arg0 int, arg1 dal.PollStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountPollStatus", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountPollStatus indicates an expected call of SetAccountPollStatus.
+func (mr *MockIRepoMockRecorder) SetAccountPollStatus(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountPollStatus", reflect.TypeOf((*MockIRepo)(nil).SetAccountPollStatus), arg0, arg1)
+}
+
+// SetAccountWebSub mocks base method.
+func (m *MockIRepo) SetAccountWebSub(arg0 int, arg1, arg2, arg3 string, arg4 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountWebSub", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountWebSub indicates an expected call of SetAccountWebSub.
+func (mr *MockIRepoMockRecorder) SetAccountWebSub(arg0, arg1, arg2, arg3, arg4 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountWebSub", reflect.TypeOf((*MockIRepo)(nil).SetAccountWebSub), arg0, arg1, arg2, arg3, arg4)
+}
+
+// SetAccountWebSubExpires mocks base method.
+func (m *MockIRepo) SetAccountWebSubExpires(arg0 int, arg1 time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountWebSubExpires", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAccountWebSubExpires indicates an expected call of SetAccountWebSubExpires.
+func (mr *MockIRepoMockRecorder) SetAccountWebSubExpires(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountWebSubExpires", reflect.TypeOf((*MockIRepo)(nil).SetAccountWebSubExpires), arg0, arg1)
+}
+
 // SetFollowerApproveStatus mocks base method.
 func (m *MockIRepo) SetFollowerApproveStatus(arg0, arg1 string, arg2 int) error {
 	m.ctrl.T.Helper()
@@ -480,6 +1050,20 @@ func (mr *MockIRepoMockRecorder) SetFollowerApproveStatus(arg0, arg1, arg2 any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFollowerApproveStatus", reflect.TypeOf((*MockIRepo)(nil).SetFollowerApproveStatus), arg0, arg1, arg2)
 }
 
+// SuspendInbox mocks base method.
+func (m *MockIRepo) SuspendInbox(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SuspendInbox", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SuspendInbox indicates an expected call of SuspendInbox.
+func (mr *MockIRepoMockRecorder) SuspendInbox(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuspendInbox", reflect.TypeOf((*MockIRepo)(nil).SuspendInbox), arg0)
+}
+
 // UpdateAccountFeedTimes mocks base method.
 func (m *MockIRepo) UpdateAccountFeedTimes(arg0 int, arg1, arg2 time.Time) error {
 	m.ctrl.T.Helper()
@@ -494,6 +1078,64 @@ func (mr *MockIRepoMockRecorder) UpdateAccountFeedTimes(arg0, arg1, arg2 any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountFeedTimes", reflect.TypeOf((*MockIRepo)(nil).UpdateAccountFeedTimes), arg0, arg1, arg2)
 }
 
+// UpdateAccountFeedUrl mocks base method.
+func (m *MockIRepo) UpdateAccountFeedUrl(arg0 int, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountFeedUrl", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAccountFeedUrl indicates an expected call of UpdateAccountFeedUrl.
+func (mr *MockIRepoMockRecorder) UpdateAccountFeedUrl(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountFeedUrl", reflect.TypeOf((*MockIRepo)(nil).UpdateAccountFeedUrl), arg0, arg1)
+}
+
+// UpdateAccountFeedValidators mocks base method.
+func (m *MockIRepo) UpdateAccountFeedValidators(arg0 int, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountFeedValidators", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAccountFeedValidators indicates an expected call of UpdateAccountFeedValidators.
+func (mr *MockIRepoMockRecorder) UpdateAccountFeedValidators(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountFeedValidators", reflect.TypeOf((*MockIRepo)(nil).UpdateAccountFeedValidators), arg0, arg1, arg2)
+}
+
+// UpdateFeedPostIfChanged mocks base method.
+func (m *MockIRepo) UpdateFeedPostIfChanged(arg0 int, arg1 *dal.FeedPost) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateFeedPostIfChanged", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateFeedPostIfChanged indicates an expected call of UpdateFeedPostIfChanged.
+func (mr *MockIRepoMockRecorder) UpdateFeedPostIfChanged(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFeedPostIfChanged", reflect.TypeOf((*MockIRepo)(nil).UpdateFeedPostIfChanged), arg0, arg1)
+}
+
+// UpdateTootContent mocks base method.
+func (m *MockIRepo) UpdateTootContent(arg0 int, arg1 int64, arg2 string, arg3 []string) (*dal.Toot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTootContent", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*dal.Toot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateTootContent indicates an expected call of UpdateTootContent.
+func (mr *MockIRepoMockRecorder) UpdateTootContent(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTootContent", reflect.TypeOf((*MockIRepo)(nil).UpdateTootContent), arg0, arg1, arg2, arg3)
+}
+
 // Vacuum mocks base method.
 func (m *MockIRepo) Vacuum() error {
 	m.ctrl.T.Helper()
@@ -53,6 +53,36 @@ func (mr *MockIUserDirectoryMockRecorder) AcceptFollower(arg0, arg1, arg2, arg3
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptFollower", reflect.TypeOf((*MockIUserDirectory)(nil).AcceptFollower), arg0, arg1, arg2, arg3)
 }
 
+// GetFollowersOnHost mocks base method.
+func (m *MockIUserDirectory) GetFollowersOnHost(arg0, arg1 string) (*dto.OrderedCollection, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFollowersOnHost", arg0, arg1)
+	ret0, _ := ret[0].(*dto.OrderedCollection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFollowersOnHost indicates an expected call of GetFollowersOnHost.
+func (mr *MockIUserDirectoryMockRecorder) GetFollowersOnHost(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFollowersOnHost", reflect.TypeOf((*MockIUserDirectory)(nil).GetFollowersOnHost), arg0, arg1)
+}
+
+// GetFollowersPage mocks base method.
+func (m *MockIUserDirectory) GetFollowersPage(arg0 string, arg1 int) (*dto.OrderedCollectionPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFollowersPage", arg0, arg1)
+	ret0, _ := ret[0].(*dto.OrderedCollectionPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFollowersPage indicates an expected call of GetFollowersPage.
+func (mr *MockIUserDirectoryMockRecorder) GetFollowersPage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFollowersPage", reflect.TypeOf((*MockIUserDirectory)(nil).GetFollowersPage), arg0, arg1)
+}
+
 // GetFollowersSummary mocks base method.
 func (m *MockIUserDirectory) GetFollowersSummary(arg0 string) *dto.OrderedListSummary {
 	m.ctrl.T.Helper()
@@ -81,12 +111,28 @@ func (mr *MockIUserDirectoryMockRecorder) GetFollowingSummary(arg0 any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFollowingSummary", reflect.TypeOf((*MockIUserDirectory)(nil).GetFollowingSummary), arg0)
 }
 
+// GetOutboxPage mocks base method.
+func (m *MockIUserDirectory) GetOutboxPage(arg0 string, arg1 int) (*dto.OrderedCollectionPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOutboxPage", arg0, arg1)
+	ret0, _ := ret[0].(*dto.OrderedCollectionPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOutboxPage indicates an expected call of GetOutboxPage.
+func (mr *MockIUserDirectoryMockRecorder) GetOutboxPage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOutboxPage", reflect.TypeOf((*MockIUserDirectory)(nil).GetOutboxPage), arg0, arg1)
+}
+
 // GetOutboxSummary mocks base method.
-func (m *MockIUserDirectory) GetOutboxSummary(arg0 string) *dto.OrderedListSummary {
+func (m *MockIUserDirectory) GetOutboxSummary(arg0 string) (*dto.OrderedListSummary, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetOutboxSummary", arg0)
 	ret0, _ := ret[0].(*dto.OrderedListSummary)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetOutboxSummary indicates an expected call of GetOutboxSummary.
@@ -110,12 +156,13 @@ func (mr *MockIUserDirectoryMockRecorder) GetUserInfo(arg0 any) *gomock.Call {
 }
 
 // GetUserStatus mocks base method.
-func (m *MockIUserDirectory) GetUserStatus(arg0, arg1 string) (*dto.Note, error) {
+func (m *MockIUserDirectory) GetUserStatus(arg0, arg1 string) (*dto.Note, *dto.Tombstone, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetUserStatus", arg0, arg1)
 	ret0, _ := ret[0].(*dto.Note)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(*dto.Tombstone)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // GetUserStatus indicates an expected call of GetUserStatus.
@@ -137,3 +184,17 @@ func (mr *MockIUserDirectoryMockRecorder) GetWebfinger(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebfinger", reflect.TypeOf((*MockIUserDirectory)(nil).GetWebfinger), arg0)
 }
+
+// RejectFollower mocks base method.
+func (m *MockIUserDirectory) RejectFollower(arg0, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RejectFollower", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RejectFollower indicates an expected call of RejectFollower.
+func (mr *MockIUserDirectoryMockRecorder) RejectFollower(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RejectFollower", reflect.TypeOf((*MockIUserDirectory)(nil).RejectFollower), arg0, arg1, arg2, arg3)
+}
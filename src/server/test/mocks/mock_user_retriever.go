@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: rss_parrot/logic (interfaces: IUserRetriever)
+//
+// Generated by this command:
+//
+//	mockgen --build_flags=--mod=mod -destination ../test/mocks/mock_user_retriever.go -package mocks rss_parrot/logic IUserRetriever
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+	dto "rss_parrot/dto"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIUserRetriever is a mock of IUserRetriever interface.
+type MockIUserRetriever struct {
+	ctrl     *gomock.Controller
+	recorder *MockIUserRetrieverMockRecorder
+}
+
+// MockIUserRetrieverMockRecorder is the mock recorder for MockIUserRetriever.
+type MockIUserRetrieverMockRecorder struct {
+	mock *MockIUserRetriever
+}
+
+// NewMockIUserRetriever creates a new mock instance.
+func NewMockIUserRetriever(ctrl *gomock.Controller) *MockIUserRetriever {
+	mock := &MockIUserRetriever{ctrl: ctrl}
+	mock.recorder = &MockIUserRetrieverMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIUserRetriever) EXPECT() *MockIUserRetrieverMockRecorder {
+	return m.recorder
+}
+
+// Retrieve mocks base method.
+func (m *MockIUserRetriever) Retrieve(arg0 string) (*dto.UserInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Retrieve", arg0)
+	ret0, _ := ret[0].(*dto.UserInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Retrieve indicates an expected call of Retrieve.
+func (mr *MockIUserRetrieverMockRecorder) Retrieve(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retrieve", reflect.TypeOf((*MockIUserRetriever)(nil).Retrieve), arg0)
+}
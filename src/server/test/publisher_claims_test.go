@@ -0,0 +1,257 @@
+package test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"rss_parrot/dal"
+	"rss_parrot/logic"
+	"rss_parrot/server"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"rss_parrot/texts"
+	"strings"
+	"testing"
+)
+
+type claimsHarness struct {
+	mockRepo     *mocks.MockIRepo
+	mockResolver *mocks.MockIDnsResolver
+	claims       logic.IPublisherClaims
+}
+
+func setupClaimsTest(t *testing.T) (*gomock.Controller, *claimsHarness) {
+
+	ctrl := gomock.NewController(t)
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockUserAgent := mocks.NewMockIUserAgent(ctrl)
+	setupDummyLogger(mockLogger)
+	mockUserAgent.EXPECT().AddUserAgent(gomock.Any()).AnyTimes()
+	h := &claimsHarness{
+		mockRepo:     mocks.NewMockIRepo(ctrl),
+		mockResolver: mocks.NewMockIDnsResolver(ctrl),
+	}
+	cfg := &shared.Config{Host: "parrot.com", Birb: &shared.UserInfo{User: "birb"}}
+	h.claims = logic.NewPublisherClaims(cfg, mockLogger, mockUserAgent, h.mockRepo, h.mockResolver)
+	return ctrl, h
+}
+
+// Starts a claim and returns the token and the secret, along with the claim as it was stored
+func startClaim(t *testing.T, h *claimsHarness, acct *dal.Account) (string, string, *dal.AccountClaim) {
+
+	var stored *dal.AccountClaim
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq(acct.Handle)).Return(acct, nil).AnyTimes()
+	h.mockRepo.EXPECT().DeleteUnverifiedAccountClaims(gomock.Any()).Return(nil).Times(1)
+	// Only a few claims stay open per account
+	h.mockRepo.EXPECT().DeleteOlderUnverifiedAccountClaims(gomock.Eq(acct.Id), gomock.Eq(2)).Return(nil).Times(1)
+	h.mockRepo.EXPECT().AddAccountClaim(gomock.Any()).
+		DoAndReturn(func(claim *dal.AccountClaim) error {
+			stored = claim
+			return nil
+		}).Times(1)
+	token, secret, err := h.claims.Start(acct.Handle)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, secret)
+	assert.Equal(t, token, stored.Token)
+	assert.Equal(t, acct.Id, stored.AccountId)
+	// Only a hash of the secret is kept
+	assert.NotContains(t, stored.SecretHash, secret)
+	return token, secret, stored
+}
+
+func test_Publisher_Claims_Verify(t *testing.T, siteMeta, claimFile string, txtRecords map[string][]string, expectedBy string) {
+
+	ctrl, h := setupClaimsTest(t)
+	defer ctrl.Finish()
+
+	var token string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blog/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Replace(optOutSiteHtml, "%META%", strings.ReplaceAll(siteMeta, "TOKEN", token), 1)))
+	})
+	mux.HandleFunc(logic.ClaimWellKnownPath, func(w http.ResponseWriter, r *http.Request) {
+		if claimFile == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(strings.ReplaceAll(claimFile, "TOKEN", token)))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	acct := &dal.Account{Id: 12, Handle: "site.com", SiteUrl: srv.URL + "/blog/"}
+	token, secret, stored := startClaim(t, h, acct)
+
+	h.mockResolver.EXPECT().LookupTXT(gomock.Any()).
+		DoAndReturn(func(name string) ([]string, error) {
+			records, found := txtRecords[name]
+			if !found {
+				return nil, errors.New("no such host")
+			}
+			res := make([]string, 0, len(records))
+			for _, record := range records {
+				res = append(res, strings.ReplaceAll(record, "TOKEN", token))
+			}
+			return res, nil
+		}).AnyTimes()
+	h.mockRepo.EXPECT().GetAccountClaim(gomock.Eq(acct.Id), gomock.Eq(stored.SecretHash)).Return(stored, nil).AnyTimes()
+	h.mockRepo.EXPECT().GetAccountClaim(gomock.Eq(acct.Id), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	// Wrong secret gets nowhere
+	_, err := h.claims.Verify(acct.Handle, "not-the-secret")
+	assert.ErrorIs(t, err, logic.ErrClaimNotFound)
+
+	if expectedBy == "" {
+		_, err = h.claims.Verify(acct.Handle, secret)
+		assert.ErrorIs(t, err, logic.ErrClaimTokenMissing)
+		return
+	}
+	h.mockRepo.EXPECT().SetAccountClaimVerified(gomock.Eq(token), gomock.Any(), gomock.Eq(expectedBy)).Return(nil).Times(1)
+	verifiedBy, err := h.claims.Verify(acct.Handle, secret)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedBy, verifiedBy)
+}
+
+func Test_Publisher_Claims_Verify_Meta(t *testing.T) {
+	test_Publisher_Claims_Verify(t, `<meta name="fediverse:parrot-claim" content="TOKEN">`, "", nil, "meta")
+}
+
+func Test_Publisher_Claims_Verify_File(t *testing.T) {
+	test_Publisher_Claims_Verify(t, "", "some-other-token\nTOKEN\n", nil, "file")
+}
+
+func Test_Publisher_Claims_Verify_Dns(t *testing.T) {
+	txtRecords := map[string][]string{
+		"_rss-parrot.127.0.0.1": {"v=spf1 -all", "rss-parrot-claim=TOKEN"},
+	}
+	test_Publisher_Claims_Verify(t, "", "", txtRecords, "dns")
+}
+
+func Test_Publisher_Claims_Verify_Token_Missing(t *testing.T) {
+	txtRecords := map[string][]string{
+		"_rss-parrot.127.0.0.1": {"rss-parrot-claim=wrong"},
+	}
+	test_Publisher_Claims_Verify(t, `<meta name="fediverse:parrot-claim" content="wrong">`, "wrong\n", txtRecords, "")
+}
+
+func Test_Publisher_Claims_Birb_And_Missing_Accounts_Refused(t *testing.T) {
+
+	ctrl, h := setupClaimsTest(t)
+	defer ctrl.Finish()
+
+	h.mockRepo.EXPECT().GetAccount(gomock.Eq("nope.com")).Return(nil, nil).Times(1)
+	h.mockRepo.EXPECT().AddAccountClaim(gomock.Any()).Times(0)
+
+	_, _, err := h.claims.Start("birb")
+	assert.ErrorIs(t, err, logic.ErrClaimNotFound)
+	_, _, err = h.claims.Start("nope.com")
+	assert.ErrorIs(t, err, logic.ErrClaimNotFound)
+}
+
+func Test_Publisher_Claims_Profile_And_Deletion_Need_Verified_Claim(t *testing.T) {
+
+	ctrl, h := setupClaimsTest(t)
+	defer ctrl.Finish()
+
+	acct := &dal.Account{Id: 12, Handle: "site.com", SiteUrl: "https://site.com"}
+	token, secret, stored := startClaim(t, h, acct)
+	h.mockRepo.EXPECT().GetAccountClaim(gomock.Eq(acct.Id), gomock.Eq(stored.SecretHash)).Return(stored, nil).AnyTimes()
+	h.mockRepo.EXPECT().SetAccountClaimedProfile(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+	// Not verified yet
+	err := h.claims.SetProfile(acct.Handle, secret, &logic.ClaimedProfile{Name: "Site"})
+	assert.ErrorIs(t, err, logic.ErrClaimNotVerified)
+	err = h.claims.RequestDeletion(acct.Handle, secret)
+	assert.ErrorIs(t, err, logic.ErrClaimNotVerified)
+
+	stored.VerifiedBy = "meta"
+
+	// Invalid values are refused
+	var validationErr *logic.ClaimValidationError
+	err = h.claims.SetProfile(acct.Handle, secret, &logic.ClaimedProfile{Name: strings.Repeat("x", 101)})
+	assert.ErrorAs(t, err, &validationErr)
+	err = h.claims.SetProfile(acct.Handle, secret, &logic.ClaimedProfile{PublisherAccount: "http://social.site.com/@me"})
+	assert.ErrorAs(t, err, &validationErr)
+	err = h.claims.SetProfile(acct.Handle, secret, &logic.ClaimedProfile{PublisherAccount: "https://social.site.com/@me'><script>"})
+	assert.ErrorAs(t, err, &validationErr)
+
+	h.mockRepo.EXPECT().SetAccountClaimedProfile(gomock.Eq(acct.Id), gomock.Eq("Site's Name"), gomock.Eq("About us"),
+		gomock.Eq("https://social.site.com/@me")).Return(nil).Times(1)
+	err = h.claims.SetProfile(acct.Handle, secret, &logic.ClaimedProfile{
+		Name:             " Site's Name ",
+		Bio:              "About us\n",
+		PublisherAccount: "https://social.site.com/@me",
+	})
+	assert.Nil(t, err)
+
+	// Deletion request stops the feed right away
+	h.mockRepo.EXPECT().SetAccountClaimDeletionRequested(gomock.Eq(token), gomock.Any()).Return(nil).Times(1)
+	h.mockRepo.EXPECT().SetAccountPollStatus(gomock.Eq(acct.Id), gomock.Eq(dal.PollOptedOut)).Return(nil).Times(1)
+	err = h.claims.RequestDeletion(acct.Handle, secret)
+	assert.Nil(t, err)
+}
+
+func Test_User_Directory_Claimed_Profile(t *testing.T) {
+
+	// Real texts, so we see the bio the way followers do
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockRepo := mocks.NewMockIRepo(ctrl)
+	mockMedia := mocks.NewMockIMediaCache(ctrl)
+	setupDummyLogger(mockLogger)
+	setupEmptyMediaCache(mockMedia)
+	cfg := &shared.Config{Host: "parrot.com", Birb: &shared.UserInfo{User: "birb"}}
+	udir := logic.NewUserDirectory(cfg, mockLogger, mockRepo, mocks.NewMockIKeyStore(ctrl),
+		mocks.NewMockIActivitySender(ctrl), texts.NewTexts(), mockMedia)
+
+	acct := &dal.Account{
+		Id:               9,
+		Handle:           "some.site.com",
+		FeedName:         "Feed title",
+		FeedSummary:      "Feed description",
+		SiteUrl:          "https://some.site.com",
+		ClaimedName:      "Our Site",
+		ClaimedBio:       "Posts about <things> & stuff\nand more",
+		PublisherAccount: "https://social.site.com/@us",
+	}
+	mockRepo.EXPECT().GetAccount(gomock.Eq("some.site.com")).Return(acct, nil).Times(1)
+
+	ui := udir.GetUserInfo("some.site.com")
+	assert.Equal(t, shared.GetNameWithParrot("Our Site"), ui.Name)
+	assert.True(t, strings.HasSuffix(ui.Summary, "<p>Posts about &lt;things&gt; &amp; stuff<br>and more</p>"))
+	assert.Contains(t, ui.Summary, `<a href="https://parrot.com">RSS Parrot</a>`)
+	assert.NotContains(t, ui.Summary, "Feed description")
+	assert.Len(t, ui.Attachments, 2)
+	assert.Equal(t, "Publisher", ui.Attachments[1].Name)
+	assert.Contains(t, ui.Attachments[1].Value, "href='https://social.site.com/@us'")
+}
+
+func Test_Publisher_Claims_Starts_Rate_Limited_Per_Account(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cfg := &shared.Config{Host: "parrot.com"}
+	mockLogger := mocks.NewMockILogger(ctrl)
+	setupDummyLogger(mockLogger)
+	mockClaims := mocks.NewMockIPublisherClaims(ctrl)
+	mockClaims.EXPECT().Start(gomock.Eq("busy.site.com")).Return("token", "secret", nil).Times(5)
+	mockClaims.EXPECT().Start(gomock.Eq("other.site.com")).Return("token", "secret", nil).Times(1)
+	router := server.NewMux([]server.IHandlerGroup{server.NewClaimHandlerGroup(cfg, mockLogger, mockClaims)}, cfg, mockLogger)
+	post := func(path string) int {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+		return rr.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusCreated, post("/claim/busy.site.com"))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, post("/claim/busy.site.com"))
+	assert.Equal(t, http.StatusTooManyRequests, post("/claim/BUSY.site.com"))
+	// Other accounts can still be claimed
+	assert.Equal(t, http.StatusCreated, post("/claim/other.site.com"))
+}
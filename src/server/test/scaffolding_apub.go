@@ -84,3 +84,37 @@ func makeCreateNote(host, name, content string, to, cc []string, inReplyTo *stri
 	json = strings.ReplaceAll(json, "{{cc}}", listToStr(cc))
 	return []byte(json)
 }
+
+func makeDeleteActor(host, name string) []byte {
+	bytes, err := fs.ReadFile("data/delete-actor.json")
+	if err != nil {
+		panic(err)
+	}
+	json := string(bytes)
+	json = strings.ReplaceAll(json, "{{actor}}", fmt.Sprintf("https://%s/users/%s", host, name))
+	return []byte(json)
+}
+
+func makeMoveActor(host, name, target string) []byte {
+	bytes, err := fs.ReadFile("data/move-actor.json")
+	if err != nil {
+		panic(err)
+	}
+	json := string(bytes)
+	json = strings.ReplaceAll(json, "{{actor}}", fmt.Sprintf("https://%s/users/%s", host, name))
+	json = strings.ReplaceAll(json, "{{move-id}}", fmt.Sprintf("%d", getNextId()))
+	json = strings.ReplaceAll(json, "{{target}}", target)
+	return []byte(json)
+}
+
+func makeFollow(host, name, object string) []byte {
+	bytes, err := fs.ReadFile("data/follow.json")
+	if err != nil {
+		panic(err)
+	}
+	json := string(bytes)
+	json = strings.ReplaceAll(json, "{{actor}}", fmt.Sprintf("https://%s/users/%s", host, name))
+	json = strings.ReplaceAll(json, "{{follow-id}}", fmt.Sprintf("%d", getNextId()))
+	json = strings.ReplaceAll(json, "{{object}}", object)
+	return []byte(json)
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"embed"
 	"go.uber.org/mock/gomock"
+	"rss_parrot/logic"
 	"rss_parrot/test/mocks"
 	"strings"
 	"sync"
@@ -48,6 +49,14 @@ func setupFakeTexts(mockTexts *mocks.MockITexts) {
 		}).AnyTimes()
 }
 
+// Media cache that has nothing stored, so all images link to their originals
+func setupEmptyMediaCache(mockMedia *mocks.MockIMediaCache) {
+	mockMedia.EXPECT().GetUrl(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_, srcUrl string, _ logic.MediaKind) string {
+			return srcUrl
+		}).AnyTimes()
+}
+
 func fakeTextWithVals(id string, vals map[string]string) string {
 	res := id
 	for k, v := range vals {
@@ -60,6 +69,8 @@ func setupDummyMetrics(mockMetrics *mocks.MockIMetrics) {
 	mockMetrics.EXPECT().TotalFollowers(gomock.Any()).AnyTimes()
 	mockMetrics.EXPECT().TotalPosts(gomock.Any()).AnyTimes()
 	mockMetrics.EXPECT().PostsDeleted(gomock.Any()).AnyTimes()
+	mockMetrics.EXPECT().PostsDigested(gomock.Any()).AnyTimes()
+	mockMetrics.EXPECT().PostRepublished().AnyTimes()
 	mockMetrics.EXPECT().CheckableFeedCount(gomock.Any()).AnyTimes()
 }
 
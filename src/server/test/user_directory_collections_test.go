@@ -0,0 +1,211 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"testing"
+	"time"
+)
+
+func setupUserDirectoryTest(t *testing.T) (*gomock.Controller, *shared.Config, *mocks.MockIRepo, logic.IUserDirectory) {
+
+	ctrl := gomock.NewController(t)
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockRepo := mocks.NewMockIRepo(ctrl)
+	mockKeyStore := mocks.NewMockIKeyStore(ctrl)
+	mockSender := mocks.NewMockIActivitySender(ctrl)
+	mockTexts := mocks.NewMockITexts(ctrl)
+	mockMedia := mocks.NewMockIMediaCache(ctrl)
+	setupDummyLogger(mockLogger)
+	setupEmptyMediaCache(mockMedia)
+	setupFakeTexts(mockTexts)
+
+	cfg := &shared.Config{Host: "parrot.com", Birb: &shared.UserInfo{User: "birb"}}
+	udir := logic.NewUserDirectory(cfg, mockLogger, mockRepo, mockKeyStore, mockSender, mockTexts, mockMedia)
+	return ctrl, cfg, mockRepo, udir
+}
+
+func Test_User_Directory_Outbox_Summary_Links_Pages(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	acct := &dal.Account{Id: 5, Handle: "some.site.com"}
+	mockRepo.EXPECT().GetAccount(gomock.Eq("some.site.com")).Return(acct, nil).Times(1)
+	// Toots, not posts, are counted
+	mockRepo.EXPECT().GetPostCount(gomock.Any()).Times(0)
+	mockRepo.EXPECT().GetTootCount(gomock.Eq(acct.Id)).Return(uint(45), nil).Times(1)
+
+	summary, err := udir.GetOutboxSummary("some.site.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox", summary.Id)
+	assert.Equal(t, uint(45), summary.TotalItems)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox?page=1", *summary.First)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox?page=3", *summary.Last)
+}
+
+func Test_User_Directory_Outbox_Summary_Returns_Count_Error(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	acct := &dal.Account{Id: 5, Handle: "some.site.com"}
+	mockRepo.EXPECT().GetAccount(gomock.Eq("some.site.com")).Return(acct, nil).Times(1)
+	mockRepo.EXPECT().GetTootCount(gomock.Eq(acct.Id)).Return(uint(0), errors.New("database is locked")).Times(1)
+
+	summary, err := udir.GetOutboxSummary("some.site.com")
+	assert.NotNil(t, err)
+	assert.Nil(t, summary)
+}
+
+func Test_User_Directory_Outbox_Page_Wraps_Toots_In_Create(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	acct := &dal.Account{Id: 9, Handle: "some.site.com"}
+	tootedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	toots := []*dal.Toot{
+		{StatusId: "https://parrot.com/u/some.site.com/status/2", TootedAt: tootedAt, Content: "<p>Second</p>",
+			Hashtags: []string{"OpenSource"}},
+		{StatusId: "https://parrot.com/u/some.site.com/status/1", TootedAt: tootedAt, Content: "<p>First</p>"},
+	}
+	mockRepo.EXPECT().GetAccount(gomock.Eq("some.site.com")).Return(acct, nil).AnyTimes()
+	mockRepo.EXPECT().GetTootsPage(gomock.Eq(acct.Id), gomock.Eq(0), gomock.Any()).Return(toots, 22, nil).Times(1)
+	mockRepo.EXPECT().GetTootsPage(gomock.Eq(acct.Id), gomock.Eq(20), gomock.Any()).Return(toots, 22, nil).Times(1)
+
+	// First page: there's a next page, but no previous one
+	page, err := udir.GetOutboxPage("some.site.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "OrderedCollectionPage", page.Type)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox", page.PartOf)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox?page=2", *page.Next)
+	assert.Nil(t, page.Prev)
+	assert.Len(t, page.OrderedItems, 2)
+	act := page.OrderedItems[0].(*dto.ActivityOut)
+	assert.Equal(t, "Create", act.Type)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/status/2/activity", act.Id)
+	assert.Equal(t, "https://parrot.com/u/some.site.com", act.Actor)
+	note := act.Object.(*dto.Note)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/status/2", note.Id)
+	assert.Equal(t, "2024-01-01T10:00:00Z", note.Published)
+	assert.Equal(t, "<p>Second</p>", note.Content)
+	assert.Equal(t, []string{shared.ActivityPublic}, note.To)
+	assert.Equal(t, []string{"https://parrot.com/u/some.site.com/followers"}, note.Cc)
+	assert.Equal(t, &[]dto.Tag{{
+		Type: "Hashtag",
+		Href: "https://parrot.com/web/feeds/some.site.com?tag=OpenSource",
+		Name: "#OpenSource",
+	}}, note.Tag)
+	assert.Nil(t, page.OrderedItems[1].(*dto.ActivityOut).Object.(*dto.Note).Tag)
+
+	// Second page is the last one
+	page, err = udir.GetOutboxPage("some.site.com", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/outbox?page=1", *page.Prev)
+	assert.Nil(t, page.Next)
+}
+
+func Test_User_Directory_Outbox_Page_Unknown_User(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	mockRepo.EXPECT().GetAccount(gomock.Eq("nobody.com")).Return(nil, nil).Times(1)
+
+	page, err := udir.GetOutboxPage("nobody.com", 1)
+	assert.Nil(t, err)
+	assert.Nil(t, page)
+}
+
+func makeFollowers(count int) []*dal.FollowerInfo {
+	var res []*dal.FollowerInfo
+	for i := count; i > 0; i-- {
+		res = append(res, &dal.FollowerInfo{UserUrl: fmt.Sprintf("https://instance.com/users/user%03d", i)})
+	}
+	return res
+}
+
+func Test_User_Directory_Followers_Pages(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	mockRepo.EXPECT().DoesAccountExist(gomock.Eq("some.site.com")).Return(true, nil).AnyTimes()
+	mockRepo.EXPECT().GetFollowerCount(gomock.Eq("some.site.com"), gomock.Eq(true)).Return(uint(45), nil).Times(1)
+	mockRepo.EXPECT().GetFollowersByUser(gomock.Eq("some.site.com"), gomock.Eq(true)).
+		DoAndReturn(func(_ string, _ bool) ([]*dal.FollowerInfo, error) {
+			return makeFollowers(45), nil
+		}).Times(2)
+
+	summary := udir.GetFollowersSummary("some.site.com")
+	assert.Equal(t, uint(45), summary.TotalItems)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/followers?page=1", *summary.First)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/followers?page=2", *summary.Last)
+
+	// First page: sorted by actor URL
+	page, err := udir.GetFollowersPage("some.site.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(45), page.TotalItems)
+	assert.Len(t, page.OrderedItems, 40)
+	assert.Equal(t, "https://instance.com/users/user001", page.OrderedItems[0])
+	assert.Equal(t, "https://parrot.com/u/some.site.com/followers?page=2", *page.Next)
+	assert.Nil(t, page.Prev)
+
+	// Last page has the rest
+	page, err = udir.GetFollowersPage("some.site.com", 2)
+	assert.Nil(t, err)
+	assert.Len(t, page.OrderedItems, 5)
+	assert.Equal(t, "https://instance.com/users/user045", page.OrderedItems[4])
+	assert.Equal(t, "https://parrot.com/u/some.site.com/followers?page=1", *page.Prev)
+	assert.Nil(t, page.Next)
+}
+
+func Test_User_Directory_Hidden_Followers_Only_Counted(t *testing.T) {
+
+	ctrl, cfg, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+	cfg.HideFollowers = true
+
+	mockRepo.EXPECT().DoesAccountExist(gomock.Eq("some.site.com")).Return(true, nil).AnyTimes()
+	mockRepo.EXPECT().GetFollowerCount(gomock.Eq("some.site.com"), gomock.Eq(true)).Return(uint(3), nil).Times(1)
+	mockRepo.EXPECT().GetFollowersByUser(gomock.Eq("some.site.com"), gomock.Eq(true)).
+		Return(makeFollowers(3), nil).Times(1)
+
+	summary := udir.GetFollowersSummary("some.site.com")
+	assert.Equal(t, uint(3), summary.TotalItems)
+	assert.Nil(t, summary.First)
+	assert.Nil(t, summary.Last)
+
+	page, err := udir.GetFollowersPage("some.site.com", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), page.TotalItems)
+	assert.Empty(t, page.OrderedItems)
+	assert.Nil(t, page.Next)
+}
+
+func Test_User_Directory_Followers_On_Host(t *testing.T) {
+
+	ctrl, _, mockRepo, udir := setupUserDirectoryTest(t)
+	defer ctrl.Finish()
+
+	mockRepo.EXPECT().DoesAccountExist(gomock.Eq("some.site.com")).Return(true, nil).Times(1)
+	mockRepo.EXPECT().GetFollowersByUser(gomock.Eq("some.site.com"), gomock.Eq(true)).Return([]*dal.FollowerInfo{
+		{UserUrl: "https://instance.com/users/one"},
+		{UserUrl: "https://other.instance.com/users/two"},
+		{UserUrl: "https://Instance.com/users/three"},
+	}, nil).Times(1)
+
+	coll, err := udir.GetFollowersOnHost("some.site.com", "instance.com")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://parrot.com/u/some.site.com/followers_synchronization", coll.Id)
+	assert.Equal(t, uint(2), coll.TotalItems)
+	assert.Equal(t, []any{"https://instance.com/users/one", "https://Instance.com/users/three"}, coll.OrderedItems)
+}
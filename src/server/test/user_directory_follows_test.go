@@ -0,0 +1,63 @@
+package test
+
+import (
+	"crypto/rsa"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"rss_parrot/dal"
+	"rss_parrot/dto"
+	"rss_parrot/logic"
+	"rss_parrot/shared"
+	"rss_parrot/test/mocks"
+	"testing"
+)
+
+const rejectTestFollowId = "https://instance.com/users/one#follows/1"
+const rejectTestFollower = "https://instance.com/users/one"
+const rejectTestInbox = "https://instance.com/users/one/inbox"
+
+func test_User_Directory_Reject_Follower(t *testing.T, followedUser string, followedAcct *dal.Account, sendingUser string) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockLogger := mocks.NewMockILogger(ctrl)
+	mockRepo := mocks.NewMockIRepo(ctrl)
+	mockKeyStore := mocks.NewMockIKeyStore(ctrl)
+	mockSender := mocks.NewMockIActivitySender(ctrl)
+	mockTexts := mocks.NewMockITexts(ctrl)
+	mockMedia := mocks.NewMockIMediaCache(ctrl)
+	setupDummyLogger(mockLogger)
+	setupEmptyMediaCache(mockMedia)
+
+	cfg := &shared.Config{Host: "parrot.com", Birb: &shared.UserInfo{User: "birb"}}
+	udir := logic.NewUserDirectory(cfg, mockLogger, mockRepo, mockKeyStore, mockSender, mockTexts, mockMedia)
+
+	mockRepo.EXPECT().GetAccount(gomock.Eq(followedUser)).Return(followedAcct, nil).Times(1)
+	mockRepo.EXPECT().GetNextId().Return(uint64(77)).Times(1)
+	privKey := &rsa.PrivateKey{}
+	mockKeyStore.EXPECT().GetPrivKey(gomock.Eq(sendingUser)).Return(privKey, nil).Times(1)
+	var sent *dto.ActivityOut
+	mockSender.EXPECT().Send(gomock.Eq(privKey), gomock.Eq(sendingUser), gomock.Eq(rejectTestInbox), gomock.Any()).
+		DoAndReturn(func(_ *rsa.PrivateKey, _, _ string, act *dto.ActivityOut) error {
+			sent = act
+			return nil
+		}).Times(1)
+
+	err := udir.RejectFollower(rejectTestFollowId, rejectTestFollower, rejectTestInbox, followedUser)
+	assert.Nil(t, err)
+	assert.Equal(t, "Reject", sent.Type)
+	assert.Equal(t, "https://parrot.com/u/"+sendingUser, sent.Actor)
+	// Rejected Follow is echoed back as it was sent
+	follow := sent.Object.(dto.ActivityOut)
+	assert.Equal(t, rejectTestFollowId, follow.Id)
+	assert.Equal(t, rejectTestFollower, follow.Actor)
+	assert.Equal(t, "https://parrot.com/u/"+followedUser, follow.Object)
+}
+
+func Test_User_Directory_Reject_Follower_Of_Existing_User(t *testing.T) {
+	test_User_Directory_Reject_Follower(t, "some.site.com", &dal.Account{Handle: "some.site.com"}, "some.site.com")
+}
+
+func Test_User_Directory_Reject_Follower_Of_Missing_User_Comes_From_Birb(t *testing.T) {
+	test_User_Directory_Reject_Follower(t, "gone.site.com", nil, "birb")
+}